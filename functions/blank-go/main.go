@@ -33,6 +33,9 @@ func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.API
 	for _, element := range os.Environ() {
 		log.Println(element)
 	}
+	// build metadata
+	build := currentBuildInfo()
+	log.Printf("BUILD: %+v", build)
 	// request context
 	lc, _ := lambdacontext.FromContext(ctx)
 	log.Printf("REQUEST ID: %s", lc.AwsRequestID)
@@ -51,7 +54,11 @@ func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.API
 	}
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
-		Body:       usage,
+		Headers: map[string]string{
+			"X-Build-Version": build.Version,
+			"X-Build-Commit":  build.Commit,
+		},
+		Body: usage,
 	}, nil
 }
 
@@ -0,0 +1,22 @@
+package main
+
+import "os"
+
+// BuildInfo describes the release and commit this binary was built from
+type BuildInfo struct {
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	Dirty     string `json:"dirty,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+}
+
+// currentBuildInfo returns the build metadata from the BUILD_* environment variables set by
+// the Lambda stack, binaries are built without it so unchanged sources keep their zip
+func currentBuildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   os.Getenv("BUILD_VERSION"),
+		Commit:    os.Getenv("BUILD_COMMIT"),
+		Dirty:     os.Getenv("BUILD_DIRTY"),
+		BuildTime: os.Getenv("BUILD_TIME"),
+	}
+}
@@ -15,7 +15,7 @@ var ginLambda *ginadapter.GinLambda
 // init the Gin Server
 func init() {
 	// stdout and stderr are sent to AWS CloudWatch Logs
-	log.Printf("Gin cold start")
+	log.Printf("Gin cold start, build: %+v", currentBuildInfo())
 	r := gin.Default()
 	
 	// Add middleware to log the request path
@@ -31,6 +31,10 @@ func init() {
 		})
 	})
 	
+	r.GET("/gin-server/version", func(c *gin.Context) {
+		c.JSON(200, currentBuildInfo())
+	})
+	
 	r.GET("/gin-server", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "Hello from Gin and ebbo.dev!",
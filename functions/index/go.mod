@@ -0,0 +1,5 @@
+module functions/index
+
+go 1.24.2
+
+require github.com/aws/aws-lambda-go v1.48.0
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// Index describes an environment of the API, it is what the root of the API answers with
+type Index struct {
+	Environment string     `json:"environment"`
+	Version     string     `json:"version,omitempty"`
+	Functions   []Function `json:"functions"`
+}
+
+// Function is a function deployed behind the API
+type Function struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// LoadIndex reads the index from the environment variables set by the Lambda stack,
+// FUNCTION_URLS holds a JSON object of the URL of each function by name
+func LoadIndex() (*Index, error) {
+	index := &Index{
+		Environment: os.Getenv("ENVIRONMENT_NAME"),
+		Version:     os.Getenv("BUILD_VERSION"),
+		Functions:   []Function{},
+	}
+
+	urls := map[string]string{}
+	if value := os.Getenv("FUNCTION_URLS"); value != "" {
+		if err := json.Unmarshal([]byte(value), &urls); err != nil {
+			return nil, fmt.Errorf("malformed FUNCTION_URLS: %w", err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(urls)) {
+		index.Functions = append(index.Functions, Function{Name: name, URL: urls[name]})
+	}
+	return index, nil
+}
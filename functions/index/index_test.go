@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestLoadIndex(t *testing.T) {
+	t.Setenv("ENVIRONMENT_NAME", "staging")
+	t.Setenv("BUILD_VERSION", "v1.5.0")
+	t.Setenv("FUNCTION_URLS", `{"gin-server":"https://s-api.ebbo.dev/gin-server","blank-go":"https://s-api.ebbo.dev/blank-go"}`)
+
+	index, err := LoadIndex()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if index.Environment != "staging" || index.Version != "v1.5.0" {
+		t.Fatalf("unexpected index %+v", index)
+	}
+	if len(index.Functions) != 2 || index.Functions[0].Name != "blank-go" || index.Functions[1].URL != "https://s-api.ebbo.dev/gin-server" {
+		t.Fatalf("expected the functions sorted by name, got %+v", index.Functions)
+	}
+}
+
+func TestLoadIndexRejectsMalformedURLs(t *testing.T) {
+	t.Setenv("FUNCTION_URLS", "blank-go")
+	if _, err := LoadIndex(); err == nil {
+		t.Fatal("expected malformed FUNCTION_URLS to be rejected")
+	}
+}
+
+func TestHandlerAnswersWithTheIndex(t *testing.T) {
+	index, indexErr = &Index{Environment: "pr-42", Functions: []Function{}}, nil
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Path: "/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.StatusCode != 200 || response.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	var got Index
+	if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Environment != "pr-42" || got.Functions == nil {
+		t.Fatalf("unexpected body %s", response.Body)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// index is loaded once per execution environment, the variables it is read from never change
+var index, indexErr = LoadIndex()
+
+// Handler answers requests to the root of the API with the index of the environment
+func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if indexErr != nil {
+		log.Printf("index unavailable: %v", indexErr)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: `{"message":"index unavailable"}`}, nil
+	}
+	body, err := json.Marshal(index)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+		Body: string(body),
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Handler runs the reaper once, it is triggered by a scheduled EventBridge rule
+func Handler(ctx context.Context) (*Result, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRun, _ := strconv.ParseBool(os.Getenv("DRY_RUN"))
+	reaper := &Reaper{
+		CloudFormation: cloudformation.NewFromConfig(cfg),
+		Notifier:       sns.NewFromConfig(cfg),
+		TopicArn:       os.Getenv("TOPIC_ARN"),
+		RoleArn:        os.Getenv("CLOUDFORMATION_ROLE_ARN"),
+		DryRun:         dryRun,
+		PollInterval:   15 * time.Second,
+	}
+
+	// Reapers outside the global region also delete the global stacks of their environments
+	if region := os.Getenv("GLOBAL_REGION"); region != "" {
+		reaper.Global = cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
+			o.Region = region
+		})
+		reaper.GlobalRoleArn = os.Getenv("GLOBAL_CLOUDFORMATION_ROLE_ARN")
+	}
+
+	result, err := reaper.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("reaper finished: dryRun=%t expired=%v deleted=%v failed=%v", result.DryRun, result.Expired, result.Deleted, result.Failed)
+	return result, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/smithy-go"
+)
+
+// expiresAtTag is the stack tag holding the RFC 3339 expiry of an ephemeral environment
+const expiresAtTag = "ExpiresAt"
+
+// globalStackTag marks the stacks environments keep in the global region, see lib.NewGlobalStack
+const globalStackTag = "x:global-stack"
+
+// CloudFormationAPI is the subset of the CloudFormation client used by the reaper
+type CloudFormationAPI interface {
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error)
+	DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+}
+
+// NotifierAPI is the subset of the SNS client used to report reaper runs
+type NotifierAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// Reaper deletes CloudFormation stacks whose ExpiresAt tag lies in the past
+type Reaper struct {
+	CloudFormation CloudFormationAPI
+
+	// Notifier and TopicArn are optional, without them no notification is sent
+	Notifier NotifierAPI
+	TopicArn string
+
+	// RoleArn is the role CloudFormation assumes to delete the stacks
+	RoleArn string
+
+	// Global is the CloudFormation client of the global region, where environments of other
+	// regions keep some stacks. Expired stacks there that carry the global stack tag are deleted
+	// after those of the reaper's region, assuming GlobalRoleArn. It is nil when the reaper runs
+	// in the global region itself.
+	Global        CloudFormationAPI
+	GlobalRoleArn string
+
+	// DryRun only reports the stacks that would be deleted
+	DryRun bool
+
+	// PollInterval is how often stack deletion progress is checked
+	PollInterval time.Duration
+
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+}
+
+// Result summarizes a reaper run
+type Result struct {
+	DryRun  bool              `json:"dryRun"`
+	Expired []string          `json:"expired"`
+	Deleted []string          `json:"deleted"`
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+// reapTarget is a region the reaper deletes stacks in
+type reapTarget struct {
+	cloudFormation CloudFormationAPI
+	roleArn        string
+
+	// tag restricts the deleted stacks to those carrying it when set
+	tag string
+}
+
+// expiredStack is a stack that is due for deletion
+type expiredStack struct {
+	name      string
+	expiresAt time.Time
+	exports   []string
+}
+
+// Run finds all expired stacks and deletes them in dependency order, first in the reaper's
+// region and then the global stacks of expired environments
+func (r *Reaper) Run(ctx context.Context) (*Result, error) {
+	result := &Result{DryRun: r.DryRun, Failed: map[string]string{}}
+
+	targets := []reapTarget{{cloudFormation: r.CloudFormation, roleArn: r.RoleArn}}
+	if r.Global != nil {
+		targets = append(targets, reapTarget{cloudFormation: r.Global, roleArn: r.GlobalRoleArn, tag: globalStackTag})
+	}
+	for _, target := range targets {
+		if err := r.reap(ctx, target, result); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.notify(ctx, result); err != nil {
+		log.Printf("failed to publish notification: %v", err)
+	}
+	return result, nil
+}
+
+// reap deletes the expired stacks of the target and adds them to result, a failure in one
+// target does not keep the stacks of another from being deleted
+func (r *Reaper) reap(ctx context.Context, target reapTarget, result *Result) error {
+	expired, err := r.findExpired(ctx, target)
+	if err != nil {
+		return err
+	}
+	for _, stack := range expired {
+		result.Expired = append(result.Expired, stack.name)
+	}
+
+	waves, blocked, err := r.deletionOrder(ctx, target, expired)
+	if err != nil {
+		return err
+	}
+	targetResult := &Result{Failed: map[string]string{}}
+	for name, reason := range blocked {
+		targetResult.Failed[name] = reason
+	}
+
+	if !r.DryRun {
+		r.deleteWaves(ctx, target, waves, targetResult)
+	}
+
+	result.Deleted = append(result.Deleted, targetResult.Deleted...)
+	for name, reason := range targetResult.Failed {
+		result.Failed[name] = reason
+	}
+	return nil
+}
+
+// findExpired lists every stack of the target that carries an ExpiresAt tag in the past
+func (r *Reaper) findExpired(ctx context.Context, target reapTarget) ([]expiredStack, error) {
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+
+	var expired []expiredStack
+	var nextToken *string
+	for {
+		output, err := target.cloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{NextToken: nextToken})
+		if err != nil {
+			return nil, fmt.Errorf("error listing stacks: %w", err)
+		}
+
+		for _, stack := range output.Stacks {
+			if stack.StackStatus == types.StackStatusDeleteInProgress || stack.StackStatus == types.StackStatusDeleteComplete {
+				continue
+			}
+			if target.tag != "" && tagValue(stack.Tags, target.tag) == "" {
+				continue
+			}
+			value := tagValue(stack.Tags, expiresAtTag)
+			if value == "" {
+				continue
+			}
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				log.Printf("ignoring stack %s with invalid %s tag %q", aws.ToString(stack.StackName), expiresAtTag, value)
+				continue
+			}
+			if expiresAt.After(now()) {
+				continue
+			}
+
+			var exports []string
+			for _, output := range stack.Outputs {
+				if output.ExportName != nil {
+					exports = append(exports, *output.ExportName)
+				}
+			}
+			expired = append(expired, expiredStack{name: aws.ToString(stack.StackName), expiresAt: expiresAt, exports: exports})
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].name < expired[j].name })
+	return expired, nil
+}
+
+// deletionOrder groups the expired stacks into waves: every stack in a wave only exports
+// values to stacks in earlier waves. Stacks whose exports are used by a stack that has not
+// expired are blocked and never deleted.
+func (r *Reaper) deletionOrder(ctx context.Context, target reapTarget, expired []expiredStack) ([][]string, map[string]string, error) {
+	isExpired := map[string]bool{}
+	for _, stack := range expired {
+		isExpired[stack.name] = true
+	}
+
+	// importers maps each stack to the expired stacks importing its exports
+	importers := map[string][]string{}
+	blocked := map[string]string{}
+	for _, stack := range expired {
+		for _, export := range stack.exports {
+			names, err := r.listImports(ctx, target, export)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, name := range names {
+				if !isExpired[name] {
+					blocked[stack.name] = fmt.Sprintf("export %s is imported by %s, which has not expired", export, name)
+					continue
+				}
+				importers[stack.name] = append(importers[stack.name], name)
+			}
+		}
+	}
+
+	// Anything exporting to a blocked stack is blocked as well
+	for changed := true; changed; {
+		changed = false
+		for _, stack := range expired {
+			if _, ok := blocked[stack.name]; ok {
+				continue
+			}
+			for _, importer := range importers[stack.name] {
+				if _, ok := blocked[importer]; ok {
+					blocked[stack.name] = fmt.Sprintf("depends on blocked stack %s", importer)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var waves [][]string
+	scheduled := map[string]bool{}
+	for {
+		var wave []string
+		for _, stack := range expired {
+			if scheduled[stack.name] || blocked[stack.name] != "" {
+				continue
+			}
+			ready := true
+			for _, importer := range importers[stack.name] {
+				if !scheduled[importer] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, stack.name)
+			}
+		}
+		if len(wave) == 0 {
+			break
+		}
+		for _, name := range wave {
+			scheduled[name] = true
+		}
+		waves = append(waves, wave)
+	}
+
+	// Whatever is left over is part of an import cycle
+	for _, stack := range expired {
+		if !scheduled[stack.name] && blocked[stack.name] == "" {
+			blocked[stack.name] = "part of an import cycle"
+		}
+	}
+
+	return waves, blocked, nil
+}
+
+// listImports returns the names of all stacks of the target importing the export
+func (r *Reaper) listImports(ctx context.Context, target reapTarget, export string) ([]string, error) {
+	var names []string
+	var nextToken *string
+	for {
+		output, err := target.cloudFormation.ListImports(ctx, &cloudformation.ListImportsInput{
+			ExportName: aws.String(export),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			// An export nobody imports is reported as an error by CloudFormation
+			if isNotImported(err) {
+				return names, nil
+			}
+			return nil, fmt.Errorf("error listing imports of %s: %w", export, err)
+		}
+		names = append(names, output.Imports...)
+
+		if output.NextToken == nil {
+			return names, nil
+		}
+		nextToken = output.NextToken
+	}
+}
+
+// deleteWaves deletes the stacks wave by wave, waiting for each wave to finish.
+// Stacks that export values to a stack which failed to delete are skipped.
+func (r *Reaper) deleteWaves(ctx context.Context, target reapTarget, waves [][]string, result *Result) {
+	for i, wave := range waves {
+		var started []string
+		for _, name := range wave {
+			input := &cloudformation.DeleteStackInput{StackName: aws.String(name)}
+			if target.roleArn != "" {
+				input.RoleARN = aws.String(target.roleArn)
+			}
+			if _, err := target.cloudFormation.DeleteStack(ctx, input); err != nil {
+				result.Failed[name] = err.Error()
+				continue
+			}
+			started = append(started, name)
+		}
+
+		for _, name := range started {
+			if err := r.waitForDeletion(ctx, target, name); err != nil {
+				result.Failed[name] = err.Error()
+				continue
+			}
+			result.Deleted = append(result.Deleted, name)
+		}
+
+		// Later waves export values to this wave, so they cannot be deleted after a failure
+		if len(result.Failed) > 0 {
+			for _, remaining := range waves[i+1:] {
+				for _, name := range remaining {
+					result.Failed[name] = "skipped after an earlier deletion failed"
+				}
+			}
+			return
+		}
+	}
+}
+
+// waitForDeletion polls the stack until it is gone or its deletion failed
+func (r *Reaper) waitForDeletion(ctx context.Context, target reapTarget, name string) error {
+	for {
+		output, err := target.cloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+		if err != nil {
+			if isStackMissing(err) {
+				return nil
+			}
+			return err
+		}
+		if len(output.Stacks) == 0 {
+			return nil
+		}
+
+		switch stack := output.Stacks[0]; stack.StackStatus {
+		case types.StackStatusDeleteComplete:
+			return nil
+		case types.StackStatusDeleteFailed:
+			return fmt.Errorf("deletion failed: %s", aws.ToString(stack.StackStatusReason))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// notify publishes a summary of the run to the SNS topic
+func (r *Reaper) notify(ctx context.Context, result *Result) error {
+	if r.Notifier == nil || r.TopicArn == "" || (len(result.Expired) == 0 && len(result.Failed) == 0) {
+		return nil
+	}
+
+	subject := fmt.Sprintf("Reaped %d expired stack(s)", len(result.Deleted))
+	if r.DryRun {
+		subject = fmt.Sprintf("Dry run: %d stack(s) would be reaped", len(result.Expired)-len(result.Failed))
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Expired stacks: %s\n", strings.Join(result.Expired, ", "))
+	if !r.DryRun {
+		fmt.Fprintf(&body, "Deleted stacks: %s\n", strings.Join(result.Deleted, ", "))
+	}
+	failed := make([]string, 0, len(result.Failed))
+	for name := range result.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		fmt.Fprintf(&body, "Not deleted %s: %s\n", name, result.Failed[name])
+	}
+
+	_, err := r.Notifier.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(r.TopicArn),
+		Subject:  aws.String(subject),
+		Message:  aws.String(body.String()),
+	})
+	return err
+}
+
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// isStackMissing reports whether CloudFormation answered that the stack does not exist
+func isStackMissing(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.ErrorMessage(), "does not exist")
+}
+
+// isNotImported reports whether CloudFormation answered that an export is not imported
+func isNotImported(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.ErrorMessage(), "is not imported by any stack")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/smithy-go"
+)
+
+var now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+// fakeCloudFormation keeps stacks in memory and deletes them immediately
+type fakeCloudFormation struct {
+	stacks  map[string]types.Stack
+	imports map[string][]string
+	failing map[string]bool
+	deleted []string
+	roles   []string
+}
+
+func (f *fakeCloudFormation) addStack(name, expiresAt string, exports ...string) {
+	stack := types.Stack{StackName: aws.String(name), StackStatus: types.StackStatusUpdateComplete}
+	if expiresAt != "" {
+		stack.Tags = []types.Tag{{Key: aws.String(expiresAtTag), Value: aws.String(expiresAt)}}
+	}
+	for _, export := range exports {
+		stack.Outputs = append(stack.Outputs, types.Output{ExportName: aws.String(export)})
+	}
+	f.stacks[name] = stack
+}
+
+func (f *fakeCloudFormation) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if params.StackName != nil {
+		stack, ok := f.stacks[*params.StackName]
+		if !ok {
+			return nil, &smithy.GenericAPIError{Code: "ValidationError", Message: "Stack with id " + *params.StackName + " does not exist"}
+		}
+		return &cloudformation.DescribeStacksOutput{Stacks: []types.Stack{stack}}, nil
+	}
+
+	names := make([]string, 0, len(f.stacks))
+	for name := range f.stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	output := &cloudformation.DescribeStacksOutput{}
+	for _, name := range names {
+		output.Stacks = append(output.Stacks, f.stacks[name])
+	}
+	return output, nil
+}
+
+func (f *fakeCloudFormation) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	var importers []string
+	for _, name := range f.imports[*params.ExportName] {
+		if _, ok := f.stacks[name]; ok {
+			importers = append(importers, name)
+		}
+	}
+	if len(importers) == 0 {
+		return nil, &smithy.GenericAPIError{Code: "ValidationError", Message: "Export '" + *params.ExportName + "' is not imported by any stack."}
+	}
+	return &cloudformation.ListImportsOutput{Imports: importers}, nil
+}
+
+func (f *fakeCloudFormation) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	name := *params.StackName
+	f.deleted = append(f.deleted, name)
+	f.roles = append(f.roles, aws.ToString(params.RoleARN))
+
+	if f.failing[name] {
+		stack := f.stacks[name]
+		stack.StackStatus = types.StackStatusDeleteFailed
+		stack.StackStatusReason = aws.String("resource in use")
+		f.stacks[name] = stack
+		return &cloudformation.DeleteStackOutput{}, nil
+	}
+	delete(f.stacks, name)
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (f *fakeCloudFormation) tagStack(name, key, value string) {
+	stack := f.stacks[name]
+	stack.Tags = append(stack.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	f.stacks[name] = stack
+}
+
+type fakeNotifier struct {
+	messages []string
+}
+
+func (f *fakeNotifier) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.messages = append(f.messages, *params.Subject+"\n"+*params.Message)
+	return &sns.PublishOutput{}, nil
+}
+
+// newEnvironment returns a fake account with an expired PR environment whose
+// lambda and vaultwarden stacks import from its core stack, plus a live environment
+func newEnvironment() *fakeCloudFormation {
+	cfn := &fakeCloudFormation{
+		stacks:  map[string]types.Stack{},
+		imports: map[string][]string{},
+		failing: map[string]bool{},
+	}
+	cfn.addStack("pr-1-core-stack", "2026-10-15T00:00:00Z", "pr-1-zone", "pr-1-certificate")
+	cfn.addStack("pr-1-lambda-stack", "2026-10-15T00:00:00Z")
+	cfn.addStack("pr-1-vaultwarden-stack", "2026-10-15T00:00:00Z")
+	cfn.addStack("pr-2-core-stack", "2026-10-20T00:00:00Z")
+	cfn.addStack("p-core-stack", "")
+	cfn.imports["pr-1-zone"] = []string{"pr-1-lambda-stack", "pr-1-vaultwarden-stack"}
+	cfn.imports["pr-1-certificate"] = []string{"pr-1-lambda-stack"}
+	return cfn
+}
+
+func newReaper(cfn *fakeCloudFormation, notifier *fakeNotifier) *Reaper {
+	return &Reaper{
+		CloudFormation: cfn,
+		Notifier:       notifier,
+		TopicArn:       "arn:aws:sns:eu-central-1:123456789012:reaper",
+		RoleArn:        "arn:aws:iam::123456789012:role/cfn-exec",
+		Now:            func() time.Time { return now },
+	}
+}
+
+func TestReaperDeletesExpiredStacksInDependencyOrder(t *testing.T) {
+	cfn := newEnvironment()
+	notifier := &fakeNotifier{}
+
+	result, err := newReaper(cfn, notifier).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"pr-1-lambda-stack", "pr-1-vaultwarden-stack", "pr-1-core-stack"}
+	if !reflect.DeepEqual(cfn.deleted, expected) {
+		t.Fatalf("expected deletion order %v, got %v", expected, cfn.deleted)
+	}
+	if !reflect.DeepEqual(result.Deleted, expected) {
+		t.Fatalf("expected deleted stacks %v, got %v", expected, result.Deleted)
+	}
+	if _, ok := cfn.stacks["pr-2-core-stack"]; !ok {
+		t.Fatal("stacks that have not expired must not be deleted")
+	}
+	if cfn.roles[0] != "arn:aws:iam::123456789012:role/cfn-exec" {
+		t.Fatalf("expected deletion with the CloudFormation role, got %q", cfn.roles[0])
+	}
+	if len(notifier.messages) != 1 || !strings.Contains(notifier.messages[0], "Reaped 3 expired stack(s)") {
+		t.Fatalf("unexpected notifications %v", notifier.messages)
+	}
+}
+
+func TestReaperDryRunDeletesNothing(t *testing.T) {
+	cfn := newEnvironment()
+	notifier := &fakeNotifier{}
+
+	reaper := newReaper(cfn, notifier)
+	reaper.DryRun = true
+
+	result, err := reaper.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfn.deleted) != 0 {
+		t.Fatalf("dry run must not delete stacks, deleted %v", cfn.deleted)
+	}
+	if len(result.Expired) != 3 {
+		t.Fatalf("expected three expired stacks, got %v", result.Expired)
+	}
+	if len(notifier.messages) != 1 || !strings.Contains(notifier.messages[0], "Dry run: 3 stack(s) would be reaped") {
+		t.Fatalf("unexpected notifications %v", notifier.messages)
+	}
+}
+
+func TestReaperKeepsStacksExportingToLiveStacks(t *testing.T) {
+	cfn := newEnvironment()
+	cfn.addStack("p-lambda-stack", "")
+	cfn.imports["pr-1-zone"] = append(cfn.imports["pr-1-zone"], "p-lambda-stack")
+
+	result, err := newReaper(cfn, &fakeNotifier{}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := cfn.stacks["pr-1-core-stack"]; !ok {
+		t.Fatal("a stack exporting to a live stack must not be deleted")
+	}
+	if !strings.Contains(result.Failed["pr-1-core-stack"], "p-lambda-stack") {
+		t.Fatalf("expected a reason naming the importing stack, got %v", result.Failed)
+	}
+}
+
+func TestReaperStopsAfterFailedDeletion(t *testing.T) {
+	cfn := newEnvironment()
+	cfn.failing["pr-1-lambda-stack"] = true
+
+	result, err := newReaper(cfn, &fakeNotifier{}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := cfn.stacks["pr-1-core-stack"]; !ok {
+		t.Fatal("the core stack must not be deleted while an importer failed to delete")
+	}
+	if !strings.Contains(result.Failed["pr-1-lambda-stack"], "resource in use") {
+		t.Fatalf("expected the deletion failure to be reported, got %v", result.Failed)
+	}
+}
+
+func TestReaperDeletesGlobalStacksAfterTheirEnvironments(t *testing.T) {
+	cfn := newEnvironment()
+	global := &fakeCloudFormation{
+		stacks:  map[string]types.Stack{},
+		imports: map[string][]string{},
+		failing: map[string]bool{},
+	}
+	global.addStack("pr-1-dnssec-key-stack", "2026-10-15T00:00:00Z")
+	global.tagStack("pr-1-dnssec-key-stack", globalStackTag, "true")
+	global.addStack("pr-2-maintenance-stack", "2026-10-20T00:00:00Z")
+	global.tagStack("pr-2-maintenance-stack", globalStackTag, "true")
+	// Environments deployed to the global region itself are left to the reaper there
+	global.addStack("pr-3-core-stack", "2026-10-15T00:00:00Z")
+
+	reaper := newReaper(cfn, &fakeNotifier{})
+	reaper.Global = global
+	reaper.GlobalRoleArn = "arn:aws:iam::123456789012:role/cfn-exec-us-east-1"
+	result, err := reaper.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"pr-1-lambda-stack", "pr-1-vaultwarden-stack", "pr-1-core-stack", "pr-1-dnssec-key-stack"}
+	if !reflect.DeepEqual(result.Deleted, expected) {
+		t.Fatalf("expected deleted stacks %v, got %v", expected, result.Deleted)
+	}
+	if !reflect.DeepEqual(global.deleted, []string{"pr-1-dnssec-key-stack"}) {
+		t.Fatalf("expected only the expired global stack to be deleted, got %v", global.deleted)
+	}
+	if global.roles[0] != "arn:aws:iam::123456789012:role/cfn-exec-us-east-1" {
+		t.Fatalf("expected deletion with the CloudFormation role of the global region, got %q", global.roles[0])
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Handler answers the events of the SMTP password custom resource, cfn.LambdaWrap reports the
+// result to CloudFormation
+func Handler(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	writer := &PasswordWriter{SecretsManager: secretsmanager.NewFromConfig(cfg)}
+	return writer.Handle(ctx, event)
+}
+
+func main() {
+	lambda.Start(cfn.LambdaWrap(Handler))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Keys of the access key in the source secret and of the SMTP credentials in the target secret
+const (
+	usernameKey        = "username"
+	secretAccessKeyKey = "secretAccessKey"
+	passwordKey        = "password"
+)
+
+// smtpPasswordVersion prefixes every SES SMTP password
+const smtpPasswordVersion = 0x04
+
+// SecretsManagerAPI is the subset of the Secrets Manager client used by the handler
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+}
+
+// Properties of the custom resource
+type Properties struct {
+	// SourceSecretID holds the username and secret access key, it is only read
+	SourceSecretID string `json:"SourceSecretId"`
+
+	// SecretID receives the username and SMTP password
+	SecretID string `json:"SecretId"`
+
+	// Region is the region of the SES SMTP endpoint the password is valid for
+	Region string `json:"Region"`
+}
+
+// PasswordWriter derives SMTP passwords from the access key in one secret and writes them to another
+type PasswordWriter struct {
+	SecretsManager SecretsManagerAPI
+}
+
+// Handle writes the SMTP credentials on create and update and returns the credentials secret as
+// physical ID. The source secret is never changed, so every update derives the password again
+// from the same key. Deletes are left to CloudFormation, which owns both secrets.
+func (w *PasswordWriter) Handle(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
+	if event.RequestType == cfn.RequestDelete {
+		return event.PhysicalResourceID, nil, nil
+	}
+
+	props, err := parseProperties(event.ResourceProperties)
+	if err != nil {
+		return "", nil, err
+	}
+
+	source, err := w.SecretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(props.SourceSecretID),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("read access key: %w", err)
+	}
+	var key map[string]string
+	if err := json.Unmarshal([]byte(aws.ToString(source.SecretString)), &key); err != nil {
+		return "", nil, fmt.Errorf("read access key: %w", err)
+	}
+	if key[usernameKey] == "" || key[secretAccessKeyKey] == "" {
+		return "", nil, fmt.Errorf("secret %s must hold %s and %s", props.SourceSecretID, usernameKey, secretAccessKeyKey)
+	}
+
+	credentials, err := json.Marshal(map[string]string{
+		usernameKey: key[usernameKey],
+		passwordKey: SmtpPassword(key[secretAccessKeyKey], props.Region),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := w.SecretsManager.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(props.SecretID),
+		SecretString: aws.String(string(credentials)),
+	}); err != nil {
+		return "", nil, fmt.Errorf("write SMTP credentials: %w", err)
+	}
+	return props.SecretID, nil, nil
+}
+
+// parseProperties reads the properties of the custom resource and reports missing ones
+func parseProperties(properties map[string]interface{}) (*Properties, error) {
+	raw, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	props := &Properties{}
+	if err := json.Unmarshal(raw, props); err != nil {
+		return nil, fmt.Errorf("invalid properties: %w", err)
+	}
+	if props.SourceSecretID == "" || props.SecretID == "" || props.Region == "" {
+		return nil, errors.New("SourceSecretId, SecretId and Region must be set")
+	}
+	return props, nil
+}
+
+// SmtpPassword derives the SES SMTP password of a secret access key for the region, see
+// https://docs.aws.amazon.com/ses/latest/dg/smtp-credentials.html
+func SmtpPassword(secretAccessKey, region string) string {
+	signature := sign([]byte("AWS4"+secretAccessKey), "11111111")
+	for _, value := range []string{region, "ses", "aws4_request", "SendRawEmail"} {
+		signature = sign(signature, value)
+	}
+	return base64.StdEncoding.EncodeToString(append([]byte{smtpPasswordVersion}, signature...))
+}
+
+func sign(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// fakeSecretsManager keeps secret strings in memory
+type fakeSecretsManager struct {
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(f.secrets[*params.SecretId])}, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	f.secrets[*params.SecretId] = *params.SecretString
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func TestSmtpPasswordIsVersionedAndRegional(t *testing.T) {
+	password, err := base64.StdEncoding.DecodeString(SmtpPassword("secret", "eu-central-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 33 || password[0] != smtpPasswordVersion {
+		t.Errorf("expected a version byte and a SHA-256 signature, got %x", password)
+	}
+	if SmtpPassword("secret", "eu-central-1") == SmtpPassword("secret", "us-east-1") {
+		t.Error("expected passwords of different regions to differ")
+	}
+}
+
+func TestHandleKeepsTheAccessKeyForLaterUpdates(t *testing.T) {
+	fake := &fakeSecretsManager{secrets: map[string]string{
+		"key":         `{"username":"AKIA","secretAccessKey":"secret"}`,
+		"credentials": "generated",
+	}}
+	writer := &PasswordWriter{SecretsManager: fake}
+	event := cfn.Event{
+		RequestType:        cfn.RequestCreate,
+		ResourceProperties: map[string]interface{}{"SourceSecretId": "key", "SecretId": "credentials", "Region": "eu-central-1"},
+	}
+
+	physicalID, _, err := writer.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalID != "credentials" {
+		t.Errorf("expected the credentials secret as physical ID, got %q", physicalID)
+	}
+
+	// A region change derives the password again from the untouched access key
+	event.RequestType = cfn.RequestUpdate
+	event.ResourceProperties["Region"] = "us-east-1"
+	if _, _, err := writer.Handle(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	var credentials map[string]string
+	if err := json.Unmarshal([]byte(fake.secrets["credentials"]), &credentials); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{usernameKey: "AKIA", passwordKey: SmtpPassword("secret", "us-east-1")}
+	if len(credentials) != len(want) || credentials[usernameKey] != want[usernameKey] || credentials[passwordKey] != want[passwordKey] {
+		t.Errorf("expected credentials %v, got %v", want, credentials)
+	}
+	if fake.secrets["key"] != `{"username":"AKIA","secretAccessKey":"secret"}` {
+		t.Errorf("expected the access key secret to be unchanged, got %s", fake.secrets["key"])
+	}
+}
+
+func TestHandleLeavesDeletesToCloudFormation(t *testing.T) {
+	fake := &fakeSecretsManager{secrets: map[string]string{}}
+	writer := &PasswordWriter{SecretsManager: fake}
+
+	physicalID, _, err := writer.Handle(context.Background(), cfn.Event{RequestType: cfn.RequestDelete, PhysicalResourceID: "credentials"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalID != "credentials" || len(fake.secrets) != 0 {
+		t.Errorf("expected the delete to keep the physical ID and touch no secret, got %q and %v", physicalID, fake.secrets)
+	}
+}
+
+func TestHandleRejectsMissingProperties(t *testing.T) {
+	writer := &PasswordWriter{SecretsManager: &fakeSecretsManager{secrets: map[string]string{}}}
+
+	_, _, err := writer.Handle(context.Background(), cfn.Event{
+		RequestType:        cfn.RequestCreate,
+		ResourceProperties: map[string]interface{}{"SecretId": "credentials"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for missing properties")
+	}
+}
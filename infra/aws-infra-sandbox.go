@@ -1,118 +1,169 @@
 package main
 
 import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/jsii-runtime-go"
 
 	"aws-infra-sandbox/lib"
-	"aws-infra-sandbox/stacks/core"
-	"aws-infra-sandbox/stacks/lambda"
-	"aws-infra-sandbox/stacks/vaultwarden"
+	"aws-infra-sandbox/stacks/bootstrap"
+	"aws-infra-sandbox/stacks/reaper"
+
+	// Stack packages register their stacks when imported
+	_ "aws-infra-sandbox/stacks/core"
+	_ "aws-infra-sandbox/stacks/lambda"
+	_ "aws-infra-sandbox/stacks/vaultwarden"
 )
 
+// defaultProfiles are the environment profiles shipped with the app
+//
+//go:embed environments.yaml
+var defaultProfiles []byte
+
+// rootDomainRecords are the records of ebbo.dev, created by the environment served from it. They
+// were created by hand before, so they are only created with the root_records context value
+// once the hand-made records are deleted from the zone.
+var rootDomainRecords = []lib.DNSRecord{
+	// GitHub Pages serves the apex domain with certificates from Let's Encrypt
+	lib.CAARecord("", "letsencrypt.org"),
+	{Type: lib.DNSRecordA, Values: []string{"185.199.108.153", "185.199.109.153", "185.199.110.153", "185.199.111.153"}},
+	{Type: lib.DNSRecordAAAA, Values: []string{"2606:50c0:8000::153", "2606:50c0:8001::153", "2606:50c0:8002::153", "2606:50c0:8003::153"}},
+
+	// Mail is only sent through SES, whose MAIL FROM subdomain has its own SPF record
+	lib.SPFRecord(""),
+	lib.DMARCRecord("", "reject", ""),
+}
+
 func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	defer jsii.Close()
 
 	app := awscdk.NewApp(nil)
 
-	// Get environment information from context
-	environment := lib.GetEnvironmentFromContext(app)
-
-	// Add environment tags to all resources
-	awscdk.Tags_Of(app).Add(jsii.String("Environment"), jsii.String(environment.Name), nil)
-	awscdk.Tags_Of(app).Add(jsii.String("Username"), jsii.String(environment.Username), nil)
+	// Load the environment profiles, a custom file can be passed via context
+	profiles, err := loadProfiles(app)
+	if err != nil {
+		return err
+	}
 
-	if environment.IsPR {
-		awscdk.Tags_Of(app).Add(jsii.String("PR"), jsii.String("true"), nil)
-		awscdk.Tags_Of(app).Add(jsii.String("PR"), jsii.String(environment.PRNumber), nil)
+	// Get environment information from context
+	environment, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		return fmt.Errorf("invalid environment: %w", err)
 	}
-	if environment.Version != "" {
-		awscdk.Tags_Of(app).Add(jsii.String("Version"), jsii.String(environment.Version), nil)
+
+	// Tag all resources and enforce the tag policy when synthesizing, violations are error
+	// annotations that keep the toolkit from deploying the assembly
+	tagPolicy := lib.DefaultTagPolicy()
+	tagPolicy.Apply(app, environment)
+
+	// The reaper is deployed on its own, once per account and region
+	if lib.ContextBool(app, "reaper") {
+		_, err := reaper.NewReaperStack(app, reaper.StackName, &reaper.ReaperStackProps{
+			StackProps: awscdk.StackProps{
+				Env: environment.AwsEnvironment(),
+			},
+			Environment:       environment,
+			DryRun:            lib.ContextBool(app, "reaper_dry_run"),
+			NotificationEmail: lib.ContextString(app, "reaper_email"),
+		})
+		if err != nil {
+			return fmt.Errorf("reaper: %w", err)
+		}
+		app.Synth(nil)
+		return tagPolicy.Err()
 	}
 
-	// Create stacks with environment-specific names
-	coreStackName := environment.GetStackName("CoreStack")
-	lambdaStackName := environment.GetStackName("LambdaStack")
-	vaultwardenStackName := environment.GetStackName("VaultwardenStack")
+	// The GitHub deploy roles are deployed on their own, once per account
+	if lib.ContextBool(app, "bootstrap") {
+		_, err := bootstrap.NewBootstrapStack(app, bootstrap.StackName, &bootstrap.BootstrapStackProps{
+			StackProps: awscdk.StackProps{
+				Env: environment.AwsEnvironment(),
+			},
+			Profiles:        profiles,
+			Repository:      lib.ContextString(app, "github_repository"),
+			Qualifier:       lib.ContextString(app, "bootstrap_qualifier"),
+			OIDCProviderArn: lib.ContextString(app, "github_oidc_provider_arn"),
+		})
+		if err != nil {
+			return fmt.Errorf("bootstrap: %w", err)
+		}
+		app.Synth(nil)
+		return tagPolicy.Err()
+	}
 
-	// Configure domain for all stacks
+	// Resolve every hostname of the environment through the strategy of its profile
+	domainStrategy, err := environment.Profile.Domains()
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", environment.Name, err)
+	}
 	domainConfig := &lib.DomainConfig{
 		RootDomain:   "ebbo.dev",
 		HostedZoneId: "Z02287733RP9AY57D3IRQ",
+		// Needed when the environment's account does not own the root zone
+		DelegationRoleArn: lib.ContextString(app, "delegation_role_arn"),
+		Strategy:          domainStrategy,
+		DNSSEC:            environment.Profile.DNSSEC,
+		Email:             environment.Profile.Email,
+		Failover:          environment.Profile.Failover,
 	}
-
-	// Create props for each stack with environment information
-	coreProps := &core.CoreStackProps{
-		StackProps: awscdk.StackProps{
-			Env: env(),
-		},
-		Environment:  environment,
-		DomainConfig: domainConfig,
-	}
-
-	lambdaProps := &lambda.LambdaStackProps{
-		StackProps: awscdk.StackProps{
-			Env: env(),
-		},
-		Environment:  environment,
-		DomainConfig: domainConfig,
+	if domainConfig.IsApex(environment) && lib.ContextBool(app, "root_records") {
+		domainConfig.Records = rootDomainRecords
 	}
 
-	// Configure Vaultwarden stack
-	vaultwardenConfig := &vaultwarden.VaultwardenConfig{
-		// Base configuration
-		BaseImageName: "vaultwarden/server",
-		BaseVersion:   "latest",
-		DomainName:    "", // Will be auto-generated as vault.<env>.ebbo.dev
-
-		// VPC configuration
-		VpcCidr: "20.0.0.0/24",
-		MaxAzs:  2,
-
-		// ECS configuration
-		ClusterName:  environment.GetStackName("vaultwarden-cluster"),
-		DesiredCount: 1,
-		Cpu:          256, // 0.25 vCPU
-		MemoryMiB:    512, // 512 MB RAM
-
-		// EFS configuration
-		FileSystemName:            environment.GetStackName("vaultwarden-fs"),
-		EnableAutomaticBackups:    true,
-		LifecyclePolicyDays:       14,
-		OutOfInfrequentAccessHits: 1,
-	}
+	// Fail synthesis if two constructs claim the same DNS record
+	dnsClaims := lib.NewDNSRecordClaims()
+	dnsClaims.Apply(app)
 
-	vaultwardenProps := &vaultwarden.VaultwardenStackProps{
+	// Build the stacks selected by the environment profile or the "stacks" context value, the
+	// stacks validate their props and every problem of every stack is reported together
+	stacks, err := lib.BuildStacks(app, environment.Stacks, lib.StackFactoryProps{
 		StackProps: awscdk.StackProps{
-			Env: env(),
+			Env: environment.AwsEnvironment(),
 		},
 		Environment:  environment,
-		Config:       vaultwardenConfig,
 		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		return fmt.Errorf("environment %s: %w", environment.Name, err)
 	}
 
-	// Create the stacks
-	core.NewCoreStack(app, coreStackName, coreProps)
-	lambdaStack := lambda.NewLambdaStack(app, lambdaStackName, lambdaProps)
-	vaultwarden.NewVaultwardenStack(app, vaultwardenStackName, vaultwardenProps)
-
 	// Add stack outputs for PR environments
-	if environment.IsPR {
-		awscdk.NewCfnOutput(lambdaStack, jsii.String("EnvironmentType"), &awscdk.CfnOutputProps{
+	if lambdaStack, ok := stacks["lambda"]; ok && environment.IsPR {
+		awscdk.NewCfnOutput(lambdaStack.CdkStack(), jsii.String("EnvironmentType"), &awscdk.CfnOutputProps{
 			Value: jsii.String("PR"),
 		})
-		awscdk.NewCfnOutput(lambdaStack, jsii.String("PRNumber"), &awscdk.CfnOutputProps{
+		awscdk.NewCfnOutput(lambdaStack.CdkStack(), jsii.String("PRNumber"), &awscdk.CfnOutputProps{
 			Value: jsii.String(environment.PRNumber),
 		})
 	}
 
 	app.Synth(nil)
+
+	// Also exit with an error if any resource ended up without the required tags or records
+	// conflict, for callers that run the app without the toolkit
+	return errors.Join(tagPolicy.Err(), dnsClaims.Err())
 }
 
-// env determines the AWS environment (account+region) in which our stack is to
-// be deployed. For more information see: https://docs.aws.amazon.com/cdk/latest/guide/environments.html
-func env() *awscdk.Environment {
-	// For development, we'll use environment-agnostic stacks
-	// The actual account and region will be provided by the CDK CLI during deployment
-	return nil
+// loadProfiles returns the profiles file named by the "profiles" context value or the embedded defaults
+func loadProfiles(app awscdk.App) (*lib.ProfileSet, error) {
+	if path, ok := app.Node().TryGetContext(jsii.String("profiles")).(string); ok && path != "" {
+		return lib.LoadProfiles(path)
+	}
+
+	profiles, err := lib.ParseProfiles(defaultProfiles, ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("environments.yaml: %w", err)
+	}
+	return profiles, nil
 }
@@ -8,6 +8,7 @@ require (
 	github.com/aws/aws-cdk-go/awscdk/v2 v2.219.0
 	github.com/aws/constructs-go/constructs/v10 v10.4.4
 	github.com/aws/jsii-runtime-go v1.121.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// DefaultBudgetThresholds are the percentages of the monthly limit that alert on actual cost
+var DefaultBudgetThresholds = []float64{50, 80, 100}
+
+// BudgetConfig limits the monthly cost of an environment, measured on its Environment tag
+type BudgetConfig struct {
+	// MonthlyLimit in USD
+	MonthlyLimit float64 `json:"monthlyLimit" yaml:"monthlyLimit"`
+
+	// Thresholds in percent of the monthly limit, defaults to DefaultBudgetThresholds. The
+	// forecasted cost alerts once it exceeds the limit.
+	Thresholds []float64 `json:"thresholds,omitempty" yaml:"thresholds,omitempty"`
+
+	// AnomalyThreshold is the total impact in USD from which cost anomalies alert, 0 disables
+	// anomaly detection
+	AnomalyThreshold float64 `json:"anomalyThreshold,omitempty" yaml:"anomalyThreshold,omitempty"`
+
+	// Subscribers are email addresses subscribed to the cost alerts
+	Subscribers []string `json:"subscribers,omitempty" yaml:"subscribers,omitempty"`
+}
+
+// ActualThresholds returns the percentages of the limit that alert on actual cost
+func (c *BudgetConfig) ActualThresholds() []float64 {
+	if len(c.Thresholds) == 0 {
+		return DefaultBudgetThresholds
+	}
+	return c.Thresholds
+}
+
+// Validate checks the limit, the thresholds and the subscribers
+func (c *BudgetConfig) Validate() error {
+	var errs []error
+	if c.MonthlyLimit <= 0 {
+		errs = append(errs, fmt.Errorf("budget monthly limit must be positive, got %g", c.MonthlyLimit))
+	}
+	// A budget holds at most five notifications, one of them alerts on the forecast
+	if len(c.Thresholds) > 4 {
+		errs = append(errs, fmt.Errorf("at most 4 budget thresholds are supported, got %d", len(c.Thresholds)))
+	}
+	seen := map[float64]bool{}
+	for _, threshold := range c.Thresholds {
+		if threshold <= 0 || threshold > 1000 {
+			errs = append(errs, fmt.Errorf("budget threshold %g%% must be between 0 and 1000", threshold))
+		}
+		if seen[threshold] {
+			errs = append(errs, fmt.Errorf("budget threshold %g%% listed more than once", threshold))
+		}
+		seen[threshold] = true
+	}
+	if c.AnomalyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("anomaly threshold must not be negative, got %g", c.AnomalyThreshold))
+	}
+	for _, subscriber := range c.Subscribers {
+		if _, err := mail.ParseAddress(subscriber); err != nil {
+			errs = append(errs, fmt.Errorf("budget subscriber %q is not an email address", subscriber))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,32 @@
+package lib_test
+
+import (
+	"slices"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestBudgetConfigDefaultsThresholds(t *testing.T) {
+	config := &lib.BudgetConfig{MonthlyLimit: 10}
+	if !slices.Equal(config.ActualThresholds(), lib.DefaultBudgetThresholds) {
+		t.Fatalf("unexpected thresholds %v", config.ActualThresholds())
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBudgetConfigRejectsInvalidValues(t *testing.T) {
+	for _, config := range []lib.BudgetConfig{
+		{},
+		{MonthlyLimit: 10, Thresholds: []float64{80, 80}},
+		{MonthlyLimit: 10, Thresholds: []float64{20, 40, 60, 80, 100}},
+		{MonthlyLimit: 10, AnomalyThreshold: -1},
+		{MonthlyLimit: 10, Subscribers: []string{"ops"}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", config)
+		}
+	}
+}
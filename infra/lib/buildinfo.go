@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// BuildInfo describes the release and commit a deployment was built from
+type BuildInfo struct {
+	// Version is the semantic version of the release, empty for unreleased builds
+	Version string
+
+	// CommitSHA is the git commit the build was made from
+	CommitSHA string
+
+	// Dirty is true when the working tree had uncommitted changes
+	Dirty bool
+
+	// BuildTime is when the build was made
+	BuildTime time.Time
+}
+
+var (
+	semverPattern    = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// BuildInfoFromContext reads the version, sha, dirty and build_time context values
+func BuildInfoFromContext(app awscdk.App) (BuildInfo, error) {
+	var errs []error
+
+	info := BuildInfo{
+		Version:   ContextString(app, "version"),
+		CommitSHA: ContextString(app, "sha"),
+	}
+
+	if dirty := ContextString(app, "dirty"); dirty != "" {
+		value, err := strconv.ParseBool(dirty)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dirty must be true or false, got %q", dirty))
+		}
+		info.Dirty = value
+	}
+
+	if buildTime := ContextString(app, "build_time"); buildTime != "" {
+		value, err := time.Parse(time.RFC3339, buildTime)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("build_time must be an RFC 3339 timestamp, got %q", buildTime))
+		}
+		info.BuildTime = value.UTC()
+	}
+
+	if err := info.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return info, errors.Join(errs...)
+}
+
+// Validate checks that the version is a semantic version and the commit a git SHA
+func (b BuildInfo) Validate() error {
+	var errs []error
+	if b.Version != "" && !semverPattern.MatchString(b.Version) {
+		errs = append(errs, fmt.Errorf("version %q is not a semantic version", b.Version))
+	}
+	if b.CommitSHA != "" && !commitSHAPattern.MatchString(b.CommitSHA) {
+		errs = append(errs, fmt.Errorf("sha %q is not a git commit SHA", b.CommitSHA))
+	}
+	return errors.Join(errs...)
+}
+
+// values returns the build metadata as strings, leaving out unknown values
+func (b BuildInfo) values() map[string]string {
+	values := map[string]string{}
+	if b.Version != "" {
+		values["version"] = b.Version
+	}
+	if b.CommitSHA != "" {
+		values["commit"] = b.CommitSHA
+		values["dirty"] = strconv.FormatBool(b.Dirty)
+	}
+	if !b.BuildTime.IsZero() {
+		values["time"] = b.BuildTime.Format(time.RFC3339)
+	}
+	return values
+}
+
+// Tags returns the resource tags describing the build
+func (b BuildInfo) Tags() map[string]string {
+	keys := map[string]string{
+		"version": "Version",
+		"commit":  "CommitSha",
+		"dirty":   "Dirty",
+		"time":    "BuildTime",
+	}
+
+	tags := map[string]string{}
+	for name, value := range b.values() {
+		tags[keys[name]] = value
+	}
+	return tags
+}
+
+// EnvironmentVariables returns the build metadata as Lambda environment variables
+func (b BuildInfo) EnvironmentVariables() *map[string]*string {
+	keys := map[string]string{
+		"version": "BUILD_VERSION",
+		"commit":  "BUILD_COMMIT",
+		"dirty":   "BUILD_DIRTY",
+		"time":    "BUILD_TIME",
+	}
+
+	variables := map[string]*string{}
+	for name, value := range b.values() {
+		variables[keys[name]] = jsii.String(value)
+	}
+	return &variables
+}
@@ -0,0 +1,57 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestBuildInfoFromContextKeepsVersionAndCommitSeparate(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"version":    "v1.4.0",
+			"sha":        "0123456789abcdef0123456789abcdef01234567",
+			"dirty":      "true",
+			"build_time": "2026-10-16T08:30:00Z",
+		},
+	})
+
+	info, err := lib.BuildInfoFromContext(app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tags := info.Tags()
+	expected := map[string]string{
+		"Version":   "v1.4.0",
+		"CommitSha": "0123456789abcdef0123456789abcdef01234567",
+		"Dirty":     "true",
+		"BuildTime": "2026-10-16T08:30:00Z",
+	}
+	for key, value := range expected {
+		if tags[key] != value {
+			t.Errorf("tag %s = %q, want %q", key, tags[key], value)
+		}
+	}
+
+	variables := *info.EnvironmentVariables()
+	if *variables["BUILD_VERSION"] != "v1.4.0" || *variables["BUILD_DIRTY"] != "true" {
+		t.Errorf("unexpected environment variables %v", variables)
+	}
+}
+
+func TestBuildInfoFromContextRejectsInvalidValues(t *testing.T) {
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"version":    "latest",
+			"sha":        "not-a-sha",
+			"build_time": "yesterday",
+		},
+	})
+
+	if _, err := lib.BuildInfoFromContext(app); err == nil {
+		t.Fatal("expected an error")
+	}
+}
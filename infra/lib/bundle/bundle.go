@@ -0,0 +1,331 @@
+// Package bundle cross-compiles Go Lambda functions during synthesis and packages them as
+// deterministic zips, cached by a hash of their sources.
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aws-infra-sandbox/lib"
+)
+
+// DefaultCacheDir holds the built zips, relative to the working directory of the app
+const DefaultCacheDir = "build/cache/functions"
+
+// BuildTags are the tags functions are built with, lambda.norpc drops the RPC server that
+// provided runtimes do not use
+var BuildTags = []string{"lambda.norpc"}
+
+// GoArch maps the architectures of function manifests to GOARCH
+var GoArch = map[string]string{
+	lib.ArchitectureArm64:  "arm64",
+	lib.ArchitectureX86_64: "amd64",
+}
+
+// buildFlags keep the binary independent of the build machine, so equal sources give equal zips
+var buildFlags = []string{"-trimpath", "-buildvcs=false", "-tags", strings.Join(BuildTags, ",")}
+
+// zipTime is the modification time of every zip entry, zip cannot store times before 1980
+var zipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Options of a function build
+type Options struct {
+	// Architecture is lib.ArchitectureArm64 or lib.ArchitectureX86_64
+	Architecture string
+
+	// CacheDir defaults to DefaultCacheDir
+	CacheDir string
+}
+
+// Function builds the function in dir as a bootstrap binary for the provided.al2023 runtime and
+// returns the path of its zip. A zip built from the same sources is reused.
+func Function(dir string, options Options) (string, error) {
+	goarch, ok := GoArch[options.Architecture]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture %q", options.Architecture)
+	}
+	cacheDir := options.CacheDir
+	if cacheDir == "" {
+		cacheDir = DefaultCacheDir
+	}
+
+	if err := CheckMainPackage(dir, goarch); err != nil {
+		return "", err
+	}
+	goVersion, err := toolchainVersion()
+	if err != nil {
+		return "", err
+	}
+	hash, err := SourceHash(dir, goarch, goVersion)
+	if err != nil {
+		return "", err
+	}
+
+	zipPath := filepath.Join(cacheDir, fmt.Sprintf("%s-%s.zip", filepath.Base(dir), hash[:16]))
+	if _, err := os.Stat(zipPath); err == nil {
+		return zipPath, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating bundle cache: %w", err)
+	}
+	workDir, err := os.MkdirTemp(cacheDir, ".build-")
+	if err != nil {
+		return "", fmt.Errorf("error creating build directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	binary, err := filepath.Abs(filepath.Join(workDir, "bootstrap"))
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("go", append(append([]string{"build"}, buildFlags...), "-o", binary, ".")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+goarch, "CGO_ENABLED=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("error building %s: %w\n%s", dir, err, output)
+	}
+
+	// Write next to the final path and rename, concurrent builds of the same sources then
+	// leave a complete zip behind
+	tmpZip := filepath.Join(workDir, "bootstrap.zip")
+	if err := writeZip(tmpZip, binary); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpZip, zipPath); err != nil {
+		return "", fmt.Errorf("error caching bundle: %w", err)
+	}
+	return zipPath, nil
+}
+
+// CheckMainPackage reports an error unless dir holds a main package with a main function for
+// linux on goarch
+func CheckMainPackage(dir, goarch string) error {
+	context := build.Default
+	context.GOOS = "linux"
+	context.GOARCH = goarch
+	context.CgoEnabled = false
+	context.BuildTags = BuildTags
+
+	pkg, err := context.ImportDir(dir, 0)
+	var noGo *build.NoGoError
+	if errors.As(err, &noGo) {
+		return fmt.Errorf("function %s has no Go files", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading function %s: %w", dir, err)
+	}
+	if pkg.Name != "main" {
+		return fmt.Errorf("function %s is package %s, functions need a main package", dir, pkg.Name)
+	}
+
+	fset := token.NewFileSet()
+	for _, file := range pkg.GoFiles {
+		parsed, err := parser.ParseFile(fset, filepath.Join(dir, file), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return fmt.Errorf("error parsing function %s: %w", dir, err)
+		}
+		for _, decl := range parsed.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("function %s has no main function", dir)
+}
+
+// SourceHash hashes everything that decides the binary of a function: its files apart from
+// tests and the manifest, those of the local modules its go.mod replaces dependencies with, the
+// target architecture, the build flags and the Go version
+func SourceHash(dir, goarch, goVersion string) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", goarch, goVersion, strings.Join(buildFlags, " "))
+	if err := hashModule(hash, dir, map[string]bool{}); err != nil {
+		return "", fmt.Errorf("error reading function %s: %w", dir, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashModule writes the source files of the module in dir to hash, followed by the local
+// modules it replaces dependencies with. Modules in seen are skipped.
+func hashModule(hash io.Writer, dir string, seen map[string]bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if seen[abs] {
+		return nil
+	}
+	seen[abs] = true
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && !strings.HasSuffix(name, "_test.go") && name != "function.yaml" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.Sort(files)
+
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00", filepath.ToSlash(rel))
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		hash.Write([]byte{0})
+	}
+
+	replaces, err := localReplaces(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+	for _, target := range replaces {
+		fmt.Fprintf(hash, "replace %s\x00", target)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if err := hashModule(hash, target, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localReplaces returns the directories the go.mod at path replaces modules with, in the order
+// they are declared. A missing go.mod replaces nothing.
+func localReplaces(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case line == "replace (":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "replace "):
+			line = strings.TrimPrefix(line, "replace ")
+		case !inBlock:
+			continue
+		}
+
+		_, replacement, ok := strings.Cut(line, "=>")
+		fields := strings.Fields(replacement)
+		if !ok || len(fields) != 1 {
+			// A module path with a version is fetched, not read from disk
+			continue
+		}
+		target := fields[0]
+		if unquoted, err := strconv.Unquote(target); err == nil {
+			target = unquoted
+		}
+		// Like the go command, only paths starting with ./, ../ or / are local directories
+		if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") || filepath.IsAbs(target) {
+			targets = append(targets, filepath.FromSlash(target))
+		}
+	}
+	return targets, nil
+}
+
+var toolchain struct {
+	once    sync.Once
+	version string
+	err     error
+}
+
+// toolchainVersion returns the version of the go command, which builds every function
+func toolchainVersion() (string, error) {
+	toolchain.once.Do(func() {
+		output, err := exec.Command("go", "env", "GOVERSION").Output()
+		if err != nil {
+			toolchain.err = fmt.Errorf("functions are built with the go command: %w", err)
+			return
+		}
+		toolchain.version = strings.TrimSpace(string(output))
+	})
+	return toolchain.version, toolchain.err
+}
+
+// writeZip writes a zip holding the binary as an executable bootstrap, with fixed times and
+// permissions so equal binaries give equal zips
+func writeZip(path, binary string) error {
+	data, err := os.ReadFile(binary)
+	if err != nil {
+		return fmt.Errorf("error reading function binary: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating function zip: %w", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	header := &zip.FileHeader{
+		Name:     "bootstrap",
+		Method:   zip.Deflate,
+		Modified: zipTime,
+	}
+	header.SetMode(0o755)
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("error writing function zip: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("error writing function zip: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing function zip: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,168 @@
+package bundle_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/bundle"
+)
+
+// writeFunction creates a function module with the files by name
+func writeFunction(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "hello")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files["go.mod"] = "module hello\n\ngo 1.23\n"
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFunctionBuildsDeterministicZips(t *testing.T) {
+	dir := writeFunction(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	first, err := bundle.Function(dir, bundle.Options{Architecture: lib.ArchitectureArm64, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(first), "hello-") {
+		t.Errorf("unexpected zip name %s", first)
+	}
+
+	archive, err := zip.OpenReader(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if len(archive.File) != 1 || archive.File[0].Name != "bootstrap" || archive.File[0].Mode().Perm() != 0o755 {
+		t.Fatalf("expected an executable bootstrap, got %v", archive.File)
+	}
+
+	// A build in another cache gives the same bytes, a build in the same cache reuses the zip
+	second, err := bundle.Function(dir, bundle.Options{Architecture: lib.ArchitectureArm64, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	firstData, _ := os.ReadFile(first)
+	secondData, _ := os.ReadFile(second)
+	if !bytes.Equal(firstData, secondData) {
+		t.Error("expected equal sources to give equal zips")
+	}
+	cached, err := bundle.Function(dir, bundle.Options{Architecture: lib.ArchitectureArm64, CacheDir: filepath.Dir(first)})
+	if err != nil || cached != first {
+		t.Errorf("expected the cached zip %s, got %s (%v)", first, cached, err)
+	}
+}
+
+func TestFunctionReportsBuildErrors(t *testing.T) {
+	dir := writeFunction(t, map[string]string{
+		"main.go": "package main\n\nfunc main() { undefined() }\n",
+	})
+	_, err := bundle.Function(dir, bundle.Options{Architecture: lib.ArchitectureX86_64, CacheDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "undefined") {
+		t.Fatalf("expected the compiler error, got %v", err)
+	}
+}
+
+func TestCheckMainPackage(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"library":      {"lib.go": "package hello\n"},
+		"no main func": {"main.go": "package main\n\nfunc run() {}\n"},
+		"no go files":  {"README.md": "# hello\n"},
+		"other os":     {"main.go": "//go:build windows\n\npackage main\n\nfunc main() {}\n"},
+	} {
+		if err := bundle.CheckMainPackage(writeFunction(t, files), "arm64"); err == nil {
+			t.Errorf("expected %s function to be rejected", name)
+		}
+	}
+
+	dir := writeFunction(t, map[string]string{
+		"main.go":      "package main\n\nfunc main() {}\n",
+		"main_test.go": "package main_test\n",
+	})
+	if err := bundle.CheckMainPackage(dir, "arm64"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	dir := writeFunction(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+	hash := func(goarch string) string {
+		t.Helper()
+		h, err := bundle.SourceHash(dir, goarch, "go1.24.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	before := hash("arm64")
+	if hash("amd64") == before {
+		t.Error("expected the architecture to change the hash")
+	}
+
+	// Tests and the manifest do not change the binary
+	for _, name := range []string{"main_test.go", lib.FunctionManifestFile} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hash("arm64") != before {
+		t.Error("expected tests and the manifest not to change the hash")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "util.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if hash("arm64") == before {
+		t.Error("expected a new source file to change the hash")
+	}
+}
+
+func TestSourceHashCoversLocalReplacements(t *testing.T) {
+	dir := t.TempDir()
+	function := filepath.Join(dir, "function")
+	shared := filepath.Join(dir, "shared")
+	for path, content := range map[string]string{
+		filepath.Join(function, "main.go"): "package main\n\nfunc main() {}\n",
+		filepath.Join(function, "go.mod"):  "module function\n\nrequire shared v0.0.0\n\nreplace (\n\tshared => ../shared // local\n)\n",
+		filepath.Join(shared, "go.mod"):    "module shared\n",
+		filepath.Join(shared, "shared.go"): "package shared\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := bundle.SourceHash(function, "arm64", "go1.24.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	before := hash()
+
+	if err := os.WriteFile(filepath.Join(shared, "shared.go"), []byte("package shared\n\nconst Name = \"shared\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if hash() == before {
+		t.Error("expected a change of the replaced module to change the hash")
+	}
+}
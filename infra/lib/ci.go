@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CIMetadata describes the GitHub Actions run the app is synthesized in
+type CIMetadata struct {
+	// EventName is the event that triggered the workflow, e.g. pull_request or push
+	EventName string
+
+	// Ref is the full ref of the run, e.g. refs/pull/42/merge or refs/heads/main
+	Ref string
+
+	// HeadRef is the source branch of a pull request
+	HeadRef string
+
+	// PRNumber is the pull request number for pull request events
+	PRNumber string
+	// prNumberSource is the variable the PR number was read from
+	prNumberSource string
+
+	// Actor is the user that triggered the workflow
+	Actor string
+
+	// SHA is the commit the workflow runs on
+	SHA string
+}
+
+// ReleaseBranches are the branches whose pushes deploy the staging environment
+var ReleaseBranches = []string{"main", "master"}
+
+var pullRequestRefPattern = regexp.MustCompile(`^refs/pull/([0-9]+)/(merge|head)$`)
+
+// CIMetadataFromEnv reads the GitHub Actions variables, it returns false outside of GitHub Actions
+func CIMetadataFromEnv() (CIMetadata, bool) {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return CIMetadata{}, false
+	}
+
+	ci := CIMetadata{
+		EventName: os.Getenv("GITHUB_EVENT_NAME"),
+		Ref:       os.Getenv("GITHUB_REF"),
+		HeadRef:   os.Getenv("GITHUB_HEAD_REF"),
+		Actor:     os.Getenv("GITHUB_ACTOR"),
+		SHA:       os.Getenv("GITHUB_SHA"),
+	}
+
+	// The PR number is part of the ref of pull request runs, the event payload
+	// has it for events such as pull_request_target that run on the base branch
+	if match := pullRequestRefPattern.FindStringSubmatch(ci.Ref); match != nil {
+		ci.PRNumber, ci.prNumberSource = match[1], "GITHUB_REF="+ci.Ref
+	} else if number := prNumberFromEvent(os.Getenv("GITHUB_EVENT_PATH")); number != "" {
+		ci.PRNumber, ci.prNumberSource = number, "GITHUB_EVENT_PATH"
+	}
+	return ci, true
+}
+
+// prNumberFromEvent reads the pull request number from the webhook payload of the run
+func prNumberFromEvent(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var event struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil || event.PullRequest == nil || event.PullRequest.Number == 0 {
+		return ""
+	}
+	return fmt.Sprint(event.PullRequest.Number)
+}
+
+// IsPullRequest reports whether the run was triggered by a pull request, GitHub
+// only sets the head ref for pull request events
+func (c CIMetadata) IsPullRequest() bool {
+	return strings.HasPrefix(c.EventName, "pull_request") || c.HeadRef != ""
+}
+
+// Environment infers the environment to deploy and describes where it was inferred from:
+// pull requests deploy pr, version tags and releases production and release branches
+// staging. It returns an empty name when the run does not match any of them.
+func (c CIMetadata) Environment() (name, source string) {
+	switch {
+	case c.IsPullRequest():
+		return string(KindPR), "GITHUB_EVENT_NAME=" + c.EventName
+	case c.EventName == "release":
+		return string(KindProduction), "GITHUB_EVENT_NAME=" + c.EventName
+	case semverPattern.MatchString(c.Version()):
+		return string(KindProduction), "GITHUB_REF=" + c.Ref
+	}
+
+	for _, branch := range ReleaseBranches {
+		if c.Ref == "refs/heads/"+branch {
+			return string(KindStaging), "GITHUB_REF=" + c.Ref
+		}
+	}
+	return "", ""
+}
+
+// Version returns the tag name of tag runs, e.g. v1.2.0 for refs/tags/v1.2.0
+func (c CIMetadata) Version() string {
+	if !strings.HasPrefix(c.Ref, "refs/tags/") {
+		return ""
+	}
+	return strings.TrimPrefix(c.Ref, "refs/tags/")
+}
@@ -0,0 +1,129 @@
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+
+	"aws-infra-sandbox/lib"
+)
+
+const testCommitSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// setGitHubEnv simulates a GitHub Actions run with the given event and ref
+func setGitHubEnv(t *testing.T, event, ref, headRef string) {
+	t.Helper()
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_EVENT_NAME", event)
+	t.Setenv("GITHUB_REF", ref)
+	t.Setenv("GITHUB_HEAD_REF", headRef)
+	t.Setenv("GITHUB_ACTOR", "octocat")
+	t.Setenv("GITHUB_SHA", testCommitSHA)
+	t.Setenv("GITHUB_EVENT_PATH", "")
+}
+
+func TestGetEnvironmentInfersPullRequests(t *testing.T) {
+	setGitHubEnv(t, "pull_request", "refs/pull/42/merge", "feature/login")
+
+	env, err := lib.GetEnvironmentFromContext(awscdk.NewApp(nil), mustParseProfiles(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Name != "pr" || env.PRNumber != "42" {
+		t.Fatalf("expected pr environment 42, got %q %q", env.Name, env.PRNumber)
+	}
+	if env.Username != "octocat" || env.Build.CommitSHA != testCommitSHA {
+		t.Fatalf("expected the actor and commit from CI, got %q %q", env.Username, env.Build.CommitSHA)
+	}
+
+	expected := map[string]string{
+		"environment": "GITHUB_EVENT_NAME=pull_request",
+		"pr_number":   "GITHUB_REF=refs/pull/42/merge",
+		"username":    "GITHUB_ACTOR",
+		"sha":         "GITHUB_SHA",
+	}
+	for key, source := range expected {
+		if env.Sources[key] != source {
+			t.Fatalf("expected %s from %q, got %q", key, source, env.Sources[key])
+		}
+	}
+}
+
+func TestGetEnvironmentReadsPRNumberFromEventPayload(t *testing.T) {
+	setGitHubEnv(t, "pull_request_target", "refs/heads/main", "feature/login")
+
+	path := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(path, []byte(`{"pull_request": {"number": 7}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_EVENT_PATH", path)
+
+	env, err := lib.GetEnvironmentFromContext(awscdk.NewApp(nil), mustParseProfiles(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Name != "pr" || env.PRNumber != "7" || env.Sources["pr_number"] != "GITHUB_EVENT_PATH" {
+		t.Fatalf("expected pr environment 7 from the event payload, got %q %q from %q", env.Name, env.PRNumber, env.Sources["pr_number"])
+	}
+}
+
+func TestGetEnvironmentInfersStagingAndProduction(t *testing.T) {
+	tests := []struct {
+		event, ref      string
+		environment     string
+		expectedVersion string
+	}{
+		{"push", "refs/heads/main", "staging", ""},
+		{"workflow_dispatch", "refs/heads/master", "staging", ""},
+		{"push", "refs/tags/v1.2.0", "production", "v1.2.0"},
+		{"release", "refs/tags/v2.0.0-rc.1", "production", "v2.0.0-rc.1"},
+	}
+	for _, tt := range tests {
+		setGitHubEnv(t, tt.event, tt.ref, "")
+
+		env, err := lib.GetEnvironmentFromContext(awscdk.NewApp(nil), mustParseProfiles(t))
+		if err != nil {
+			t.Fatalf("%s %s: unexpected error: %v", tt.event, tt.ref, err)
+		}
+		if env.Name != tt.environment || env.Build.Version != tt.expectedVersion {
+			t.Fatalf("%s %s: expected %s %q, got %s %q", tt.event, tt.ref, tt.environment, tt.expectedVersion, env.Name, env.Build.Version)
+		}
+	}
+}
+
+func TestGetEnvironmentPrefersContextOverCI(t *testing.T) {
+	setGitHubEnv(t, "pull_request", "refs/pull/42/merge", "feature/login")
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "staging",
+			"username":    "deployer",
+		},
+	})
+
+	env, err := lib.GetEnvironmentFromContext(app, mustParseProfiles(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Name != "staging" || env.Sources["environment"] != "context" {
+		t.Fatalf("expected staging from context, got %q from %q", env.Name, env.Sources["environment"])
+	}
+	if env.Username != "deployer" || env.Sources["username"] != "context" {
+		t.Fatalf("expected username from context, got %q from %q", env.Username, env.Sources["username"])
+	}
+}
+
+func TestGetEnvironmentDefaultsToDevelopmentOutsideCI(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITHUB_EVENT_NAME", "pull_request")
+
+	env, err := lib.GetEnvironmentFromContext(awscdk.NewApp(nil), mustParseProfiles(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Name != "development" || env.Sources["environment"] != "default" {
+		t.Fatalf("expected the default development environment, got %q from %q", env.Name, env.Sources["environment"])
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GitHubOIDCProviderURL is the issuer of the tokens GitHub Actions exchanges for AWS credentials
+const GitHubOIDCProviderURL = "https://token.actions.githubusercontent.com"
+
+// GitHubOIDCAudience is the audience aws-actions/configure-aws-credentials requests tokens for
+const GitHubOIDCAudience = "sts.amazonaws.com"
+
+var (
+	githubRepositoryPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+	gitRefPattern           = regexp.MustCompile(`^[A-Za-z0-9._/*-]+$`)
+)
+
+// DeployTrust decides which GitHub Actions workflow runs may assume the deploy role of an
+// environment. Branches and tags may contain * wildcards, e.g. feature/* or v*.
+type DeployTrust struct {
+	// PullRequests trusts workflows triggered by pull requests
+	PullRequests bool `json:"pullRequests,omitempty" yaml:"pullRequests,omitempty"`
+
+	// Branches trusts workflows running on these branches
+	Branches []string `json:"branches,omitempty" yaml:"branches,omitempty"`
+
+	// Tags trusts workflows running on these tags
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// GitHubEnvironments trusts jobs running in these GitHub deployment environments, jobs
+	// with an environment are identified by it instead of their branch or tag
+	GitHubEnvironments []string `json:"githubEnvironments,omitempty" yaml:"githubEnvironments,omitempty"`
+}
+
+// Subjects returns the sub claims of the trusted workflow runs of repository
+func (t *DeployTrust) Subjects(repository string) []string {
+	prefix := "repo:" + repository + ":"
+
+	var subjects []string
+	if t.PullRequests {
+		subjects = append(subjects, prefix+"pull_request")
+	}
+	for _, branch := range t.Branches {
+		subjects = append(subjects, prefix+"ref:refs/heads/"+branch)
+	}
+	for _, tag := range t.Tags {
+		subjects = append(subjects, prefix+"ref:refs/tags/"+tag)
+	}
+	for _, environment := range t.GitHubEnvironments {
+		subjects = append(subjects, prefix+"environment:"+environment)
+	}
+	return subjects
+}
+
+// Validate checks that at least one workflow run is trusted and that the refs are well-formed
+func (t *DeployTrust) Validate() error {
+	if !t.PullRequests && len(t.Branches) == 0 && len(t.Tags) == 0 && len(t.GitHubEnvironments) == 0 {
+		return errors.New("deploy trusts no workflow runs, set pullRequests, branches, tags or githubEnvironments")
+	}
+
+	var errs []error
+	for _, ref := range append(append([]string{}, t.Branches...), t.Tags...) {
+		if !gitRefPattern.MatchString(ref) || strings.Contains(ref, "..") {
+			errs = append(errs, fmt.Errorf("deploy ref %q is not a valid branch or tag pattern", ref))
+		}
+	}
+	for _, environment := range t.GitHubEnvironments {
+		if strings.TrimSpace(environment) == "" || strings.ContainsAny(environment, ":*") {
+			errs = append(errs, fmt.Errorf("deploy GitHub environment %q must not be empty or contain : or *", environment))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateGitHubRepository checks that repository is given as owner/name
+func ValidateGitHubRepository(repository string) error {
+	if !githubRepositoryPattern.MatchString(repository) {
+		return fmt.Errorf("GitHub repository %q must be given as owner/name", repository)
+	}
+	return nil
+}
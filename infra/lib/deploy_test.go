@@ -0,0 +1,55 @@
+package lib_test
+
+import (
+	"slices"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestDeployTrustSubjects(t *testing.T) {
+	trust := &lib.DeployTrust{
+		PullRequests:       true,
+		Branches:           []string{"main", "feature/*"},
+		Tags:               []string{"v*"},
+		GitHubEnvironments: []string{"production"},
+	}
+
+	want := []string{
+		"repo:ebbo/aws-infra-sandbox:pull_request",
+		"repo:ebbo/aws-infra-sandbox:ref:refs/heads/main",
+		"repo:ebbo/aws-infra-sandbox:ref:refs/heads/feature/*",
+		"repo:ebbo/aws-infra-sandbox:ref:refs/tags/v*",
+		"repo:ebbo/aws-infra-sandbox:environment:production",
+	}
+	if got := trust.Subjects("ebbo/aws-infra-sandbox"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected subjects %v", got)
+	}
+	if err := trust.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDeployTrustRejectsInvalidValues(t *testing.T) {
+	for _, trust := range []lib.DeployTrust{
+		{},
+		{Branches: []string{"main branch"}},
+		{Tags: []string{"v1..2"}},
+		{GitHubEnvironments: []string{"prod:*"}},
+	} {
+		if err := trust.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", trust)
+		}
+	}
+}
+
+func TestValidateGitHubRepository(t *testing.T) {
+	if err := lib.ValidateGitHubRepository("ebbo/aws-infra-sandbox"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, repository := range []string{"", "aws-infra-sandbox", "ebbo/aws/infra", "ebbo/*"} {
+		if err := lib.ValidateGitHubRepository(repository); err == nil {
+			t.Errorf("expected %q to be rejected", repository)
+		}
+	}
+}
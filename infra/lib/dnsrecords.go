@@ -0,0 +1,310 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// DNSRecordType is a record type that can be declared in DomainConfig
+type DNSRecordType string
+
+const (
+	DNSRecordA    DNSRecordType = "A"
+	DNSRecordAAAA DNSRecordType = "AAAA"
+	DNSRecordCAA  DNSRecordType = "CAA"
+	DNSRecordMX   DNSRecordType = "MX"
+	DNSRecordTXT  DNSRecordType = "TXT"
+)
+
+// DefaultDNSRecordTTL is used for declared records without a TTL, in seconds
+const DefaultDNSRecordTTL = 300
+
+// AmazonCAAIssuers are the certificate authorities of ACM
+var AmazonCAAIssuers = []string{"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"}
+
+// DNSRecord is a record created in the environment's zone by CoreStack
+type DNSRecord struct {
+	// Name relative to the environment domain, empty for the environment domain itself
+	Name string
+
+	Type DNSRecordType
+
+	// Values in zone file notation, e.g. `10 inbound-smtp.eu-central-1.amazonaws.com` for MX.
+	// TXT values are given without quotes.
+	Values []string
+
+	// TTL in seconds, defaults to DefaultDNSRecordTTL
+	TTL int
+}
+
+// CAARecord only allows Amazon and the given extra issuers to issue certificates for the name
+func CAARecord(name string, extraIssuers ...string) DNSRecord {
+	var values []string
+	for _, issuer := range append(append([]string(nil), AmazonCAAIssuers...), extraIssuers...) {
+		values = append(values, fmt.Sprintf(`0 issue "%s"`, issuer), fmt.Sprintf(`0 issuewild "%s"`, issuer))
+	}
+	return DNSRecord{Name: name, Type: DNSRecordCAA, Values: values}
+}
+
+// SPFRecord declares the senders of mail for the name, no mechanisms forbid sending mail at all
+func SPFRecord(name string, mechanisms ...string) DNSRecord {
+	return DNSRecord{Name: name, Type: DNSRecordTXT, Values: []string{strings.Join(append(append([]string{"v=spf1"}, mechanisms...), "-all"), " ")}}
+}
+
+// DMARCRecord publishes the DMARC policy of the name, reports are mailed to reportAddress if set
+func DMARCRecord(name, policy, reportAddress string) DNSRecord {
+	value := "v=DMARC1; p=" + policy
+	if reportAddress != "" {
+		value += "; rua=mailto:" + reportAddress
+	}
+	return DNSRecord{Name: joinLabels("_dmarc", name), Type: DNSRecordTXT, Values: []string{value}}
+}
+
+// MXRecord routes mail for the name to host
+func MXRecord(name string, priority int, host string) DNSRecord {
+	return DNSRecord{Name: name, Type: DNSRecordMX, Values: []string{fmt.Sprintf("%d %s", priority, host)}}
+}
+
+// VerificationRecord publishes a TXT token that proves ownership of the name to a third party
+func VerificationRecord(name, token string) DNSRecord {
+	return DNSRecord{Name: name, Type: DNSRecordTXT, Values: []string{token}}
+}
+
+// FQDN returns the fully qualified name of the record below domain
+func (r DNSRecord) FQDN(domain string) string {
+	return joinLabels(r.Name, domain)
+}
+
+// ZoneValues returns the values as Route 53 expects them, TXT values are quoted and split
+// into strings of at most 255 characters
+func (r DNSRecord) ZoneValues() []string {
+	if r.Type != DNSRecordTXT {
+		return r.Values
+	}
+
+	values := make([]string, 0, len(r.Values))
+	for _, value := range r.Values {
+		var chunks []string
+		for len(value) > 255 {
+			chunks = append(chunks, value[:255])
+			value = value[255:]
+		}
+		chunks = append(chunks, value)
+
+		quoted := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			quoted[i] = fmt.Sprintf("%q", chunk)
+		}
+		values = append(values, strings.Join(quoted, " "))
+	}
+	return values
+}
+
+var (
+	recordNamePattern = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9])?)(\.[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9])?)*$`)
+	caaValuePattern   = regexp.MustCompile(`^(0|128) (issue|issuewild|iodef) "[^"]*"$`)
+	mxValuePattern    = regexp.MustCompile(`^[0-9]{1,5} [a-z0-9.-]+$`)
+)
+
+// Validate checks the name and the values of the record
+func (r DNSRecord) Validate() error {
+	var errs []error
+	if r.Name != "" && !recordNamePattern.MatchString(r.Name) {
+		errs = append(errs, fmt.Errorf("record name %q must be a lowercase relative DNS name", r.Name))
+	}
+	if len(r.Values) == 0 {
+		errs = append(errs, fmt.Errorf("%s record %q has no values", r.Type, r.Name))
+	}
+	if r.TTL < 0 {
+		errs = append(errs, fmt.Errorf("%s record %q has a negative TTL", r.Type, r.Name))
+	}
+
+	var pattern *regexp.Regexp
+	switch r.Type {
+	case DNSRecordA, DNSRecordAAAA, DNSRecordTXT:
+	case DNSRecordCAA:
+		pattern = caaValuePattern
+	case DNSRecordMX:
+		pattern = mxValuePattern
+	default:
+		errs = append(errs, fmt.Errorf("record %q has unsupported type %q", r.Name, r.Type))
+	}
+	if r.Type == DNSRecordTXT && (r.Name == DNSOwnershipPrefix || strings.HasPrefix(r.Name, DNSOwnershipPrefix+".")) {
+		errs = append(errs, fmt.Errorf("TXT record %q uses the %s label reserved for ownership records", r.Name, DNSOwnershipPrefix))
+	}
+	for _, value := range r.Values {
+		if pattern != nil && !pattern.MatchString(value) {
+			errs = append(errs, fmt.Errorf("%s record %q has malformed value %q", r.Type, r.Name, value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MergeDNSRecords combines records with the same name and type into one record set, as
+// Route 53 holds a single set per name and type. The sets are ordered by name and type.
+func MergeDNSRecords(records []DNSRecord) ([]DNSRecord, error) {
+	type key struct {
+		name       string
+		recordType DNSRecordType
+	}
+	merged := map[key]*DNSRecord{}
+	var keys []key
+
+	for _, record := range records {
+		ttl := record.TTL
+		if ttl == 0 {
+			ttl = DefaultDNSRecordTTL
+		}
+
+		k := key{record.Name, record.Type}
+		set, ok := merged[k]
+		if !ok {
+			set = &DNSRecord{Name: record.Name, Type: record.Type, TTL: ttl}
+			merged[k] = set
+			keys = append(keys, k)
+		}
+		if set.TTL != ttl {
+			return nil, fmt.Errorf("%s records %q declare different TTLs %d and %d", record.Type, record.Name, set.TTL, ttl)
+		}
+		for _, value := range record.Values {
+			if !slices.Contains(set.Values, value) {
+				set.Values = append(set.Values, value)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].recordType < keys[j].recordType
+	})
+	sets := make([]DNSRecord, 0, len(keys))
+	for _, k := range keys {
+		sets = append(sets, *merged[k])
+	}
+	return sets, nil
+}
+
+// DNSOwnershipPrefix is the label of the TXT records naming the owner of a managed record
+const DNSOwnershipPrefix = "_owner"
+
+// DNSOwnershipValue is the value of the ownership record of records created by a stack.
+// Route 53 records cannot be tagged, so ownership is published next to them instead.
+func DNSOwnershipValue(env Environment, stackName string) string {
+	return fmt.Sprintf("managed-by=cdk,environment=%s,stack=%s", env.Name, stackName)
+}
+
+// DNSRecordClaims tracks the record sets of an app and reports names claimed more than once
+type DNSRecordClaims struct {
+	mu        sync.Mutex
+	owners    map[string][]string
+	conflicts []string
+}
+
+// NewDNSRecordClaims returns an empty set of claims
+func NewDNSRecordClaims() *DNSRecordClaims {
+	return &DNSRecordClaims{owners: map[string][]string{}}
+}
+
+// Apply registers an aspect that claims every record set below scope at synth time, record sets
+// conflicting with an earlier claim fail synthesis with an error annotation
+func (c *DNSRecordClaims) Apply(scope constructs.IConstruct) {
+	awscdk.Aspects_Of(scope).Add(&dnsRecordClaimsAspect{claims: c}, &awscdk.AspectOptions{
+		Priority: awscdk.AspectPriority_READONLY(),
+	})
+}
+
+// Claim records that the construct at path owns the record set with the given name and type.
+// Record sets of a routing policy share a name and type and are told apart by setIdentifier,
+// which is empty for simple records. A CNAME conflicts with every other type of the same name.
+// Claim returns the conflicts of this claim.
+func (c *DNSRecordClaims) Claim(name string, recordType string, setIdentifier string, path string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	key := name + " " + recordType + " " + setIdentifier
+	var conflicts []string
+	for existing, owners := range c.owners {
+		fields := strings.SplitN(existing, " ", 3)
+		existingName, existingType, existingIdentifier := fields[0], fields[1], fields[2]
+		if existingName != name {
+			continue
+		}
+		sameSet := existingType == recordType && (existingIdentifier == setIdentifier || existingIdentifier == "" || setIdentifier == "")
+		if sameSet || existingType == "CNAME" || recordType == "CNAME" {
+			for _, owner := range owners {
+				// Synthesizing again visits the same record sets again
+				if owner == path {
+					continue
+				}
+				conflicts = append(conflicts, fmt.Sprintf("%s %s of %s conflicts with %s %s of %s", name, recordType, path, name, existingType, owner))
+			}
+		}
+	}
+	if !slices.Contains(c.owners[key], path) {
+		c.owners[key] = append(c.owners[key], path)
+	}
+	c.conflicts = append(c.conflicts, conflicts...)
+	return conflicts
+}
+
+// Err returns the conflicting claims found during synthesis
+func (c *DNSRecordClaims) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.conflicts) == 0 {
+		return nil
+	}
+	conflicts := append([]string(nil), c.conflicts...)
+	sort.Strings(conflicts)
+	return fmt.Errorf("conflicting DNS records:\n  %s", strings.Join(conflicts, "\n  "))
+}
+
+// dnsRecordClaimsAspect claims the record sets it visits
+type dnsRecordClaimsAspect struct {
+	claims *DNSRecordClaims
+}
+
+func (a *dnsRecordClaimsAspect) Visit(node constructs.IConstruct) {
+	recordSet, ok := node.(awsroute53.CfnRecordSet)
+	if !ok {
+		return
+	}
+
+	// Names only known at deploy time resolve to intrinsic functions and cannot be compared
+	stack := awscdk.Stack_Of(node)
+	name, ok := stack.Resolve(recordSet.Name()).(string)
+	if !ok {
+		return
+	}
+	var setIdentifier string
+	if recordSet.SetIdentifier() != nil {
+		setIdentifier = *recordSet.SetIdentifier()
+	}
+	for _, conflict := range a.claims.Claim(name, *recordSet.Type(), setIdentifier, *node.Node().Path()) {
+		awscdk.Annotations_Of(node).AddError(jsii.String("conflicting DNS record: " + conflict))
+	}
+}
+
+func joinLabels(labels ...string) string {
+	var parts []string
+	for _, label := range labels {
+		if label != "" {
+			parts = append(parts, label)
+		}
+	}
+	return strings.Join(parts, ".")
+}
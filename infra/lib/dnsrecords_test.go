@@ -0,0 +1,149 @@
+package lib_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestDNSRecordHelpers(t *testing.T) {
+	if got := lib.SPFRecord("", "include:amazonses.com").Values; !reflect.DeepEqual(got, []string{"v=spf1 include:amazonses.com -all"}) {
+		t.Fatalf("unexpected SPF values %v", got)
+	}
+	dmarc := lib.DMARCRecord("mail", "quarantine", "dmarc@ebbo.dev")
+	if dmarc.Name != "_dmarc.mail" || dmarc.Values[0] != "v=DMARC1; p=quarantine; rua=mailto:dmarc@ebbo.dev" {
+		t.Fatalf("unexpected DMARC record %+v", dmarc)
+	}
+	if got := lib.MXRecord("", 10, "inbound-smtp.eu-central-1.amazonaws.com").Values[0]; got != "10 inbound-smtp.eu-central-1.amazonaws.com" {
+		t.Fatalf("unexpected MX value %q", got)
+	}
+	for _, value := range lib.CAARecord("").Values {
+		if !strings.Contains(value, "amazon") && !strings.Contains(value, "awstrust") {
+			t.Fatalf("CAA record allows a non-Amazon issuer: %s", value)
+		}
+	}
+
+	long := lib.VerificationRecord("", strings.Repeat("a", 300))
+	if got := long.ZoneValues()[0]; got != `"`+strings.Repeat("a", 255)+`" "`+strings.Repeat("a", 45)+`"` {
+		t.Fatalf("expected long TXT values to be split, got %s", got)
+	}
+}
+
+func TestMergeDNSRecordsCombinesSets(t *testing.T) {
+	records, err := lib.MergeDNSRecords([]lib.DNSRecord{
+		lib.SPFRecord(""),
+		lib.VerificationRecord("", "google-site-verification=abc"),
+		lib.MXRecord("", 10, "mx1.example.com"),
+		lib.MXRecord("", 20, "mx2.example.com"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected one MX and one TXT set, got %+v", records)
+	}
+	if records[1].Type != lib.DNSRecordTXT || len(records[1].Values) != 2 || records[1].TTL != lib.DefaultDNSRecordTTL {
+		t.Fatalf("unexpected TXT set %+v", records[1])
+	}
+
+	_, err = lib.MergeDNSRecords([]lib.DNSRecord{
+		{Type: lib.DNSRecordA, Values: []string{"192.0.2.1"}, TTL: 60},
+		{Type: lib.DNSRecordA, Values: []string{"192.0.2.2"}, TTL: 3600},
+	})
+	if err == nil {
+		t.Fatal("expected records of one set with different TTLs to be rejected")
+	}
+}
+
+func TestDomainConfigRejectsMalformedRecords(t *testing.T) {
+	cases := map[string]lib.DNSRecord{
+		"upper case name": lib.VerificationRecord("WWW", "token"),
+		"no values":       {Name: "www", Type: lib.DNSRecordA},
+		"bad CAA":         {Type: lib.DNSRecordCAA, Values: []string{"issue amazon.com"}},
+		"bad MX":          {Type: lib.DNSRecordMX, Values: []string{"mx.example.com"}},
+		"unsupported":     {Type: "CNAME", Values: []string{"example.com"}},
+		"ownership label": lib.VerificationRecord("_owner", "token"),
+	}
+
+	for name, record := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := lib.DefaultDomainConfig()
+			config.Records = []lib.DNSRecord{record}
+			if err := config.Validate(lib.Environment{Name: "staging", Kind: lib.KindStaging}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDNSRecordClaimsDetectConflictsAcrossStacks(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	claims := lib.NewDNSRecordClaims()
+	claims.Apply(app)
+
+	zone := func(stack awscdk.Stack) awsroute53.IHostedZone {
+		return awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("Zone"), &awsroute53.HostedZoneAttributes{
+			HostedZoneId: jsii.String("Z123"),
+			ZoneName:     jsii.String("ebbo.dev"),
+		})
+	}
+	first := awscdk.NewStack(app, jsii.String("First"), nil)
+	second := awscdk.NewStack(app, jsii.String("Second"), nil)
+	firstZone, secondZone := zone(first), zone(second)
+
+	// WHEN - both stacks claim api.ebbo.dev, the TXT record of the same name is no conflict
+	awsroute53.NewARecord(first, jsii.String("Api"), &awsroute53.ARecordProps{
+		Zone:       firstZone,
+		RecordName: jsii.String("api"),
+		Target:     awsroute53.RecordTarget_FromIpAddresses(jsii.String("192.0.2.1")),
+	})
+	awsroute53.NewARecord(second, jsii.String("Api"), &awsroute53.ARecordProps{
+		Zone:       secondZone,
+		RecordName: jsii.String("api.ebbo.dev"),
+		Target:     awsroute53.RecordTarget_FromIpAddresses(jsii.String("192.0.2.2")),
+	})
+	awsroute53.NewTxtRecord(second, jsii.String("Verification"), &awsroute53.TxtRecordProps{
+		Zone:       secondZone,
+		RecordName: jsii.String("api"),
+		Values:     jsii.Strings("token"),
+	})
+	app.Synth(nil)
+
+	// THEN
+	err := claims.Err()
+	if err == nil {
+		t.Fatal("expected a conflict")
+	}
+	if !strings.Contains(err.Error(), "api.ebbo.dev A of Second/Api") || strings.Contains(err.Error(), "TXT") {
+		t.Fatalf("unexpected conflicts: %v", err)
+	}
+	assertions.Annotations_FromStack(second).HasError(jsii.String("/Second/Api/Resource"),
+		assertions.Match_StringLikeRegexp(jsii.String("conflicting DNS record: api.ebbo.dev A of Second/Api")))
+}
+
+func TestDNSRecordClaimsAllowFailoverPairs(t *testing.T) {
+	// GIVEN
+	claims := lib.NewDNSRecordClaims()
+
+	// WHEN - a failover pair shares the name, a simple record of the name conflicts with it
+	claims.Claim("api.ebbo.dev", "A", "primary", "Lambda/Primary")
+	claims.Claim("api.ebbo.dev", "A", "maintenance", "Maintenance/Secondary")
+	if err := claims.Err(); err != nil {
+		t.Fatalf("unexpected conflict: %v", err)
+	}
+	claims.Claim("api.ebbo.dev", "A", "", "Other/Api")
+
+	// THEN
+	err := claims.Err()
+	if err == nil || strings.Count(err.Error(), "Other/Api") != 2 {
+		t.Fatalf("expected conflicts with both records of the pair, got %v", err)
+	}
+}
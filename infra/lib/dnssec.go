@@ -0,0 +1,47 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// DefaultKeySigningKeyName is used when DNSSEC is enabled without naming a key
+const DefaultKeySigningKeyName = "ksk1"
+
+// DNSSECConfig enables DNSSEC signing of the zone CoreStack manages
+type DNSSECConfig struct {
+	// KeyNames name the key-signing keys, each gets a KMS key of its own. To rotate, add a
+	// second name, publish its DS record, wait for the DS TTL and then remove the old name.
+	KeyNames []string `json:"keyNames,omitempty" yaml:"keyNames,omitempty"`
+}
+
+// Keys returns the names of the key-signing keys, one default key if none are named
+func (c *DNSSECConfig) Keys() []string {
+	if len(c.KeyNames) == 0 {
+		return []string{DefaultKeySigningKeyName}
+	}
+	return c.KeyNames
+}
+
+var keySigningKeyNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,128}$`)
+
+// Validate checks the key names, Route 53 allows two key-signing keys per zone
+func (c *DNSSECConfig) Validate() error {
+	var errs []error
+	if len(c.KeyNames) > 2 {
+		errs = append(errs, fmt.Errorf("DNSSEC allows at most two key-signing keys, got %d", len(c.KeyNames)))
+	}
+
+	seen := map[string]bool{}
+	for _, name := range c.KeyNames {
+		if !keySigningKeyNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("key-signing key name %q must be 3 to 128 letters, digits or underscores", name))
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("key-signing key %q is named twice", name))
+		}
+		seen[name] = true
+	}
+	return errors.Join(errs...)
+}
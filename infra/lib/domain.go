@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -8,21 +9,87 @@ import (
 type DomainConfig struct {
 	// The root domain name (e.g., "ebbo.dev")
 	RootDomain string
-	
+
 	// The hosted zone ID for the root domain
 	HostedZoneId string
+
+	// Strategy decides the hostnames of environments and apps, defaults to EnvPrefixedDomains
+	Strategy DomainStrategy
+
+	// DelegationRoleArn is a role in the root zone's account that may add NS records to it,
+	// required when environments live in a different account than the root zone
+	DelegationRoleArn string
+
+	// Records are created in the environment's zone by CoreStack, names are relative to
+	// the environment domain
+	Records []DNSRecord
+
+	// DNSSEC signs the environment's zone when set
+	DNSSEC *DNSSECConfig
+
+	// Email creates an SES identity for the environment domain when set
+	Email *EmailConfig
+
+	// Failover health checks the public endpoints and routes them to a maintenance page when set
+	Failover *FailoverConfig
+}
+
+func (d *DomainConfig) strategy() DomainStrategy {
+	if d.Strategy == nil {
+		return EnvPrefixedDomains{}
+	}
+	return d.Strategy
 }
 
-// GetAppDomain returns the full domain for an application in the current environment
-// Format: <app>.<environment>.rootDomain (e.g., vaultwarden.staging.ebbo.dev)
+// GetAppDomain returns the canonical hostname of an application in the current environment
+// (e.g. vault.staging.ebbo.dev, or vault.ebbo.dev for production with ApexForProduction)
 func (d *DomainConfig) GetAppDomain(appName string, env Environment) string {
-	return fmt.Sprintf("%s.%s.%s", appName, env.GetEnvPrefix(), d.RootDomain)
+	return d.strategy().AppDomain(d.RootDomain, appName, env)
+}
+
+// GetAliasDomains returns the hostnames that redirect to the application's canonical hostname
+func (d *DomainConfig) GetAliasDomains(appName string, env Environment) []string {
+	return d.strategy().AliasDomains(d.RootDomain, appName, env)
 }
 
 // GetEnvironmentDomain returns the base domain for the current environment
-// Format: <environment>.rootDomain (e.g., staging.ebbo.dev)
+// (e.g. staging.ebbo.dev, or ebbo.dev for production with ApexForProduction)
 func (d *DomainConfig) GetEnvironmentDomain(env Environment) string {
-	return fmt.Sprintf("%s.%s", env.GetEnvPrefix(), d.RootDomain)
+	return d.strategy().EnvironmentDomain(d.RootDomain, env)
+}
+
+// IsApex reports whether the environment is served from the root domain itself
+func (d *DomainConfig) IsApex(env Environment) bool {
+	return d.GetEnvironmentDomain(env) == d.RootDomain
+}
+
+// Validate checks that the root domain and its zone are set, that every hostname of the
+// environment lies within its domain and that the declared records are well-formed
+func (d *DomainConfig) Validate(env Environment) error {
+	if d.RootDomain == "" {
+		return errors.New("root domain must be set")
+	}
+	var errs []error
+	if d.HostedZoneId == "" {
+		errs = append(errs, fmt.Errorf("hosted zone ID of %s must be set", d.RootDomain))
+	}
+	errs = append(errs, d.strategy().Validate(d.RootDomain, env))
+	for _, record := range d.Records {
+		errs = append(errs, record.Validate())
+	}
+	if _, err := MergeDNSRecords(d.Records); err != nil {
+		errs = append(errs, err)
+	}
+	if d.DNSSEC != nil {
+		errs = append(errs, d.DNSSEC.Validate())
+	}
+	if d.Email != nil {
+		errs = append(errs, d.Email.Validate())
+	}
+	if d.Failover != nil {
+		errs = append(errs, d.Failover.Validate())
+	}
+	return errors.Join(errs...)
 }
 
 // DefaultDomainConfig returns a configuration with default values
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DomainStrategy decides the hostnames of an environment and its applications
+type DomainStrategy interface {
+	// EnvironmentDomain returns the domain of the environment's zone and wildcard certificate
+	EnvironmentDomain(root string, env Environment) string
+
+	// AppDomain returns the canonical hostname of an application
+	AppDomain(root, app string, env Environment) string
+
+	// AliasDomains returns extra hostnames of an application that redirect to its canonical hostname
+	AliasDomains(root, app string, env Environment) []string
+
+	// Validate checks that every hostname of the environment lies within its domain
+	Validate(root string, env Environment) error
+}
+
+// Names of the built-in strategies, as used by the domainStrategy field of profiles
+const (
+	StrategyEnvPrefixed       = "env-prefixed"
+	StrategyApexForProduction = "apex-for-production"
+)
+
+// DomainStrategyByName returns the built-in strategy with the given name, empty selects env-prefixed
+func DomainStrategyByName(name string) (DomainStrategy, error) {
+	switch name {
+	case "", StrategyEnvPrefixed:
+		return EnvPrefixedDomains{}, nil
+	case StrategyApexForProduction:
+		return ApexForProduction{}, nil
+	}
+	return nil, fmt.Errorf("unknown domain strategy %q (known strategies: %s, %s)", name, StrategyEnvPrefixed, StrategyApexForProduction)
+}
+
+// EnvPrefixedDomains places every environment below its prefix, e.g. api.pr-42.ebbo.dev
+type EnvPrefixedDomains struct{}
+
+func (EnvPrefixedDomains) EnvironmentDomain(root string, env Environment) string {
+	return fmt.Sprintf("%s.%s", env.GetEnvPrefix(), root)
+}
+
+func (s EnvPrefixedDomains) AppDomain(root, app string, env Environment) string {
+	return fmt.Sprintf("%s.%s", app, s.EnvironmentDomain(root, env))
+}
+
+func (EnvPrefixedDomains) AliasDomains(root, app string, env Environment) []string {
+	return nil
+}
+
+func (EnvPrefixedDomains) Validate(root string, env Environment) error {
+	return nil
+}
+
+// ApexForProduction serves production from the root domain, e.g. api.ebbo.dev, and
+// every other environment below its prefix
+type ApexForProduction struct{}
+
+func (ApexForProduction) EnvironmentDomain(root string, env Environment) string {
+	if env.kind() == KindProduction {
+		return root
+	}
+	return EnvPrefixedDomains{}.EnvironmentDomain(root, env)
+}
+
+func (s ApexForProduction) AppDomain(root, app string, env Environment) string {
+	return fmt.Sprintf("%s.%s", app, s.EnvironmentDomain(root, env))
+}
+
+func (ApexForProduction) AliasDomains(root, app string, env Environment) []string {
+	return nil
+}
+
+func (ApexForProduction) Validate(root string, env Environment) error {
+	return nil
+}
+
+// HostnameOverride replaces the hostname of an application and adds aliases redirecting to it
+type HostnameOverride struct {
+	// Hostname is the canonical hostname, empty keeps the hostname of the base strategy
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+
+	// Aliases redirect to the canonical hostname
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// CustomHostnames overrides the hostnames of individual applications on top of a base strategy
+type CustomHostnames struct {
+	// Base decides every hostname that is not overridden, defaults to EnvPrefixedDomains
+	Base DomainStrategy
+
+	// Overrides maps application names to their hostnames
+	Overrides map[string]HostnameOverride
+}
+
+func (s CustomHostnames) base() DomainStrategy {
+	if s.Base == nil {
+		return EnvPrefixedDomains{}
+	}
+	return s.Base
+}
+
+func (s CustomHostnames) EnvironmentDomain(root string, env Environment) string {
+	return s.base().EnvironmentDomain(root, env)
+}
+
+func (s CustomHostnames) AppDomain(root, app string, env Environment) string {
+	if override, ok := s.Overrides[app]; ok && override.Hostname != "" {
+		return override.Hostname
+	}
+	return s.base().AppDomain(root, app, env)
+}
+
+func (s CustomHostnames) AliasDomains(root, app string, env Environment) []string {
+	return append(s.base().AliasDomains(root, app, env), s.Overrides[app].Aliases...)
+}
+
+// Validate checks that every overridden hostname and alias can be created in the environment's zone
+func (s CustomHostnames) Validate(root string, env Environment) error {
+	errs := []error{s.base().Validate(root, env)}
+	domain := s.EnvironmentDomain(root, env)
+
+	apps := make([]string, 0, len(s.Overrides))
+	for app := range s.Overrides {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+
+	for _, app := range apps {
+		override := s.Overrides[app]
+		for _, hostname := range append([]string{override.Hostname}, override.Aliases...) {
+			if hostname != "" && !WithinDomain(domain, hostname) {
+				errs = append(errs, fmt.Errorf("hostname %s of %s is outside of the environment domain %s", hostname, app, domain))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithinDomain reports whether hostname is the domain itself or one of its subdomains
+func WithinDomain(domain, hostname string) bool {
+	return hostname == domain || strings.HasSuffix(hostname, "."+domain)
+}
+
+// CoveredByWildcard reports whether a certificate for *.<domain> covers every hostname
+func CoveredByWildcard(domain string, hostnames ...string) bool {
+	for _, hostname := range hostnames {
+		label, ok := strings.CutSuffix(hostname, "."+domain)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			return false
+		}
+	}
+	return true
+}
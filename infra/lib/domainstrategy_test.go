@@ -0,0 +1,91 @@
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestDomainStrategies(t *testing.T) {
+	pr := lib.Environment{Name: "pr", Kind: lib.KindPR, PRNumber: "42", IsPR: true}
+	production := lib.Environment{Name: "production", Kind: lib.KindProduction}
+
+	cases := []struct {
+		name        string
+		strategy    lib.DomainStrategy
+		env         lib.Environment
+		environment string
+		app         string
+	}{
+		{"env-prefixed pr", lib.EnvPrefixedDomains{}, pr, "pr-42.ebbo.dev", "api.pr-42.ebbo.dev"},
+		{"env-prefixed production", lib.EnvPrefixedDomains{}, production, "production.ebbo.dev", "api.production.ebbo.dev"},
+		{"apex pr", lib.ApexForProduction{}, pr, "pr-42.ebbo.dev", "api.pr-42.ebbo.dev"},
+		{"apex production", lib.ApexForProduction{}, production, "ebbo.dev", "api.ebbo.dev"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &lib.DomainConfig{RootDomain: "ebbo.dev", Strategy: c.strategy}
+			if got := config.GetEnvironmentDomain(c.env); got != c.environment {
+				t.Fatalf("expected environment domain %s, got %s", c.environment, got)
+			}
+			if got := config.GetAppDomain("api", c.env); got != c.app {
+				t.Fatalf("expected app domain %s, got %s", c.app, got)
+			}
+			if got := config.GetAliasDomains("api", c.env); len(got) != 0 {
+				t.Fatalf("expected no aliases, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCustomHostnamesOverrideApps(t *testing.T) {
+	production := lib.Environment{Name: "production", Kind: lib.KindProduction}
+	config := &lib.DomainConfig{
+		RootDomain:   "ebbo.dev",
+		HostedZoneId: "Z0000000000000000000",
+		Strategy: lib.CustomHostnames{
+			Base: lib.ApexForProduction{},
+			Overrides: map[string]lib.HostnameOverride{
+				"vault": {Hostname: "passwords.ebbo.dev", Aliases: []string{"vault.ebbo.dev"}},
+				"api":   {Aliases: []string{"www.api.ebbo.dev"}},
+			},
+		},
+	}
+
+	if got := config.GetAppDomain("vault", production); got != "passwords.ebbo.dev" {
+		t.Fatalf("expected the overridden hostname, got %s", got)
+	}
+	if got := config.GetAppDomain("api", production); got != "api.ebbo.dev" {
+		t.Fatalf("expected the hostname of the base strategy, got %s", got)
+	}
+	if got := config.GetAliasDomains("vault", production); !reflect.DeepEqual(got, []string{"vault.ebbo.dev"}) {
+		t.Fatalf("unexpected aliases %v", got)
+	}
+	if err := config.Validate(production); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The same overrides leave the zone of any other environment
+	pr := lib.Environment{Name: "pr", Kind: lib.KindPR, PRNumber: "42", IsPR: true}
+	if err := config.Validate(pr); err == nil {
+		t.Fatal("expected hostnames outside of pr-42.ebbo.dev to be rejected")
+	}
+}
+
+func TestCoveredByWildcard(t *testing.T) {
+	cases := map[string]bool{
+		"staging.ebbo.dev":         false,
+		"api.staging.ebbo.dev":     true,
+		"www.api.staging.ebbo.dev": false,
+		"api.ebbo.dev":             false,
+		"xstaging.ebbo.dev":        false,
+	}
+
+	for hostname, covered := range cases {
+		if got := lib.CoveredByWildcard("staging.ebbo.dev", hostname); got != covered {
+			t.Errorf("CoveredByWildcard(%s) = %v, expected %v", hostname, got, covered)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package lib
+
+import "fmt"
+
+// DefaultMailFromSubdomain is the MAIL FROM subdomain used when none is configured
+const DefaultMailFromSubdomain = "mail"
+
+// EmailConfig enables an SES identity for the environment domain
+type EmailConfig struct {
+	// MailFromSubdomain receives bounces of sent mail, e.g. mail for mail.staging.ebbo.dev
+	MailFromSubdomain string `json:"mailFromSubdomain,omitempty" yaml:"mailFromSubdomain,omitempty"`
+}
+
+// MailFromDomain returns the MAIL FROM domain below the given environment domain
+func (c *EmailConfig) MailFromDomain(environmentDomain string) string {
+	subdomain := c.MailFromSubdomain
+	if subdomain == "" {
+		subdomain = DefaultMailFromSubdomain
+	}
+	return subdomain + "." + environmentDomain
+}
+
+// Validate checks that the MAIL FROM subdomain is a single DNS label
+func (c *EmailConfig) Validate() error {
+	if c.MailFromSubdomain != "" && !dnsLabelPattern.MatchString(c.MailFromSubdomain) {
+		return fmt.Errorf("mail from subdomain %q must be a single lowercase DNS label", c.MailFromSubdomain)
+	}
+	return nil
+}
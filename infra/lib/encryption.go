@@ -0,0 +1,15 @@
+package lib
+
+// EncryptionConfig decides which resources are encrypted with the environment's customer managed
+// keys. Logs and secrets always are, their key can be changed in place.
+type EncryptionConfig struct {
+	// DataStores encrypts EFS file systems and ECR repositories with the data key. Changing the
+	// key of an existing file system or repository replaces it, so environments with data to
+	// keep must be migrated before turning it on.
+	DataStores bool `json:"dataStores,omitempty" yaml:"dataStores,omitempty"`
+}
+
+// EncryptsDataStores reports whether data stores use the data key, which is off unless configured
+func (c *EncryptionConfig) EncryptsDataStores() bool {
+	return c != nil && c.DataStores
+}
@@ -1,23 +1,54 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib/naming"
 )
 
 // Environment represents the deployment environment
 type Environment struct {
 	Name     string
+	Kind     EnvironmentKind
 	PRNumber string
-	Version  string
 	Username string
 	IsPR     bool
+
+	// ExpiresAt is when an ephemeral environment may be reaped, zero if it never expires
+	ExpiresAt time.Time
+
+	// Build describes the release and commit being deployed
+	Build BuildInfo
+
+	// Account and Region the environment's stacks are deployed to
+	Account string
+	Region  string
+
+	// Sources records where each resolved value came from, e.g. "context" or "GITHUB_REF=refs/heads/main"
+	Sources map[string]string
+
+	// Stacks names the stacks to build, empty builds every registered stack
+	Stacks []string
+
+	// Profile is the declarative configuration the environment was built from
+	Profile Profile
 }
 
+var (
+	prNumberPattern = regexp.MustCompile(`^[0-9]+$`)
+	dnsLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
 // getCurrentUsername retrieves the current username from the environment variables
 func getCurrentUsername() string {
 	username := os.Getenv("USER")
@@ -30,113 +61,344 @@ func getCurrentUsername() string {
 	return username
 }
 
-// converts a string from camelCase to kebab-case
-func kebabCase(s string) string {
-	// Split the string by uppercase letters
-	words := regexp.MustCompile("[A-Z][^A-Z]*").FindAllString(s, -1)
-	// Join the words with a hyphen
-	kebab := strings.Join(words, "-")
-	return strings.ToLower(kebab)
+// kind returns the environment kind, falling back to the name for environments built by hand
+func (e *Environment) kind() EnvironmentKind {
+	if e.Kind != "" {
+		return e.Kind
+	}
+	return EnvironmentKind(e.Name)
+}
+
+// username returns the configured or current username as a DNS-safe label
+func (e *Environment) username() string {
+	username := e.Username
+	if username == "" {
+		username = getCurrentUsername()
+	}
+	return naming.Label(username)
 }
 
+// GetStackName returns the environment-specific CloudFormation stack name for the suffix
 func (e *Environment) GetStackName(suffix string) string {
-	firstChar := string(e.Name[0])
+	// get the first char of e.Name
+	firstChar := ""
+	if e.Name != "" {
+		firstChar = e.Name[:1]
+	}
 
-	if e.Name == "pr" {
-		return e.Name + "-" + e.PRNumber + "-" + kebabCase(suffix)
+	if e.kind() == KindPR {
+		return naming.Stack.Format("pr", e.PRNumber, naming.Kebab(suffix))
 	}
-	if e.Name == "development" {
-		// If username is empty, use the current username
-		username := e.Username
-		if username == "" {
-			username = getCurrentUsername()
-		}
-		// get the first char of e.Name
-		return firstChar + "-" + username + "-" + kebabCase(suffix)
+	if e.kind() == KindDevelopment {
+		return naming.Stack.Format(firstChar, e.username(), naming.Kebab(suffix))
 	}
 
-	return firstChar + "-" + kebabCase(suffix)
+	return naming.Stack.Format(firstChar, naming.Kebab(suffix))
+}
+
+// ResourceName returns a physical name for a resource, prefixed with the environment and
+// shortened to the limits of the resource type
+func (e *Environment) ResourceName(rule naming.Rule, parts ...string) string {
+	return rule.Format(append([]string{e.GetEnvPrefix()}, parts...)...)
 }
 
 // GetEnvPrefix returns the environment-specific prefix for domain names
 func (e *Environment) GetEnvPrefix() string {
-	if e.Name == "pr" {
-		return "pr-" + e.PRNumber
+	if e.Profile.DomainPrefix != "" {
+		return naming.DNSLabel.Format(strings.NewReplacer(
+			"{name}", e.Name,
+			"{username}", e.username(),
+			"{pr}", e.PRNumber,
+		).Replace(e.Profile.DomainPrefix))
 	}
-	if e.Name == "development" {
-		username := e.Username
-		if username == "" {
-			username = getCurrentUsername()
-		}
-		return "d-" + username
+
+	switch e.kind() {
+	case KindPR:
+		return naming.DNSLabel.Format("pr", e.PRNumber)
+	case KindDevelopment:
+		return naming.DNSLabel.Format("d", e.username())
+	case KindStaging, KindProduction:
+		return naming.DNSLabel.Format(e.Name)
 	}
-	if e.Name == "staging" {
-		return "staging"
+	return "unknown"
+}
+
+// CostTags returns the tags that tell the cost of the environment apart from other environments.
+// PR and development environments share their Environment tag, so their PR or Owner tag is added.
+func (e *Environment) CostTags() map[string]string {
+	tags := map[string]string{"Environment": e.Name}
+	switch e.kind() {
+	case KindPR:
+		tags["PR"] = e.PRNumber
+	case KindDevelopment:
+		tags["Owner"] = e.username()
+	}
+	return tags
+}
+
+// Tags returns the tags every resource of the environment carries
+func (e *Environment) Tags() map[string]string {
+	tags := map[string]string{
+		"Environment": e.Name,
+		"Owner":       e.username(),
+		"ManagedBy":   "CDK",
 	}
-	if e.Name == "production" {
-		return "production"
+	if e.Profile.CostCenter != "" {
+		tags["CostCenter"] = e.Profile.CostCenter
 	}
-	return "unknown"
+	if e.kind() == KindPR {
+		tags["PR"] = e.PRNumber
+	}
+	if !e.ExpiresAt.IsZero() {
+		tags["ExpiresAt"] = e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	for key, value := range e.Build.Tags() {
+		tags[key] = value
+	}
+	return tags
 }
 
-// GetEnvironmentFromContext extracts environment information from CDK context
-func GetEnvironmentFromContext(app awscdk.App) Environment {
-	env := Environment{
-		Name: "unknown",
-		IsPR: false,
+// AwsEnvironment returns the account and region stacks are bound to, or nil for environment-agnostic stacks
+func (e *Environment) AwsEnvironment() *awscdk.Environment {
+	if e.Account == "" && e.Region == "" {
+		return nil
+	}
+
+	awsEnv := &awscdk.Environment{}
+	if e.Account != "" {
+		awsEnv.Account = jsii.String(e.Account)
+	}
+	if e.Region != "" {
+		awsEnv.Region = jsii.String(e.Region)
+	}
+	return awsEnv
+}
+
+// Validate checks that the environment is complete enough to synthesize
+func (e *Environment) Validate() error {
+	var errs []error
+
+	if e.Name == "" {
+		errs = append(errs, errors.New("environment name must not be empty"))
+	}
+	if !e.kind().IsValid() {
+		errs = append(errs, fmt.Errorf("environment %q has unsupported kind %q", e.Name, e.kind()))
+	}
+	if e.kind() == KindPR && !prNumberPattern.MatchString(e.PRNumber) {
+		errs = append(errs, fmt.Errorf("environment %q requires a numeric pr_number, got %q", e.Name, e.PRNumber))
 	}
+	if e.Account != "" && !accountPattern.MatchString(e.Account) {
+		errs = append(errs, fmt.Errorf("environment %q targets account %q, which is not a 12 digit AWS account ID", e.Name, e.Account))
+	}
+	if e.Region != "" && !regionPattern.MatchString(e.Region) {
+		errs = append(errs, fmt.Errorf("environment %q targets region %q, which is not a valid AWS region", e.Name, e.Region))
+	}
+	if e.kind() == KindProduction && e.Account == "" {
+		errs = append(errs, fmt.Errorf("production environment %q requires an explicit account", e.Name))
+	}
+	if prefix := e.GetEnvPrefix(); !dnsLabelPattern.MatchString(prefix) {
+		errs = append(errs, fmt.Errorf("environment %q resolves to domain prefix %q, which is not a valid DNS label", e.Name, prefix))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ContextString reads a context value as a string, treating missing values as empty
+func ContextString(app awscdk.App, key string) string {
+	value := app.Node().TryGetContext(jsii.String(key))
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%g", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ContextBool reports whether a context value is true, values passed with --context are strings
+// while cdk.json may hold booleans
+func ContextBool(app awscdk.App, key string) bool {
+	switch value := app.Node().TryGetContext(jsii.String(key)).(type) {
+	case bool:
+		return value
+	case string:
+		parsed, _ := strconv.ParseBool(value)
+		return parsed
+	}
+	return false
+}
 
-	// Add nil check for app
+// GetEnvironmentFromContext builds the environment named in the CDK context from its profile
+func GetEnvironmentFromContext(app awscdk.App, profiles *ProfileSet) (Environment, error) {
 	if app == nil {
-		panic("CDK app is nil. Cannot extract environment context.")
+		return Environment{}, errors.New("CDK app is nil, cannot extract environment context")
+	}
+	if profiles == nil {
+		return Environment{}, errors.New("no environment profiles loaded")
+	}
+
+	// Explicit context wins over values inferred from CI, which win over local defaults
+	ci, _ := CIMetadataFromEnv()
+	ciEnvironment, ciEnvironmentSource := ci.Environment()
+	sources := map[string]string{}
+	resolve := func(key string, candidates ...sourcedValue) string {
+		for _, candidate := range candidates {
+			if candidate.value != "" {
+				sources[key] = candidate.source
+				log.Printf("resolved %s=%q from %s", key, candidate.value, candidate.source)
+				return candidate.value
+			}
+		}
+		return ""
+	}
+
+	name := resolve("environment",
+		sourcedValue{ContextString(app, "environment"), "context"},
+		sourcedValue{ciEnvironment, ciEnvironmentSource},
+		sourcedValue{string(KindDevelopment), "default"},
+	)
+
+	profile, err := profiles.Get(name)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	env := Environment{
+		Name: name,
+		Kind: profile.Kind,
+		PRNumber: resolve("pr_number",
+			sourcedValue{ContextString(app, "pr_number"), "context"},
+			sourcedValue{ci.PRNumber, ci.prNumberSource},
+		),
+		Username: resolve("username",
+			sourcedValue{ContextString(app, "username"), "context"},
+			sourcedValue{ci.Actor, "GITHUB_ACTOR"},
+			sourcedValue{getCurrentUsername(), "$USER"},
+		),
+		IsPR:    profile.Kind == KindPR,
+		Stacks:  profile.Stacks,
+		Profile: profile,
+		Sources: sources,
+	}
+
+	// A "stacks" context value, e.g. -c stacks=core,lambda, replaces the profile's stacks
+	if stacks := contextList(app, "stacks"); len(stacks) > 0 {
+		env.Stacks = stacks
+	}
+
+	build, err := BuildInfoFromContext(app)
+	if err != nil {
+		return Environment{}, fmt.Errorf("invalid build metadata: %w", err)
+	}
+	build.Version = resolve("version",
+		sourcedValue{build.Version, "context"},
+		sourcedValue{ci.Version(), "GITHUB_REF"},
+	)
+	build.CommitSHA = resolve("sha",
+		sourcedValue{build.CommitSHA, "context"},
+		sourcedValue{ci.SHA, "GITHUB_SHA"},
+	)
+	if err := build.Validate(); err != nil {
+		return Environment{}, fmt.Errorf("invalid build metadata: %w", err)
 	}
+	env.Build = build
 
-	envName := app.Node().TryGetContext(jsii.String("environment"))
-	prNumber := app.Node().TryGetContext(jsii.String("pr_number"))
-	version := app.Node().TryGetContext(jsii.String("version"))
-	username := app.Node().TryGetContext(jsii.String("username"))
-	sha := app.Node().TryGetContext(jsii.String("sha"))
+	expiresAt, err := expiryFromContext(app, env.Kind, profile.TTL)
+	if err != nil {
+		return Environment{}, err
+	}
+	env.ExpiresAt = expiresAt
 
-	env = Environment{}
+	// Resolve the target account and region: context first, then the profile,
+	// then whatever the CDK CLI detected from the current credentials
+	env.Account = firstNonEmpty(ContextString(app, "account"), profile.Account, os.Getenv("CDK_DEFAULT_ACCOUNT"))
+	env.Region = firstNonEmpty(ContextString(app, "region"), profile.Region, os.Getenv("CDK_DEFAULT_REGION"))
 
-	// Validate environment context
-	if envName == nil || (envName != nil && envName.(string) == "") {
-		env.Name = "development"
+	if err := env.Validate(); err != nil {
+		return Environment{}, err
 	}
+	if err := profiles.CheckAccount(env); err != nil {
+		return Environment{}, err
+	}
+	return env, nil
+}
 
-	if nameStr, ok := envName.(string); ok {
-		env.Name = nameStr
+// expiryFromContext determines when an ephemeral environment expires. An explicit
+// expires_at or ttl context value wins over the profile's ttl, which is counted from
+// the deploy_time context value or now, never from the build time, so every deployment
+// extends the environment's lifetime, also of an old commit.
+func expiryFromContext(app awscdk.App, kind EnvironmentKind, profileTTL string) (time.Time, error) {
+	expiresAt := ContextString(app, "expires_at")
+	ttl := firstNonEmpty(ContextString(app, "ttl"), profileTTL)
+
+	if !kind.IsEphemeral() {
+		if expiresAt != "" || ContextString(app, "ttl") != "" {
+			return time.Time{}, fmt.Errorf("%s environments never expire, remove the expires_at and ttl context values", kind)
+		}
+		return time.Time{}, nil
 	}
 
-	if prNumber != nil {
-		if prStr, ok := prNumber.(string); ok {
-			env.PRNumber = prStr
-			env.IsPR = true
+	if expiresAt != "" {
+		value, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("expires_at must be an RFC 3339 timestamp, got %q", expiresAt)
 		}
+		return value.UTC().Truncate(time.Second), nil
+	}
+	if ttl == "" {
+		return time.Time{}, nil
 	}
 
-	if version != nil {
-		if versionStr, ok := version.(string); ok {
-			env.Version = versionStr
+	duration, err := ParseTTL(ttl)
+	if err != nil {
+		return time.Time{}, err
+	}
+	deployTime := time.Now()
+	if value := ContextString(app, "deploy_time"); value != "" {
+		deployTime, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("deploy_time must be an RFC 3339 timestamp, got %q", value)
 		}
 	}
+	return deployTime.Add(duration).UTC().Truncate(time.Second), nil
+}
 
-	if sha != nil {
-		if shaStr, ok := sha.(string); ok {
-			env.Version = shaStr
+// contextList reads a comma separated context value, cdk.json may also hold a list
+func contextList(app awscdk.App, key string) []string {
+	var values []string
+	switch v := app.Node().TryGetContext(jsii.String(key)).(type) {
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
 		}
+	default:
+		values = strings.Split(ContextString(app, key), ",")
 	}
 
-	// Set username from context or use current username as fallback
-	if username != nil {
-		if usernameStr, ok := username.(string); ok && usernameStr != "" {
-			env.Username = usernameStr
-		} else {
-			env.Username = getCurrentUsername()
+	var list []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
 		}
-	} else {
-		env.Username = getCurrentUsername()
 	}
+	return list
+}
+
+// sourcedValue is a candidate for a resolved value together with where it came from
+type sourcedValue struct {
+	value  string
+	source string
+}
 
-	return env
+// firstNonEmpty returns the first value that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
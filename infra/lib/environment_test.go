@@ -0,0 +1,227 @@
+package lib_test
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestGetEnvironmentFromContext(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "pr",
+			"pr_number":   "42",
+		},
+	})
+
+	env, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.Kind != lib.KindPR || !env.IsPR {
+		t.Fatalf("expected a PR environment, got kind %q", env.Kind)
+	}
+	if prefix := env.GetEnvPrefix(); prefix != "pr-42" {
+		t.Fatalf("expected prefix pr-42, got %q", prefix)
+	}
+	if len(env.Stacks) == 0 || slices.Contains(env.Stacks, "vaultwarden") {
+		t.Fatal("vaultwarden should not be enabled for pr environments")
+	}
+}
+
+func TestGetEnvironmentFromContextSelectsStacks(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "development",
+			"stacks":      "core, lambda",
+		},
+	})
+
+	env, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(env.Stacks, ",") != "core,lambda" {
+		t.Fatalf("expected the stacks from context, got %v", env.Stacks)
+	}
+}
+
+func TestGetEnvironmentFromContextFailsForUnknownEnvironments(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "qa",
+		},
+	})
+
+	_, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err == nil || !strings.Contains(err.Error(), `unknown environment "qa"`) {
+		t.Fatalf("expected unknown environment error, got %v", err)
+	}
+}
+
+func TestGetEnvironmentFromContextRequiresPRNumber(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "pr",
+		},
+	})
+
+	if _, err := lib.GetEnvironmentFromContext(app, profiles); err == nil {
+		t.Fatal("expected an error for a pr environment without pr_number")
+	}
+}
+
+func TestGetStackName(t *testing.T) {
+	dev := lib.Environment{Name: "development", Username: "Alice.Smith"}
+	if got := dev.GetStackName("vaultwarden-cluster"); got != "d-alice-smith-vaultwarden-cluster" {
+		t.Errorf("unexpected development stack name %q", got)
+	}
+	if got := dev.GetEnvPrefix(); got != "d-alice-smith" {
+		t.Errorf("unexpected development prefix %q", got)
+	}
+
+	pr := lib.Environment{Name: "pr", PRNumber: "42"}
+	if got := pr.GetStackName("VaultwardenStack"); got != "pr-42-vaultwarden-stack" {
+		t.Errorf("unexpected pr stack name %q", got)
+	}
+
+	// An empty environment must not panic
+	empty := lib.Environment{}
+	if got := empty.GetStackName("CoreStack"); got != "core-stack" {
+		t.Errorf("unexpected stack name for empty environment %q", got)
+	}
+}
+
+func TestCostTagsIdentifySharedEnvironments(t *testing.T) {
+	pr := lib.Environment{Name: "pr", PRNumber: "42"}
+	if got := pr.CostTags(); !maps.Equal(got, map[string]string{"Environment": "pr", "PR": "42"}) {
+		t.Errorf("unexpected pr cost tags %v", got)
+	}
+
+	dev := lib.Environment{Name: "development", Username: "Alice.Smith"}
+	if got := dev.CostTags(); !maps.Equal(got, map[string]string{"Environment": "development", "Owner": "alice-smith"}) {
+		t.Errorf("unexpected development cost tags %v", got)
+	}
+
+	staging := lib.Environment{Name: "staging"}
+	if got := staging.CostTags(); !maps.Equal(got, map[string]string{"Environment": "staging"}) {
+		t.Errorf("unexpected staging cost tags %v", got)
+	}
+}
+
+func TestGetEnvironmentFromContextResolvesAccountAndRegion(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "development",
+			"region":      "us-east-1",
+		},
+	})
+
+	env, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	awsEnv := env.AwsEnvironment()
+	if awsEnv == nil || *awsEnv.Account != "111111111111" || *awsEnv.Region != "us-east-1" {
+		t.Fatalf("expected account from profile and region from context, got %+v", awsEnv)
+	}
+}
+
+func TestGetEnvironmentFromContextRejectsProductionInDevelopmentAccount(t *testing.T) {
+	profiles := mustParseProfiles(t)
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "production",
+			"account":     "111111111111",
+		},
+	})
+
+	_, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err == nil || !strings.Contains(err.Error(), "must not target account 111111111111") {
+		t.Fatalf("expected account guard error, got %v", err)
+	}
+}
+
+func TestGetEnvironmentFromContextSetsExpiry(t *testing.T) {
+	profiles, err := lib.ParseProfiles([]byte(`
+environments:
+  pr:
+    kind: pr
+    ttl: 3d
+`), ".yaml")
+	if err != nil {
+		t.Fatalf("unexpected error parsing profiles: %v", err)
+	}
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "pr",
+			"pr_number":   "42",
+			"deploy_time": "2026-10-16T08:00:00Z",
+		},
+	})
+	env, err := lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := env.Tags()["ExpiresAt"]; got != "2026-10-19T08:00:00Z" {
+		t.Fatalf("expected expiry three days after the deployment, got %q", got)
+	}
+
+	// Redeploying a commit older than the ttl counts from the deployment, not the build
+	app = awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "pr",
+			"pr_number":   "42",
+			"build_time":  "2020-01-01T08:00:00Z",
+		},
+	})
+	env, err = lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !env.ExpiresAt.After(time.Now().Add(71 * time.Hour)) {
+		t.Fatalf("expected expiry three days from now for an old build, got %s", env.ExpiresAt)
+	}
+
+	// An explicit ttl overrides the profile
+	app = awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"environment": "pr",
+			"pr_number":   "42",
+			"deploy_time": "2026-10-16T08:00:00Z",
+			"ttl":         "12h",
+		},
+	})
+	env, err = lib.GetEnvironmentFromContext(app, profiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := env.Tags()["ExpiresAt"]; got != "2026-10-16T20:00:00Z" {
+		t.Fatalf("expected expiry from the ttl context value, got %q", got)
+	}
+}
+
+func TestProfilesRejectTTLForPermanentEnvironments(t *testing.T) {
+	_, err := lib.ParseProfiles([]byte("environments:\n  staging:\n    kind: staging\n    ttl: 3d\n"), ".yaml")
+	if err == nil {
+		t.Fatal("expected an error for a staging environment with a ttl")
+	}
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FailoverConfig enables health checks of the public endpoints and failover to a maintenance page
+type FailoverConfig struct {
+	// HealthCheckIntervalSeconds is 10 or 30, defaults to 30
+	HealthCheckIntervalSeconds int `json:"healthCheckIntervalSeconds,omitempty" yaml:"healthCheckIntervalSeconds,omitempty"`
+
+	// FailureThreshold is the number of failed checks before an endpoint fails over, defaults to 3
+	FailureThreshold int `json:"failureThreshold,omitempty" yaml:"failureThreshold,omitempty"`
+
+	// AlarmEmail is subscribed to the health check alarms if set
+	AlarmEmail string `json:"alarmEmail,omitempty" yaml:"alarmEmail,omitempty"`
+}
+
+// Interval returns the health check interval in seconds
+func (c *FailoverConfig) Interval() int {
+	if c.HealthCheckIntervalSeconds == 0 {
+		return 30
+	}
+	return c.HealthCheckIntervalSeconds
+}
+
+// Threshold returns the number of failed checks before an endpoint fails over
+func (c *FailoverConfig) Threshold() int {
+	if c.FailureThreshold == 0 {
+		return 3
+	}
+	return c.FailureThreshold
+}
+
+// OriginHostname returns the hostname health checks use to reach an app directly, as its
+// canonical hostname points to the maintenance page while the app is unhealthy
+func (c *FailoverConfig) OriginHostname(app, environmentDomain string) string {
+	return fmt.Sprintf("origin-%s.%s", app, environmentDomain)
+}
+
+// Validate checks the values Route 53 accepts for health checks
+func (c *FailoverConfig) Validate() error {
+	var errs []error
+	if interval := c.Interval(); interval != 10 && interval != 30 {
+		errs = append(errs, fmt.Errorf("health check interval must be 10 or 30 seconds, got %d", interval))
+	}
+	if threshold := c.Threshold(); threshold < 1 || threshold > 10 {
+		errs = append(errs, fmt.Errorf("failure threshold must be between 1 and 10, got %d", threshold))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,31 @@
+package lib_test
+
+import (
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestFailoverConfigDefaults(t *testing.T) {
+	config := &lib.FailoverConfig{}
+	if config.Interval() != 30 || config.Threshold() != 3 {
+		t.Fatalf("unexpected defaults: interval %d, threshold %d", config.Interval(), config.Threshold())
+	}
+	if got := config.OriginHostname("api", "dev.ebbo.dev"); got != "origin-api.dev.ebbo.dev" {
+		t.Fatalf("unexpected origin hostname %q", got)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFailoverConfigRejectsUnsupportedValues(t *testing.T) {
+	for _, config := range []lib.FailoverConfig{
+		{HealthCheckIntervalSeconds: 15},
+		{FailureThreshold: 11},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", config)
+		}
+	}
+}
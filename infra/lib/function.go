@@ -0,0 +1,237 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FunctionManifestFile is the manifest in a function folder, folders without one use the defaults
+const FunctionManifestFile = "function.yaml"
+
+// Architectures of a FunctionManifest
+const (
+	ArchitectureArm64  = "arm64"
+	ArchitectureX86_64 = "x86_64"
+)
+
+// Defaults of a FunctionManifest
+const (
+	DefaultFunctionMemoryMiB      = 128
+	DefaultFunctionTimeoutSeconds = 300
+	DefaultFunctionArchitecture   = ArchitectureArm64
+)
+
+// FunctionMethods are the HTTP methods a route may declare, ANY matches every method. OPTIONS is
+// answered by the CORS preflight of the API.
+var FunctionMethods = []string{"ANY", "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+var (
+	environmentVariablePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	routeSegmentPattern        = regexp.MustCompile(`^([A-Za-z0-9._~-]+|\{[A-Za-z][A-Za-z0-9_]*\}|\{proxy\+\})$`)
+)
+
+// FunctionRoute is a path of the API served by a function
+type FunctionRoute struct {
+	// Path starts with a slash, segments may be path parameters such as {id} and the last one
+	// may be {proxy+} to match everything below
+	Path string `json:"path" yaml:"path"`
+
+	// Methods defaults to ANY
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+}
+
+// HTTPMethods returns the methods of the route
+func (r FunctionRoute) HTTPMethods() []string {
+	if len(r.Methods) == 0 {
+		return []string{"ANY"}
+	}
+	return r.Methods
+}
+
+// FunctionManifest configures the Lambda function built from a folder in ./functions
+type FunctionManifest struct {
+	// MemoryMiB defaults to DefaultFunctionMemoryMiB
+	MemoryMiB int `json:"memoryMiB,omitempty" yaml:"memoryMiB,omitempty"`
+
+	// TimeoutSeconds defaults to DefaultFunctionTimeoutSeconds, API Gateway stops waiting after 29
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds,omitempty"`
+
+	// Architecture is arm64 or x86_64 and has to match the build, defaults to arm64
+	Architecture string `json:"architecture,omitempty" yaml:"architecture,omitempty"`
+
+	// Environment variables of the function, next to the BUILD_* variables of the build
+	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	// Routes default to /<folder> and everything below it
+	Routes []FunctionRoute `json:"routes,omitempty" yaml:"routes,omitempty"`
+
+	// Root serves the root of the API by the function as well, instead of the built-in index
+	// handler. Only one function may declare it.
+	Root bool `json:"root,omitempty" yaml:"root,omitempty"`
+
+	// ReservedConcurrency caps the concurrent executions of the function, 0 disables it
+	ReservedConcurrency *int `json:"reservedConcurrency,omitempty" yaml:"reservedConcurrency,omitempty"`
+
+	// Tags of the function, keys need the x: prefix of the tag policy
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Permissions are the AWS permissions of the function, it has none when not set
+	Permissions *FunctionPermissions `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+// LoadFunctionManifest reads the manifest of a function folder, a folder without one gets the
+// defaults
+func LoadFunctionManifest(folder string) (*FunctionManifest, error) {
+	path := filepath.Join(folder, FunctionManifestFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &FunctionManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading function manifest: %w", err)
+	}
+	manifest, err := ParseFunctionManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// ParseFunctionManifest decodes and validates a function manifest
+func ParseFunctionManifest(data []byte) (*FunctionManifest, error) {
+	var manifest FunctionManifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("malformed function manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Memory returns the memory of the function in MiB
+func (m *FunctionManifest) Memory() int {
+	if m.MemoryMiB == 0 {
+		return DefaultFunctionMemoryMiB
+	}
+	return m.MemoryMiB
+}
+
+// Timeout returns the timeout of the function in seconds
+func (m *FunctionManifest) Timeout() int {
+	if m.TimeoutSeconds == 0 {
+		return DefaultFunctionTimeoutSeconds
+	}
+	return m.TimeoutSeconds
+}
+
+// CPUArchitecture returns the instruction set the function runs on
+func (m *FunctionManifest) CPUArchitecture() string {
+	if m.Architecture == "" {
+		return DefaultFunctionArchitecture
+	}
+	return m.Architecture
+}
+
+// APIRoutes returns the routes of the function in the folder name
+func (m *FunctionManifest) APIRoutes(name string) []FunctionRoute {
+	if len(m.Routes) == 0 {
+		return []FunctionRoute{
+			{Path: "/" + name},
+			{Path: "/" + name + "/{proxy+}"},
+		}
+	}
+	return m.Routes
+}
+
+// Validate checks the sizing, the environment variables, the routes, the tags and the permissions
+func (m *FunctionManifest) Validate() error {
+	var errs []error
+
+	if m.MemoryMiB != 0 && (m.MemoryMiB < 128 || m.MemoryMiB > 10240) {
+		errs = append(errs, fmt.Errorf("memoryMiB %d must be between 128 and 10240", m.MemoryMiB))
+	}
+	if m.TimeoutSeconds < 0 || m.TimeoutSeconds > 900 {
+		errs = append(errs, fmt.Errorf("timeoutSeconds %d must be between 1 and 900", m.TimeoutSeconds))
+	}
+	if a := m.CPUArchitecture(); a != ArchitectureArm64 && a != ArchitectureX86_64 {
+		errs = append(errs, fmt.Errorf("architecture %q is not supported, use %s or %s", a, ArchitectureArm64, ArchitectureX86_64))
+	}
+	if m.ReservedConcurrency != nil && *m.ReservedConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("reservedConcurrency %d must not be negative", *m.ReservedConcurrency))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(m.Environment)) {
+		switch {
+		case !environmentVariablePattern.MatchString(name):
+			errs = append(errs, fmt.Errorf("environment variable %q must be letters, digits and underscores", name))
+		case strings.HasPrefix(name, "AWS_") || strings.HasPrefix(name, "LAMBDA_") || name == "_HANDLER":
+			errs = append(errs, fmt.Errorf("environment variable %s is reserved by Lambda", name))
+		case strings.HasPrefix(name, "BUILD_"):
+			errs = append(errs, fmt.Errorf("environment variable %s is reserved for the build info", name))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, route := range m.Routes {
+		if err := validateRoutePath(route.Path); err != nil {
+			errs = append(errs, err)
+		}
+		for _, method := range route.HTTPMethods() {
+			if !slices.Contains(FunctionMethods, method) {
+				errs = append(errs, fmt.Errorf("route %s has unsupported method %q, use one of %s", route.Path, method, strings.Join(FunctionMethods, ", ")))
+			}
+			if seen[method+" "+route.Path] {
+				errs = append(errs, fmt.Errorf("route %s %s declared more than once", method, route.Path))
+			}
+			seen[method+" "+route.Path] = true
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(m.Tags)) {
+		switch {
+		case !strings.HasPrefix(key, "x:") || len(key) > 128:
+			errs = append(errs, fmt.Errorf("tag %q must start with x: and have at most 128 characters", key))
+		case len(m.Tags[key]) > 256:
+			errs = append(errs, fmt.Errorf("tag %s must have at most 256 characters", key))
+		}
+	}
+
+	if m.Permissions != nil {
+		if err := m.Permissions.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateRoutePath checks that a route path is below the root of the API, which the root
+// function serves
+func validateRoutePath(path string) error {
+	if !strings.HasPrefix(path, "/") || path == "/" {
+		return fmt.Errorf("route path %q must start with a slash and name a resource below the root", path)
+	}
+	segments := strings.Split(path[1:], "/")
+	for i, segment := range segments {
+		if !routeSegmentPattern.MatchString(segment) {
+			return fmt.Errorf("route path %q has invalid segment %q", path, segment)
+		}
+		if segment == "{proxy+}" && i != len(segments)-1 {
+			return fmt.Errorf("route path %q may only use {proxy+} as its last segment", path)
+		}
+	}
+	return nil
+}
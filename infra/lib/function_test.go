@@ -0,0 +1,87 @@
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestFunctionManifestDefaults(t *testing.T) {
+	manifest, err := lib.LoadFunctionManifest(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manifest.Memory() != 128 || manifest.Timeout() != 300 || manifest.CPUArchitecture() != lib.ArchitectureArm64 {
+		t.Fatalf("unexpected defaults: memory %d, timeout %d, architecture %s", manifest.Memory(), manifest.Timeout(), manifest.CPUArchitecture())
+	}
+	routes := manifest.APIRoutes("gin-server")
+	if len(routes) != 2 || routes[0].Path != "/gin-server" || routes[1].Path != "/gin-server/{proxy+}" || routes[0].HTTPMethods()[0] != "ANY" {
+		t.Fatalf("unexpected default routes %+v", routes)
+	}
+}
+
+func TestParseFunctionManifest(t *testing.T) {
+	manifest, err := lib.ParseFunctionManifest([]byte(`
+memoryMiB: 512
+timeoutSeconds: 20
+architecture: x86_64
+environment:
+  LOG_LEVEL: debug
+routes:
+  - path: /users
+    methods: [GET, POST]
+  - path: /users/{id}
+reservedConcurrency: 10
+root: true
+tags:
+  x:team: platform
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manifest.Memory() != 512 || manifest.Timeout() != 20 || manifest.CPUArchitecture() != lib.ArchitectureX86_64 {
+		t.Fatalf("unexpected sizing %+v", manifest)
+	}
+	if manifest.Environment["LOG_LEVEL"] != "debug" || *manifest.ReservedConcurrency != 10 || !manifest.Root || manifest.Tags["x:team"] != "platform" {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	if routes := manifest.APIRoutes("users"); len(routes) != 2 || len(routes[0].HTTPMethods()) != 2 || routes[1].HTTPMethods()[0] != "ANY" {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+}
+
+func TestFunctionManifestRejectsInvalidManifests(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"unknown field":        "memory: 512",
+		"too little memory":    "memoryMiB: 64",
+		"too long timeout":     "timeoutSeconds: 901",
+		"architecture":         "architecture: arm",
+		"negative concurrency": "reservedConcurrency: -1",
+		"reserved variable":    "environment: {AWS_REGION: eu-central-1}",
+		"build variable":       "environment: {BUILD_VERSION: v1}",
+		"invalid variable":     "environment: {LOG-LEVEL: debug}",
+		"relative path":        "routes: [{path: users}]",
+		"root path":            "routes: [{path: /}]",
+		"proxy not last":       "routes: [{path: '/{proxy+}/users'}]",
+		"options method":       "routes: [{path: /users, methods: [OPTIONS]}]",
+		"duplicate route":      "routes: [{path: /users}, {path: /users, methods: [ANY]}]",
+		"unprefixed tag":       "tags: {team: platform}",
+	} {
+		if _, err := lib.ParseFunctionManifest([]byte(manifest)); err == nil {
+			t.Errorf("expected %s manifest to be rejected", name)
+		}
+	}
+}
+
+func TestLoadFunctionManifestNamesTheFile(t *testing.T) {
+	folder := t.TempDir()
+	if err := os.WriteFile(filepath.Join(folder, lib.FunctionManifestFile), []byte("memoryMiB: 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := lib.LoadFunctionManifest(folder)
+	if err == nil || err.Error() != filepath.Join(folder, "function.yaml")+": memoryMiB 1 must be between 128 and 10240" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
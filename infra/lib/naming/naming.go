@@ -0,0 +1,185 @@
+// Package naming generates physical names that satisfy the length and
+// character set limits of the AWS resources they are used for.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// hashLength is the number of hex characters appended to truncated names
+const hashLength = 8
+
+// Rule describes the naming constraints of an AWS resource type
+type Rule struct {
+	// Resource is a human readable name of the resource type
+	Resource string
+
+	// MaxLength is the maximum number of characters allowed
+	MaxLength int
+
+	// Lowercase forces the generated name to lowercase
+	Lowercase bool
+
+	// Allowed reports whether a character may appear in the name, all other characters become hyphens
+	Allowed func(r rune) bool
+}
+
+func isLower(r rune) bool { return r >= 'a' && r <= 'z' }
+func isUpper(r rune) bool { return r >= 'A' && r <= 'Z' }
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+
+func alphanumeric(r rune) bool { return isLower(r) || isUpper(r) || isDigit(r) }
+
+func alphanumericOr(extra string) func(r rune) bool {
+	return func(r rune) bool {
+		return alphanumeric(r) || strings.ContainsRune(extra, r)
+	}
+}
+
+var (
+	// Stack is a CloudFormation stack name
+	Stack = Rule{Resource: "CloudFormation stack", MaxLength: 128, Allowed: alphanumericOr("-")}
+
+	// LoadBalancer is an Application or Network Load Balancer name
+	LoadBalancer = Rule{Resource: "load balancer", MaxLength: 32, Allowed: alphanumericOr("-")}
+
+	// Bucket is an S3 bucket name
+	Bucket = Rule{Resource: "S3 bucket", MaxLength: 63, Lowercase: true, Allowed: alphanumericOr("-.")}
+
+	// ECRRepository is an ECR repository name, which may contain namespaces separated by slashes
+	ECRRepository = Rule{Resource: "ECR repository", MaxLength: 256, Lowercase: true, Allowed: alphanumericOr("-_./")}
+
+	// ECSCluster is an ECS cluster name
+	ECSCluster = Rule{Resource: "ECS cluster", MaxLength: 255, Allowed: alphanumericOr("-_")}
+
+	// FileSystem is the name tag of an EFS file system
+	FileSystem = Rule{Resource: "EFS file system", MaxLength: 256, Allowed: alphanumericOr("-_.")}
+
+	// Function is a Lambda function name
+	Function = Rule{Resource: "Lambda function", MaxLength: 64, Allowed: alphanumericOr("-_")}
+
+	// Role is an IAM role name
+	Role = Rule{Resource: "IAM role", MaxLength: 64, Allowed: alphanumericOr("-_+=,.@")}
+
+	// RestApi is an API Gateway REST API name
+	RestApi = Rule{Resource: "API Gateway REST API", MaxLength: 1024, Allowed: alphanumericOr("-_")}
+
+	// LogGroup is a CloudWatch Logs log group name
+	LogGroup = Rule{Resource: "log group", MaxLength: 512, Allowed: alphanumericOr("-_./#")}
+
+	// SESConfigurationSet is an SES configuration set name
+	SESConfigurationSet = Rule{Resource: "SES configuration set", MaxLength: 64, Allowed: alphanumericOr("-_")}
+
+	// Alarm is a CloudWatch alarm name
+	Alarm = Rule{Resource: "CloudWatch alarm", MaxLength: 255, Allowed: alphanumericOr("-_.")}
+
+	// Dashboard is a CloudWatch dashboard name
+	Dashboard = Rule{Resource: "CloudWatch dashboard", MaxLength: 255, Allowed: alphanumericOr("-_")}
+
+	// Budget is an AWS Budgets budget name
+	Budget = Rule{Resource: "budget", MaxLength: 100, Allowed: alphanumericOr("-_.")}
+
+	// AnomalyMonitor is a Cost Explorer anomaly monitor or subscription name
+	AnomalyMonitor = Rule{Resource: "anomaly monitor", MaxLength: 1024, Allowed: alphanumericOr("-_.")}
+
+	// KMSAlias is a KMS key alias without its alias/ prefix
+	KMSAlias = Rule{Resource: "KMS alias", MaxLength: 250, Allowed: alphanumericOr("-_/")}
+
+	// DNSLabel is a single label of a DNS name
+	DNSLabel = Rule{Resource: "DNS label", MaxLength: 63, Lowercase: true, Allowed: alphanumericOr("-")}
+)
+
+// Kebab converts camelCase, PascalCase, snake_case and dotted strings to kebab-case.
+// Acronyms are kept together and digits stay attached to the preceding word,
+// so "APIGatewayV2Stack" becomes "api-gateway-v2-stack" and "S3Bucket" becomes "s3-bucket".
+func Kebab(s string) string {
+	var words []string
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !alphanumeric(r) {
+			flush()
+			continue
+		}
+		if i > 0 && len(word) > 0 && isUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && isLower(runes[i+1])
+			// Split "fooBar", "foo2Bar" and the end of an acronym in "APIGateway"
+			if isLower(prev) || isDigit(prev) || (isUpper(prev) && nextIsLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return strings.Join(words, "-")
+}
+
+// Format joins the parts with hyphens and returns a name that satisfies the rule.
+// Names that exceed the maximum length are truncated and suffixed with a hash of
+// the full name, so distinct long names stay distinct.
+func (r Rule) Format(parts ...string) string {
+	var cleaned []string
+	for _, part := range parts {
+		if part = r.sanitize(part); part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+	name := strings.Join(cleaned, "-")
+
+	if len(name) <= r.MaxLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	prefix := strings.TrimRight(name[:r.MaxLength-hashLength-1], "-_./")
+	return prefix + "-" + hash
+}
+
+// sanitize replaces disallowed characters and collapses repeated separators
+func (r Rule) sanitize(s string) string {
+	if r.Lowercase {
+		s = strings.ToLower(s)
+	}
+
+	var b strings.Builder
+	lastHyphen := false
+	for _, c := range s {
+		if c > unicode.MaxASCII || !r.Allowed(c) {
+			c = '-'
+		}
+		if c == '-' {
+			if lastHyphen {
+				continue
+			}
+			lastHyphen = true
+		} else {
+			lastHyphen = false
+		}
+		b.WriteRune(c)
+	}
+
+	return strings.Trim(b.String(), "-_./")
+}
+
+// Label returns a DNS-safe label for arbitrary input such as usernames
+func Label(s string) string {
+	label := DNSLabel.Format(s)
+	if label == "" {
+		return "default"
+	}
+	return label
+}
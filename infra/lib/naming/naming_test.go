@@ -0,0 +1,76 @@
+package naming_test
+
+import (
+	"strings"
+	"testing"
+
+	"aws-infra-sandbox/lib/naming"
+)
+
+func TestKebab(t *testing.T) {
+	cases := map[string]string{
+		"CoreStack":                  "core-stack",
+		"vaultwarden-cluster":        "vaultwarden-cluster",
+		"VaultwardenImageRepository": "vaultwarden-image-repository",
+		"APIGatewayV2Stack":          "api-gateway-v2-stack",
+		"S3Bucket":                   "s3-bucket",
+		"gin_server":                 "gin-server",
+		"alice.smith":                "alice-smith",
+		"":                           "",
+	}
+
+	for input, want := range cases {
+		if got := naming.Kebab(input); got != want {
+			t.Errorf("Kebab(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFormatSanitizes(t *testing.T) {
+	if got := naming.DNSLabel.Format("d", "Alice.Smith"); got != "d-alice-smith" {
+		t.Errorf("unexpected DNS label %q", got)
+	}
+	if got := naming.ECRRepository.Format("pr-42", "Vaultwarden/Server"); got != "pr-42-vaultwarden/server" {
+		t.Errorf("unexpected ECR repository name %q", got)
+	}
+	if got := naming.Stack.Format("", "alice", "--core--"); got != "alice-core" {
+		t.Errorf("unexpected stack name %q", got)
+	}
+}
+
+func TestFormatTruncatesWithHash(t *testing.T) {
+	long := naming.LoadBalancer.Format("d", "a-very-long-developer-name", "vaultwarden")
+	other := naming.LoadBalancer.Format("d", "a-very-long-developer-name", "vaultwarden-two")
+
+	if len(long) > naming.LoadBalancer.MaxLength {
+		t.Fatalf("name %q exceeds %d characters", long, naming.LoadBalancer.MaxLength)
+	}
+	if long == other {
+		t.Fatalf("truncated names must stay distinct, both are %q", long)
+	}
+	if !strings.HasPrefix(long, "d-a-very-long-developer") {
+		t.Fatalf("truncated name should keep its prefix, got %q", long)
+	}
+	if long != naming.LoadBalancer.Format("d", "a-very-long-developer-name", "vaultwarden") {
+		t.Fatal("truncated names must be deterministic")
+	}
+}
+
+func TestBucketNamesAreShortAndLowercase(t *testing.T) {
+	name := naming.Bucket.Format("D-Alice", "Maintenance.Site", strings.Repeat("Assets", 12))
+	if len(name) > naming.Bucket.MaxLength || naming.Bucket.MaxLength != 63 {
+		t.Fatalf("name %q exceeds 63 characters", name)
+	}
+	if name != strings.ToLower(name) {
+		t.Fatalf("bucket names must be lowercase, got %q", name)
+	}
+	if !strings.HasPrefix(name, "d-alice-maintenance.site-assets") {
+		t.Fatalf("bucket names keep dots, got %q", name)
+	}
+}
+
+func TestLabelFallsBackForEmptyInput(t *testing.T) {
+	if got := naming.Label("..."); got != "default" {
+		t.Errorf("expected default label, got %q", got)
+	}
+}
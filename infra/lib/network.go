@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+)
+
+// Subnet types of a SubnetConfig
+const (
+	// SubnetPublic subnets route to an internet gateway, load balancers and NAT gateways live there
+	SubnetPublic = "public"
+
+	// SubnetPrivate subnets reach the internet through NAT gateways
+	SubnetPrivate = "private"
+
+	// SubnetIsolated subnets only reach AWS services through the VPC endpoints
+	SubnetIsolated = "isolated"
+)
+
+// DefaultNetworkCidr is the address range of environment VPCs
+const DefaultNetworkCidr = "10.0.0.0/16"
+
+// DefaultMaxAzs is the number of availability zones environment VPCs span, load balancers need two
+const DefaultMaxAzs = 2
+
+// DefaultSubnets is the subnet layout of environment VPCs, public ingress and isolated workloads
+var DefaultSubnets = []SubnetConfig{
+	{Name: "ingress", Type: SubnetPublic, CidrMask: 24},
+	{Name: "isolated", Type: SubnetIsolated, CidrMask: 24},
+}
+
+var subnetNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// SubnetConfig is a subnet group, with one subnet per availability zone
+type SubnetConfig struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Type is public, private or isolated
+	Type string `json:"type" yaml:"type"`
+
+	// CidrMask is the prefix length of each subnet of the group
+	CidrMask int `json:"cidrMask" yaml:"cidrMask"`
+}
+
+// NetworkConfig is the VPC an environment's stacks share, see DefaultSubnets for the layout
+type NetworkConfig struct {
+	// Cidr is the address range of the VPC, defaults to DefaultNetworkCidr
+	Cidr string `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+
+	// MaxAzs is the number of availability zones, defaults to DefaultMaxAzs
+	MaxAzs int `json:"maxAzs,omitempty" yaml:"maxAzs,omitempty"`
+
+	// NatGateways is the number of NAT gateways of private subnets, defaults to one if the
+	// layout has private subnets
+	NatGateways *int `json:"natGateways,omitempty" yaml:"natGateways,omitempty"`
+
+	// Subnets is the subnet layout, defaults to DefaultSubnets
+	Subnets []SubnetConfig `json:"subnets,omitempty" yaml:"subnets,omitempty"`
+}
+
+// VpcCidr returns the address range of the VPC
+func (c *NetworkConfig) VpcCidr() string {
+	if c.Cidr == "" {
+		return DefaultNetworkCidr
+	}
+	return c.Cidr
+}
+
+// AzCount returns the number of availability zones
+func (c *NetworkConfig) AzCount() int {
+	if c.MaxAzs == 0 {
+		return DefaultMaxAzs
+	}
+	return c.MaxAzs
+}
+
+// Layout returns the subnet groups of the VPC
+func (c *NetworkConfig) Layout() []SubnetConfig {
+	if len(c.Subnets) == 0 {
+		return DefaultSubnets
+	}
+	return c.Subnets
+}
+
+// NatGatewayCount returns the number of NAT gateways of the VPC
+func (c *NetworkConfig) NatGatewayCount() int {
+	if c.NatGateways != nil {
+		return *c.NatGateways
+	}
+	for _, subnet := range c.Layout() {
+		if subnet.Type == SubnetPrivate {
+			return 1
+		}
+	}
+	return 0
+}
+
+// WorkloadSubnets returns the subnet group tasks, file systems and VPC endpoints are placed in,
+// the first private group or else the first isolated one
+func (c *NetworkConfig) WorkloadSubnets() (SubnetConfig, bool) {
+	for _, subnetType := range []string{SubnetPrivate, SubnetIsolated} {
+		for _, subnet := range c.Layout() {
+			if subnet.Type == subnetType {
+				return subnet, true
+			}
+		}
+	}
+	return SubnetConfig{}, false
+}
+
+// Validate checks the address range, the number of availability zones and that the subnet
+// layout fits into the address range
+func (c *NetworkConfig) Validate() error {
+	var errs []error
+
+	prefix, err := netip.ParsePrefix(c.VpcCidr())
+	if err != nil || !prefix.Addr().Is4() || prefix.Masked() != prefix {
+		errs = append(errs, fmt.Errorf("network cidr %q must be an IPv4 network address such as %s", c.VpcCidr(), DefaultNetworkCidr))
+	} else if prefix.Bits() < 16 || prefix.Bits() > 28 {
+		errs = append(errs, fmt.Errorf("network cidr %q must have a prefix length between 16 and 28", c.VpcCidr()))
+	}
+	if c.MaxAzs < 0 || c.MaxAzs > 6 {
+		errs = append(errs, fmt.Errorf("network maxAzs %d must be between 1 and 6", c.MaxAzs))
+	}
+
+	seen := map[string]bool{}
+	hasPublic, hasPrivate := false, false
+	var addresses uint64
+	for _, subnet := range c.Layout() {
+		if !subnetNamePattern.MatchString(subnet.Name) {
+			errs = append(errs, fmt.Errorf("subnet name %q must be lowercase letters, digits and hyphens", subnet.Name))
+		} else if seen[subnet.Name] {
+			errs = append(errs, fmt.Errorf("subnet %q declared more than once", subnet.Name))
+		}
+		seen[subnet.Name] = true
+
+		switch subnet.Type {
+		case SubnetPublic:
+			hasPublic = true
+		case SubnetPrivate:
+			hasPrivate = true
+		case SubnetIsolated:
+		default:
+			errs = append(errs, fmt.Errorf("subnet %q has unsupported type %q, use public, private or isolated", subnet.Name, subnet.Type))
+		}
+
+		if subnet.CidrMask < 16 || subnet.CidrMask > 28 {
+			errs = append(errs, fmt.Errorf("subnet %q cidrMask %d must be between 16 and 28", subnet.Name, subnet.CidrMask))
+			continue
+		}
+		addresses += uint64(c.AzCount()) << (32 - subnet.CidrMask)
+	}
+	if err == nil && addresses > uint64(1)<<(32-prefix.Bits()) {
+		errs = append(errs, fmt.Errorf("subnets of %d availability zones do not fit into network cidr %s", c.AzCount(), c.VpcCidr()))
+	}
+
+	if _, ok := c.WorkloadSubnets(); !ok {
+		errs = append(errs, errors.New("network needs a private or isolated subnet for workloads"))
+	}
+	nat := c.NatGatewayCount()
+	switch {
+	case nat < 0 || nat > c.AzCount():
+		errs = append(errs, fmt.Errorf("network natGateways %d must be between 0 and the number of availability zones", nat))
+	case nat > 0 && (!hasPrivate || !hasPublic):
+		errs = append(errs, errors.New("network NAT gateways need a public subnet and serve private subnets only"))
+	case nat == 0 && hasPrivate:
+		errs = append(errs, errors.New("private subnets need at least one NAT gateway, use isolated subnets otherwise"))
+	}
+	return errors.Join(errs...)
+}
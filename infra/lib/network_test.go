@@ -0,0 +1,59 @@
+package lib_test
+
+import (
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestNetworkConfigDefaults(t *testing.T) {
+	config := &lib.NetworkConfig{}
+	if config.VpcCidr() != lib.DefaultNetworkCidr || config.AzCount() != 2 || config.NatGatewayCount() != 0 {
+		t.Fatalf("unexpected defaults: cidr %s, azs %d, nat gateways %d", config.VpcCidr(), config.AzCount(), config.NatGatewayCount())
+	}
+	if workload, ok := config.WorkloadSubnets(); !ok || workload.Name != "isolated" {
+		t.Fatalf("unexpected workload subnets %+v", workload)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNetworkConfigPrefersPrivateWorkloadSubnets(t *testing.T) {
+	config := &lib.NetworkConfig{
+		MaxAzs: 3,
+		Subnets: []lib.SubnetConfig{
+			{Name: "ingress", Type: lib.SubnetPublic, CidrMask: 24},
+			{Name: "data", Type: lib.SubnetIsolated, CidrMask: 24},
+			{Name: "apps", Type: lib.SubnetPrivate, CidrMask: 22},
+		},
+	}
+	if workload, ok := config.WorkloadSubnets(); !ok || workload.Name != "apps" {
+		t.Fatalf("unexpected workload subnets %+v", workload)
+	}
+	if config.NatGatewayCount() != 1 {
+		t.Fatalf("expected one NAT gateway, got %d", config.NatGatewayCount())
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNetworkConfigRejectsInvalidLayouts(t *testing.T) {
+	none := 0
+	for name, config := range map[string]lib.NetworkConfig{
+		"host address":   {Cidr: "10.0.0.1/16"},
+		"too large":      {Cidr: "10.0.0.0/8"},
+		"too many azs":   {MaxAzs: 7},
+		"does not fit":   {Cidr: "10.0.0.0/24", MaxAzs: 3, Subnets: []lib.SubnetConfig{{Name: "a", Type: lib.SubnetIsolated, CidrMask: 25}}},
+		"no workloads":   {Subnets: []lib.SubnetConfig{{Name: "ingress", Type: lib.SubnetPublic, CidrMask: 24}}},
+		"unknown type":   {Subnets: []lib.SubnetConfig{{Name: "a", Type: "dmz", CidrMask: 24}}},
+		"duplicate":      {Subnets: []lib.SubnetConfig{{Name: "a", Type: lib.SubnetIsolated, CidrMask: 24}, {Name: "a", Type: lib.SubnetIsolated, CidrMask: 24}}},
+		"private no nat": {NatGateways: &none, Subnets: []lib.SubnetConfig{{Name: "a", Type: lib.SubnetPublic, CidrMask: 24}, {Name: "b", Type: lib.SubnetPrivate, CidrMask: 24}}},
+		"private only":   {Subnets: []lib.SubnetConfig{{Name: "b", Type: lib.SubnetPrivate, CidrMask: 24}}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %s layout to be rejected", name)
+		}
+	}
+}
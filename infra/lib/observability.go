@@ -0,0 +1,24 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// ObservabilityConfig decides who is notified by the alarms of an environment
+type ObservabilityConfig struct {
+	// AlarmSubscribers are email addresses subscribed to the alarm topic
+	AlarmSubscribers []string `json:"alarmSubscribers,omitempty" yaml:"alarmSubscribers,omitempty"`
+}
+
+// Validate checks the subscribers
+func (c *ObservabilityConfig) Validate() error {
+	var errs []error
+	for _, subscriber := range c.AlarmSubscribers {
+		if _, err := mail.ParseAddress(subscriber); err != nil {
+			errs = append(errs, fmt.Errorf("alarm subscriber %q is not an email address", subscriber))
+		}
+	}
+	return errors.Join(errs...)
+}
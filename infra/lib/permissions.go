@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	iamActionPattern    = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9*]+$`)
+	resourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+)
+
+// PermissionStatement allows actions on resources. Resource ARNs may use ${AWS::Partition},
+// ${AWS::Region} and ${AWS::AccountId} for the function's partition, region and account.
+type PermissionStatement struct {
+	Actions   []string `json:"actions" yaml:"actions"`
+	Resources []string `json:"resources" yaml:"resources"`
+}
+
+// HasWildcardResource reports whether a resource of the statement matches more than one name,
+// the indexes readTables grants on a table do not count
+func (s PermissionStatement) HasWildcardResource() bool {
+	for _, resource := range s.Resources {
+		if strings.Contains(resource, "*") && !s.isSubresource(resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubresource reports whether resource is a subresource of another resource of the statement
+func (s PermissionStatement) isSubresource(resource string) bool {
+	for _, subresource := range readTables.subresources {
+		if parent, ok := strings.CutSuffix(resource, subresource); ok && !strings.Contains(parent, "*") && slices.Contains(s.Resources, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// FunctionPermissions are the AWS permissions of a function, which has none unless declared.
+// Names are turned into ARNs in the function's account and region, ARNs are used as they are.
+type FunctionPermissions struct {
+	// ReadTables may read items of DynamoDB tables
+	ReadTables []string `json:"readTables,omitempty" yaml:"readTables,omitempty"`
+
+	// WriteTables may write items of DynamoDB tables
+	WriteTables []string `json:"writeTables,omitempty" yaml:"writeTables,omitempty"`
+
+	// PublishTopics may publish to SNS topics
+	PublishTopics []string `json:"publishTopics,omitempty" yaml:"publishTopics,omitempty"`
+
+	// SendQueues may send messages to SQS queues
+	SendQueues []string `json:"sendQueues,omitempty" yaml:"sendQueues,omitempty"`
+
+	// ReadSecrets may read the values of Secrets Manager secrets
+	ReadSecrets []string `json:"readSecrets,omitempty" yaml:"readSecrets,omitempty"`
+
+	// ReadParameters may read SSM parameters, names start with a slash
+	ReadParameters []string `json:"readParameters,omitempty" yaml:"readParameters,omitempty"`
+
+	// Statements allow any other actions
+	Statements []PermissionStatement `json:"statements,omitempty" yaml:"statements,omitempty"`
+}
+
+// grant is a common permission granted on resources by name
+type grant struct {
+	key     string
+	actions []string
+
+	// arn formats the ARN of a resource name
+	arn string
+
+	// subresources are granted below the ARN of every resource as well
+	subresources []string
+}
+
+var (
+	readTables = grant{"readTables", []string{
+		"dynamodb:BatchGetItem", "dynamodb:ConditionCheckItem", "dynamodb:DescribeTable",
+		"dynamodb:GetItem", "dynamodb:Query", "dynamodb:Scan",
+	}, "arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/%s", []string{"/index/*"}}
+	writeTables = grant{"writeTables", []string{
+		"dynamodb:BatchWriteItem", "dynamodb:DeleteItem", "dynamodb:DescribeTable",
+		"dynamodb:PutItem", "dynamodb:UpdateItem",
+	}, "arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/%s", nil}
+	publishTopics = grant{"publishTopics", []string{
+		"sns:Publish",
+	}, "arn:${AWS::Partition}:sns:${AWS::Region}:${AWS::AccountId}:%s", nil}
+	sendQueues = grant{"sendQueues", []string{
+		"sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:SendMessage",
+	}, "arn:${AWS::Partition}:sqs:${AWS::Region}:${AWS::AccountId}:%s", nil}
+	// Secret ARNs end in a random suffix of six characters
+	readSecrets = grant{"readSecrets", []string{
+		"secretsmanager:DescribeSecret", "secretsmanager:GetSecretValue",
+	}, "arn:${AWS::Partition}:secretsmanager:${AWS::Region}:${AWS::AccountId}:secret:%s-??????", nil}
+	readParameters = grant{"readParameters", []string{
+		"ssm:GetParameter", "ssm:GetParameters",
+	}, "arn:${AWS::Partition}:ssm:${AWS::Region}:${AWS::AccountId}:parameter%s", nil}
+)
+
+// grantedNames are the resource names a common grant is given on
+type grantedNames struct {
+	grant
+	names []string
+}
+
+// grants returns the common grants with the resource names of the permissions
+func (p *FunctionPermissions) grants() []grantedNames {
+	return []grantedNames{
+		{readTables, p.ReadTables},
+		{writeTables, p.WriteTables},
+		{publishTopics, p.PublishTopics},
+		{sendQueues, p.SendQueues},
+		{readSecrets, p.ReadSecrets},
+		{readParameters, p.ReadParameters},
+	}
+}
+
+// PolicyStatements returns a statement per common grant, followed by the declared statements
+func (p *FunctionPermissions) PolicyStatements() []PermissionStatement {
+	var statements []PermissionStatement
+	for _, g := range p.grants() {
+		if len(g.names) == 0 {
+			continue
+		}
+		statement := PermissionStatement{Actions: g.actions}
+		for _, name := range g.names {
+			arn := name
+			if !strings.HasPrefix(name, "arn:") {
+				arn = fmt.Sprintf(g.arn, name)
+			}
+			statement.Resources = append(statement.Resources, arn)
+			for _, subresource := range g.subresources {
+				statement.Resources = append(statement.Resources, arn+subresource)
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return append(statements, p.Statements...)
+}
+
+// Validate checks the resource names of the grants and the actions and resources of the statements
+func (p *FunctionPermissions) Validate() error {
+	var errs []error
+
+	for _, g := range p.grants() {
+		for _, name := range g.names {
+			switch {
+			case strings.HasPrefix(name, "arn:"):
+			case g.key == readParameters.key && !strings.HasPrefix(name, "/"):
+				errs = append(errs, fmt.Errorf("permission %s %q must be a parameter name starting with a slash or an ARN", g.key, name))
+			case !resourceNamePattern.MatchString(name):
+				errs = append(errs, fmt.Errorf("permission %s %q must be a name or an ARN", g.key, name))
+			}
+		}
+	}
+
+	for i, statement := range p.Statements {
+		if len(statement.Actions) == 0 || len(statement.Resources) == 0 {
+			errs = append(errs, fmt.Errorf("permission statement %d needs actions and resources", i+1))
+		}
+		for _, action := range statement.Actions {
+			if !iamActionPattern.MatchString(action) {
+				errs = append(errs, fmt.Errorf("permission statement %d has invalid action %q, use service:Action", i+1, action))
+			}
+		}
+		for _, resource := range statement.Resources {
+			if resource != "*" && !strings.HasPrefix(resource, "arn:") {
+				errs = append(errs, fmt.Errorf("permission statement %d has invalid resource %q, use an ARN or *", i+1, resource))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
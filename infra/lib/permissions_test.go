@@ -0,0 +1,58 @@
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-infra-sandbox/lib"
+)
+
+func TestFunctionPermissionsPolicyStatements(t *testing.T) {
+	permissions := &lib.FunctionPermissions{
+		ReadTables:     []string{"users"},
+		PublishTopics:  []string{"arn:aws:sns:eu-central-1:123456789012:events"},
+		ReadParameters: []string{"/app/config"},
+		Statements: []lib.PermissionStatement{
+			{Actions: []string{"lambda:GetAccountSettings"}, Resources: []string{"*"}},
+		},
+	}
+	if err := permissions.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	statements := permissions.PolicyStatements()
+	if len(statements) != 4 {
+		t.Fatalf("expected 4 statements, got %+v", statements)
+	}
+	if !reflect.DeepEqual(statements[0].Resources, []string{
+		"arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/users",
+		"arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/users/index/*",
+	}) {
+		t.Errorf("unexpected table resources %v", statements[0].Resources)
+	}
+	if !reflect.DeepEqual(statements[1].Actions, []string{"sns:Publish"}) || statements[1].Resources[0] != "arn:aws:sns:eu-central-1:123456789012:events" {
+		t.Errorf("unexpected topic statement %+v", statements[1])
+	}
+	if statements[2].Resources[0] != "arn:${AWS::Partition}:ssm:${AWS::Region}:${AWS::AccountId}:parameter/app/config" {
+		t.Errorf("unexpected parameter resources %v", statements[2].Resources)
+	}
+	for i, statement := range statements {
+		if statement.HasWildcardResource() != (i == 3) {
+			t.Errorf("unexpected wildcard of statement %d %+v", i, statement)
+		}
+	}
+}
+
+func TestFunctionPermissionsRejectsInvalidPermissions(t *testing.T) {
+	for name, permissions := range map[string]lib.FunctionPermissions{
+		"table name":        {ReadTables: []string{"users table"}},
+		"relative param":    {ReadParameters: []string{"app/config"}},
+		"missing resources": {Statements: []lib.PermissionStatement{{Actions: []string{"s3:GetObject"}}}},
+		"invalid action":    {Statements: []lib.PermissionStatement{{Actions: []string{"GetObject"}, Resources: []string{"*"}}}},
+		"invalid resource":  {Statements: []lib.PermissionStatement{{Actions: []string{"s3:GetObject"}, Resources: []string{"my-bucket"}}}},
+	} {
+		if err := permissions.Validate(); err == nil {
+			t.Errorf("expected %s permissions to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentKind classifies an environment and drives naming and lifecycle defaults
+type EnvironmentKind string
+
+const (
+	KindDevelopment EnvironmentKind = "development"
+	KindPR          EnvironmentKind = "pr"
+	KindStaging     EnvironmentKind = "staging"
+	KindProduction  EnvironmentKind = "production"
+)
+
+// knownKinds lists every supported environment kind
+var knownKinds = []EnvironmentKind{KindDevelopment, KindPR, KindStaging, KindProduction}
+
+// IsValid reports whether the kind is one of the supported environment kinds
+func (k EnvironmentKind) IsValid() bool {
+	for _, known := range knownKinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Sizing contains the default compute sizing for container workloads
+type Sizing struct {
+	DesiredCount int `json:"desiredCount,omitempty" yaml:"desiredCount,omitempty"`
+	Cpu          int `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	MemoryMiB    int `json:"memoryMiB,omitempty" yaml:"memoryMiB,omitempty"`
+}
+
+// RetentionPolicy controls how long data and logs outlive an environment
+type RetentionPolicy struct {
+	// Number of days to keep CloudWatch logs, 0 uses the default of the environment kind
+	LogRetentionDays int `json:"logRetentionDays,omitempty" yaml:"logRetentionDays,omitempty"`
+
+	// What happens to stateful resources on stack deletion: destroy, retain or snapshot
+	RemovalPolicy string `json:"removalPolicy,omitempty" yaml:"removalPolicy,omitempty"`
+}
+
+// CdkRemovalPolicy maps the configured removal policy to its CDK equivalent
+func (r RetentionPolicy) CdkRemovalPolicy() awscdk.RemovalPolicy {
+	switch r.RemovalPolicy {
+	case "retain":
+		return awscdk.RemovalPolicy_RETAIN
+	case "snapshot":
+		return awscdk.RemovalPolicy_SNAPSHOT
+	default:
+		return awscdk.RemovalPolicy_DESTROY
+	}
+}
+
+// CdkLogRemovalPolicy returns the removal policy of log groups, which are retained instead of
+// snapshotted
+func (r RetentionPolicy) CdkLogRemovalPolicy() awscdk.RemovalPolicy {
+	if policy := r.CdkRemovalPolicy(); policy != awscdk.RemovalPolicy_SNAPSHOT {
+		return policy
+	}
+	return awscdk.RemovalPolicy_RETAIN
+}
+
+// Profile declares everything that differs between environments
+type Profile struct {
+	Kind    EnvironmentKind `json:"kind" yaml:"kind"`
+	Account string          `json:"account,omitempty" yaml:"account,omitempty"`
+	Region  string          `json:"region,omitempty" yaml:"region,omitempty"`
+
+	// CostCenter the environment's resources are billed to
+	CostCenter string `json:"costCenter,omitempty" yaml:"costCenter,omitempty"`
+
+	// Budget alerts on the cost of the environment, see BudgetConfig
+	Budget *BudgetConfig `json:"budget,omitempty" yaml:"budget,omitempty"`
+
+	// Template for the environment's domain prefix, supports {name}, {username} and {pr}
+	DomainPrefix string `json:"domainPrefix,omitempty" yaml:"domainPrefix,omitempty"`
+
+	// DomainStrategy names the built-in strategy for hostnames, "env-prefixed" or "apex-for-production"
+	DomainStrategy string `json:"domainStrategy,omitempty" yaml:"domainStrategy,omitempty"`
+
+	// Hostnames overrides the hostnames of individual applications and adds aliases
+	Hostnames map[string]HostnameOverride `json:"hostnames,omitempty" yaml:"hostnames,omitempty"`
+
+	// DNSSEC signs the environment's zone, see DNSSECConfig
+	DNSSEC *DNSSECConfig `json:"dnssec,omitempty" yaml:"dnssec,omitempty"`
+
+	// Email creates an SES identity for the environment domain, see EmailConfig
+	Email *EmailConfig `json:"email,omitempty" yaml:"email,omitempty"`
+
+	// Failover health checks the public endpoints, see FailoverConfig
+	Failover *FailoverConfig `json:"failover,omitempty" yaml:"failover,omitempty"`
+
+	// Observability subscribes to the alarms of the environment, see ObservabilityConfig
+	Observability *ObservabilityConfig `json:"observability,omitempty" yaml:"observability,omitempty"`
+
+	// Encryption opts data stores into the environment's customer managed keys, see EncryptionConfig
+	Encryption *EncryptionConfig `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+
+	// Network is the VPC shared by the environment's stacks, see NetworkConfig. Stacks that
+	// run in a VPC need it.
+	Network *NetworkConfig `json:"network,omitempty" yaml:"network,omitempty"`
+
+	// Deploy decides which GitHub Actions workflow runs may deploy the environment, see DeployTrust
+	Deploy *DeployTrust `json:"deploy,omitempty" yaml:"deploy,omitempty"`
+
+	// TTL after which an ephemeral environment may be reaped, e.g. "72h" or "7d"
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	Sizing    Sizing          `json:"sizing,omitempty" yaml:"sizing,omitempty"`
+	Retention RetentionPolicy `json:"retention,omitempty" yaml:"retention,omitempty"`
+
+	// Names of the stacks deployed into this environment
+	Stacks []string `json:"stacks,omitempty" yaml:"stacks,omitempty"`
+}
+
+// CdkLogRetention returns the log retention of the profile, defaulting to the retention of its
+// environment kind. Logs of unknown kinds are kept forever.
+func (p Profile) CdkLogRetention() awslogs.RetentionDays {
+	days := p.Retention.LogRetentionDays
+	if days == 0 {
+		days = DefaultLogRetentionDays[p.Kind]
+	}
+	if retention, ok := logRetentionDays[days]; ok {
+		return retention
+	}
+	return awslogs.RetentionDays_INFINITE
+}
+
+// IsEphemeral reports whether environments of this kind are short-lived and may expire
+func (k EnvironmentKind) IsEphemeral() bool {
+	return k == KindDevelopment || k == KindPR
+}
+
+// ParseTTL parses a duration such as "90m", "72h" or "7d"
+func ParseTTL(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid ttl %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q", value)
+	}
+	return ttl, nil
+}
+
+// Domains returns the domain strategy declared by the profile
+func (p Profile) Domains() (DomainStrategy, error) {
+	strategy, err := DomainStrategyByName(p.DomainStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Hostnames) > 0 {
+		strategy = CustomHostnames{Base: strategy, Overrides: p.Hostnames}
+	}
+	return strategy, nil
+}
+
+// ProfileSet holds all environment profiles keyed by environment name
+type ProfileSet struct {
+	Environments map[string]Profile `json:"environments" yaml:"environments"`
+}
+
+var (
+	accountPattern      = regexp.MustCompile(`^[0-9]{12}$`)
+	regionPattern       = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-[0-9]$`)
+	costCenterPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	domainPrefixPattern = regexp.MustCompile(`^[a-z0-9{}]([a-z0-9{}-]*[a-z0-9{}])?$`)
+	placeholderPattern  = regexp.MustCompile(`\{[^}]*\}`)
+	hostnamePattern     = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// DefaultLogRetentionDays is the log retention of profiles that do not set logRetentionDays
+var DefaultLogRetentionDays = map[EnvironmentKind]int{
+	KindDevelopment: 7,
+	KindPR:          3,
+	KindStaging:     30,
+	KindProduction:  365,
+}
+
+// logRetentionDays maps the retention periods supported by CloudWatch Logs to their CDK equivalent
+var logRetentionDays = map[int]awslogs.RetentionDays{
+	1: awslogs.RetentionDays_ONE_DAY, 3: awslogs.RetentionDays_THREE_DAYS, 5: awslogs.RetentionDays_FIVE_DAYS,
+	7: awslogs.RetentionDays_ONE_WEEK, 14: awslogs.RetentionDays_TWO_WEEKS, 30: awslogs.RetentionDays_ONE_MONTH,
+	60: awslogs.RetentionDays_TWO_MONTHS, 90: awslogs.RetentionDays_THREE_MONTHS, 120: awslogs.RetentionDays_FOUR_MONTHS,
+	150: awslogs.RetentionDays_FIVE_MONTHS, 180: awslogs.RetentionDays_SIX_MONTHS, 365: awslogs.RetentionDays_ONE_YEAR,
+	400: awslogs.RetentionDays_THIRTEEN_MONTHS, 545: awslogs.RetentionDays_EIGHTEEN_MONTHS, 731: awslogs.RetentionDays_TWO_YEARS,
+	1096: awslogs.RetentionDays_THREE_YEARS, 1827: awslogs.RetentionDays_FIVE_YEARS, 2192: awslogs.RetentionDays_SIX_YEARS,
+	2557: awslogs.RetentionDays_SEVEN_YEARS, 2922: awslogs.RetentionDays_EIGHT_YEARS, 3288: awslogs.RetentionDays_NINE_YEARS,
+	3653: awslogs.RetentionDays_TEN_YEARS,
+}
+
+// LoadProfiles reads environment profiles from a YAML or JSON file
+func LoadProfiles(path string) (*ProfileSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading environment profiles: %w", err)
+	}
+	profiles, err := ParseProfiles(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// ParseProfiles decodes and validates environment profiles, the format is chosen by file extension
+func ParseProfiles(data []byte, ext string) (*ProfileSet, error) {
+	var profiles ProfileSet
+
+	switch strings.ToLower(ext) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&profiles); err != nil {
+			return nil, fmt.Errorf("malformed environment profiles: %w", err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&profiles); err != nil {
+			return nil, fmt.Errorf("malformed environment profiles: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported environment profile format %q", ext)
+	}
+
+	if err := profiles.Validate(); err != nil {
+		return nil, err
+	}
+	return &profiles, nil
+}
+
+// Names returns the declared environment names in sorted order
+func (p *ProfileSet) Names() []string {
+	names := make([]string, 0, len(p.Environments))
+	for name := range p.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the profile for the named environment
+func (p *ProfileSet) Get(name string) (Profile, error) {
+	profile, ok := p.Environments[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown environment %q (known environments: %s)", name, strings.Join(p.Names(), ", "))
+	}
+	return profile, nil
+}
+
+// CheckAccount makes sure a production environment is never pointed at an account
+// that is declared for development or PR environments
+func (p *ProfileSet) CheckAccount(env Environment) error {
+	if env.Kind != KindProduction || env.Account == "" {
+		return nil
+	}
+
+	for _, name := range p.Names() {
+		profile := p.Environments[name]
+		if profile.Kind != KindDevelopment && profile.Kind != KindPR {
+			continue
+		}
+		if profile.Account == env.Account {
+			return fmt.Errorf("production environment %q must not target account %s, which belongs to %s environment %q",
+				env.Name, env.Account, profile.Kind, name)
+		}
+	}
+	return nil
+}
+
+// Validate checks every profile in the set and reports all problems at once
+func (p *ProfileSet) Validate() error {
+	if len(p.Environments) == 0 {
+		return errors.New("no environments declared")
+	}
+
+	var errs []error
+	for _, name := range p.Names() {
+		if err := p.Environments[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("environment %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks a single profile for malformed values
+func (p Profile) Validate() error {
+	var errs []error
+
+	if !p.Kind.IsValid() {
+		errs = append(errs, fmt.Errorf("unsupported kind %q", p.Kind))
+	}
+	if p.Account != "" && !accountPattern.MatchString(p.Account) {
+		errs = append(errs, fmt.Errorf("account %q must be a 12 digit AWS account ID", p.Account))
+	}
+	if p.Region != "" && !regionPattern.MatchString(p.Region) {
+		errs = append(errs, fmt.Errorf("region %q is not a valid AWS region", p.Region))
+	}
+	if p.CostCenter != "" && !costCenterPattern.MatchString(p.CostCenter) {
+		errs = append(errs, fmt.Errorf("cost center %q must be lowercase letters, digits and hyphens", p.CostCenter))
+	}
+	if p.DomainPrefix != "" {
+		if !domainPrefixPattern.MatchString(p.DomainPrefix) {
+			errs = append(errs, fmt.Errorf("domain prefix %q must be a lowercase DNS label", p.DomainPrefix))
+		}
+		for _, placeholder := range placeholderPattern.FindAllString(p.DomainPrefix, -1) {
+			switch placeholder {
+			case "{name}", "{username}", "{pr}":
+			default:
+				errs = append(errs, fmt.Errorf("domain prefix %q uses unknown placeholder %s", p.DomainPrefix, placeholder))
+			}
+		}
+	}
+
+	if _, err := DomainStrategyByName(p.DomainStrategy); err != nil {
+		errs = append(errs, err)
+	}
+	for app, override := range p.Hostnames {
+		for _, hostname := range append([]string{override.Hostname}, override.Aliases...) {
+			if hostname != "" && !hostnamePattern.MatchString(hostname) {
+				errs = append(errs, fmt.Errorf("hostname %q of %s must be a lowercase DNS name", hostname, app))
+			}
+		}
+	}
+
+	if p.DNSSEC != nil {
+		if err := p.DNSSEC.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Email != nil {
+		if err := p.Email.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Failover != nil {
+		if err := p.Failover.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Budget != nil {
+		if err := p.Budget.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Observability != nil {
+		if err := p.Observability.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Network != nil {
+		if err := p.Network.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Deploy != nil {
+		if err := p.Deploy.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if p.TTL != "" {
+		if _, err := ParseTTL(p.TTL); err != nil {
+			errs = append(errs, err)
+		} else if !p.Kind.IsEphemeral() {
+			errs = append(errs, fmt.Errorf("ttl is only supported for development and pr environments, not %s", p.Kind))
+		}
+	}
+
+	if err := p.Sizing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := p.Retention.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	seen := map[string]bool{}
+	for _, stack := range p.Stacks {
+		if strings.TrimSpace(stack) == "" {
+			errs = append(errs, errors.New("stack names must not be empty"))
+			continue
+		}
+		if seen[stack] {
+			errs = append(errs, fmt.Errorf("stack %q listed more than once", stack))
+		}
+		seen[stack] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks that sizing values describe a valid Fargate task
+func (s Sizing) Validate() error {
+	if s.DesiredCount < 0 {
+		return fmt.Errorf("desired count %d must not be negative", s.DesiredCount)
+	}
+	if s.Cpu == 0 && s.MemoryMiB == 0 {
+		return nil
+	}
+	if !validFargateSize(s.Cpu, s.MemoryMiB) {
+		return fmt.Errorf("cpu %d with memory %d MiB is not a supported Fargate task size", s.Cpu, s.MemoryMiB)
+	}
+	return nil
+}
+
+// validFargateSize reports whether the cpu and memory combination is supported by Fargate
+func validFargateSize(cpu, memoryMiB int) bool {
+	switch cpu {
+	case 256:
+		return memoryMiB == 512 || memoryMiB == 1024 || memoryMiB == 2048
+	case 512:
+		return memoryMiB >= 1024 && memoryMiB <= 4096 && memoryMiB%1024 == 0
+	case 1024:
+		return memoryMiB >= 2048 && memoryMiB <= 8192 && memoryMiB%1024 == 0
+	case 2048:
+		return memoryMiB >= 4096 && memoryMiB <= 16384 && memoryMiB%1024 == 0
+	case 4096:
+		return memoryMiB >= 8192 && memoryMiB <= 30720 && memoryMiB%1024 == 0
+	}
+	return false
+}
+
+// Validate checks the retention values against what AWS supports
+func (r RetentionPolicy) Validate() error {
+	var errs []error
+	if _, ok := logRetentionDays[r.LogRetentionDays]; r.LogRetentionDays != 0 && !ok {
+		errs = append(errs, fmt.Errorf("log retention of %d days is not supported by CloudWatch Logs", r.LogRetentionDays))
+	}
+	switch r.RemovalPolicy {
+	case "", "destroy", "retain", "snapshot":
+	default:
+		errs = append(errs, fmt.Errorf("removal policy %q must be one of destroy, retain or snapshot", r.RemovalPolicy))
+	}
+	return errors.Join(errs...)
+}
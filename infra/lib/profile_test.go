@@ -0,0 +1,102 @@
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+
+	"aws-infra-sandbox/lib"
+)
+
+const testProfiles = `
+environments:
+  development:
+    kind: development
+    account: "111111111111"
+    region: eu-central-1
+    domainPrefix: d-{username}
+  pr:
+    kind: pr
+    domainPrefix: pr-{pr}
+    stacks: [core, lambda]
+  staging:
+    kind: staging
+    domainPrefix: staging
+  production:
+    kind: production
+    account: "123456789012"
+    domainPrefix: production
+    sizing:
+      cpu: 512
+      memoryMiB: 1024
+    retention:
+      logRetentionDays: 365
+      removalPolicy: retain
+`
+
+func mustParseProfiles(t *testing.T) *lib.ProfileSet {
+	t.Helper()
+	profiles, err := lib.ParseProfiles([]byte(testProfiles), ".yaml")
+	if err != nil {
+		t.Fatalf("unexpected error parsing profiles: %v", err)
+	}
+	return profiles
+}
+
+func TestParseProfilesYAMLAndJSON(t *testing.T) {
+	profiles := mustParseProfiles(t)
+	if got := profiles.Names(); strings.Join(got, ",") != "development,pr,production,staging" {
+		t.Fatalf("unexpected environment names: %v", got)
+	}
+
+	json := `{"environments": {"staging": {"kind": "staging", "region": "us-east-1"}}}`
+	profiles, err := lib.ParseProfiles([]byte(json), ".json")
+	if err != nil {
+		t.Fatalf("unexpected error parsing JSON profiles: %v", err)
+	}
+	if profile, _ := profiles.Get("staging"); profile.Region != "us-east-1" {
+		t.Fatalf("expected region us-east-1, got %q", profile.Region)
+	}
+}
+
+func TestParseProfilesRejectsMalformedProfiles(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":     "environments:\n  dev:\n    kind: development\n    colour: blue\n",
+		"unknown kind":      "environments:\n  dev:\n    kind: sandbox\n",
+		"bad account":       "environments:\n  dev:\n    kind: development\n    account: \"1234\"\n",
+		"bad sizing":        "environments:\n  dev:\n    kind: development\n    sizing:\n      cpu: 256\n      memoryMiB: 4096\n",
+		"bad retention":     "environments:\n  dev:\n    kind: development\n    retention:\n      logRetentionDays: 10\n",
+		"bad placeholder":   "environments:\n  dev:\n    kind: development\n    domainPrefix: d-{user}\n",
+		"no environments":   "environments: {}\n",
+		"duplicated stacks": "environments:\n  dev:\n    kind: development\n    stacks: [core, core]\n",
+		"dnssec keys":       "environments:\n  dev:\n    kind: development\n    dnssec:\n      keyNames: [a, b, c]\n",
+		"mail from":         "environments:\n  dev:\n    kind: development\n    email:\n      mailFromSubdomain: mail.from\n",
+		"unknown strategy":  "environments:\n  dev:\n    kind: development\n    domainStrategy: apex\n",
+		"bad hostname":      "environments:\n  dev:\n    kind: development\n    hostnames:\n      vault:\n        aliases: [Vault.ebbo.dev]\n",
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := lib.ParseProfiles([]byte(data), ".yaml"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestProfileLogRetentionDefaultsToKind(t *testing.T) {
+	for _, tc := range []struct {
+		profile lib.Profile
+		want    awslogs.RetentionDays
+	}{
+		{lib.Profile{Kind: lib.KindPR}, awslogs.RetentionDays_THREE_DAYS},
+		{lib.Profile{Kind: lib.KindProduction}, awslogs.RetentionDays_ONE_YEAR},
+		{lib.Profile{Kind: lib.KindProduction, Retention: lib.RetentionPolicy{LogRetentionDays: 731}}, awslogs.RetentionDays_TWO_YEARS},
+		{lib.Profile{}, awslogs.RetentionDays_INFINITE},
+	} {
+		if got := tc.profile.CdkLogRetention(); got != tc.want {
+			t.Errorf("%+v: expected %s, got %s", tc.profile, tc.want, got)
+		}
+	}
+}
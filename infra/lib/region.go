@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// GlobalRegion is the only region some global services accept resources from, such as the KMS
+// keys of DNSSEC key-signing keys, CloudFront certificates and Route 53 health check metrics
+const GlobalRegion = "us-east-1"
+
+// GlobalStackTag marks the stacks created by NewGlobalStack, reapers of other regions delete them
+// once their environment expires
+const GlobalStackTag = "x:global-stack"
+
+// NeedsGlobalStack reports whether resources that only work in GlobalRegion have to be created
+// in a stack of their own, because a stack with props is deployed to another region
+func NeedsGlobalStack(props awscdk.StackProps) bool {
+	return props.Env != nil && props.Env.Region != nil && *props.Env.Region != GlobalRegion
+}
+
+// NewGlobalStack creates a stack in GlobalRegion of the account of props, which stacks of
+// other regions may reference
+func NewGlobalStack(scope constructs.Construct, id string, props awscdk.StackProps) awscdk.Stack {
+	stack := awscdk.NewStack(scope, jsii.String(id), &awscdk.StackProps{
+		Env: &awscdk.Environment{
+			Account: props.Env.Account,
+			Region:  jsii.String(GlobalRegion),
+		},
+		CrossRegionReferences: jsii.Bool(true),
+	})
+	awscdk.Tags_Of(stack).Add(jsii.String(GlobalStackTag), jsii.String("true"), nil)
+	return stack
+}
@@ -0,0 +1,242 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+)
+
+// StackFactoryProps is passed to every registered stack factory
+type StackFactoryProps struct {
+	awscdk.StackProps
+	Environment  Environment
+	DomainConfig *DomainConfig
+
+	// Dependencies holds the stacks named in DependsOn, which have already been created
+	Dependencies map[string]BuiltStack
+}
+
+// BuiltStack is a stack created by a factory. Stacks sharing resources with the stacks
+// depending on them return a struct that holds those resources next to the CDK stack.
+type BuiltStack interface {
+	CdkStack() awscdk.Stack
+}
+
+// plainStack is a BuiltStack that shares nothing with other stacks
+type plainStack struct {
+	stack awscdk.Stack
+}
+
+func (s plainStack) CdkStack() awscdk.Stack {
+	return s.stack
+}
+
+// PlainStack wraps a stack that shares no resources with other stacks
+func PlainStack(stack awscdk.Stack) BuiltStack {
+	if stack == nil {
+		return nil
+	}
+	return plainStack{stack: stack}
+}
+
+// StackFactory creates a registered stack, or returns every problem that keeps it from being created
+type StackFactory func(scope constructs.Construct, id string, props *StackFactoryProps) (BuiltStack, error)
+
+// StackRegistration describes a stack that environments can select by name
+type StackRegistration struct {
+	// Name selects the stack in environment profiles and the "stacks" context value
+	Name string
+
+	// Suffix is passed to Environment.GetStackName to build the stack name, e.g. "CoreStack"
+	Suffix string
+
+	// DependsOn names the stacks that are created before this one and deployed first
+	DependsOn []string
+
+	// Validate reports every problem of the props before any stack is created, so the problems
+	// of all selected stacks are reported together. Dependencies are not set yet. Optional.
+	Validate func(props *StackFactoryProps) error
+
+	New StackFactory
+}
+
+// StackRegistry holds the stacks the app knows how to build
+type StackRegistry struct {
+	mu     sync.Mutex
+	stacks map[string]StackRegistration
+}
+
+// NewStackRegistry returns an empty registry
+func NewStackRegistry() *StackRegistry {
+	return &StackRegistry{stacks: map[string]StackRegistration{}}
+}
+
+// defaultRegistry is filled by the stack packages when they are imported
+var defaultRegistry = NewStackRegistry()
+
+// RegisterStack adds a stack to the default registry, it is meant to be called from
+// the init function of a stack package and panics on invalid or duplicate registrations
+func RegisterStack(registration StackRegistration) {
+	defaultRegistry.Register(registration)
+}
+
+// RegisteredStacks returns the names of all stacks in the default registry
+func RegisteredStacks() []string {
+	return defaultRegistry.Names()
+}
+
+// BuildStacks creates the named stacks from the default registry, see StackRegistry.Build
+func BuildStacks(scope constructs.Construct, names []string, props StackFactoryProps) (map[string]BuiltStack, error) {
+	return defaultRegistry.Build(scope, names, props)
+}
+
+// Register adds a stack to the registry and panics on invalid or duplicate registrations
+func (r *StackRegistry) Register(registration StackRegistration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if registration.Name == "" || registration.Suffix == "" {
+		panic("lib: stack registration needs a name and a suffix")
+	}
+	if registration.New == nil {
+		panic(fmt.Sprintf("lib: stack %q registered without a factory", registration.Name))
+	}
+	if _, ok := r.stacks[registration.Name]; ok {
+		panic(fmt.Sprintf("lib: stack %q registered twice", registration.Name))
+	}
+	r.stacks[registration.Name] = registration
+}
+
+// Names returns the names of all registered stacks in alphabetical order
+func (r *StackRegistry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.namesLocked()
+}
+
+// Resolve returns the named stacks together with all stacks they depend on, ordered so
+// that every stack comes after its dependencies. No names selects every registered stack.
+func (r *StackRegistry) Resolve(names []string) ([]StackRegistration, error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ordered []StackRegistration
+	state := map[string]int{} // 1 while visiting, 2 once ordered
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		registration, ok := r.stacks[name]
+		if !ok {
+			if len(path) > 0 {
+				return fmt.Errorf("stack %q depends on unknown stack %q", path[len(path)-1], name)
+			}
+			return fmt.Errorf("unknown stack %q (known stacks: %s)", name, strings.Join(r.namesLocked(), ", "))
+		}
+
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("stack dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case 2:
+			return nil
+		}
+
+		state[name] = 1
+		dependencies := append([]string(nil), registration.DependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, registration)
+		return nil
+	}
+
+	selected := append([]string(nil), names...)
+	sort.Strings(selected)
+	for _, name := range selected {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Build creates the named stacks and their dependencies below scope. Each stack is
+// named with props.Environment.GetStackName and depends on the stacks it declared. Every
+// stack is validated before the first one is created and the problems of all stacks are
+// returned together. Stacks whose dependencies fail to be created are skipped.
+func (r *StackRegistry) Build(scope constructs.Construct, names []string, props StackFactoryProps) (map[string]BuiltStack, error) {
+	registrations, err := r.Resolve(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, registration := range registrations {
+		if registration.Validate == nil {
+			continue
+		}
+		stackProps := props
+		if err := registration.Validate(&stackProps); err != nil {
+			errs = append(errs, fmt.Errorf("stack %q: %w", registration.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	stacks := map[string]BuiltStack{}
+	failed := map[string]bool{}
+	for _, registration := range registrations {
+		stackProps := props
+		stackProps.Dependencies = map[string]BuiltStack{}
+		skip := false
+		for _, dependency := range registration.DependsOn {
+			stackProps.Dependencies[dependency] = stacks[dependency]
+			skip = skip || failed[dependency]
+		}
+		if skip {
+			failed[registration.Name] = true
+			continue
+		}
+
+		stack, err := registration.New(scope, props.Environment.GetStackName(registration.Suffix), &stackProps)
+		if err == nil && (stack == nil || stack.CdkStack() == nil) {
+			err = errors.New("could not be created")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stack %q: %w", registration.Name, err))
+			failed[registration.Name] = true
+			continue
+		}
+		for _, dependency := range registration.DependsOn {
+			stack.CdkStack().AddDependency(stacks[dependency].CdkStack(), nil)
+		}
+		stacks[registration.Name] = stack
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return stacks, nil
+}
+
+func (r *StackRegistry) namesLocked() []string {
+	names := make([]string, 0, len(r.stacks))
+	for name := range r.stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,156 @@
+package lib_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+
+	"aws-infra-sandbox/lib"
+)
+
+// newTestRegistry registers core, lambda and vaultwarden stacks that only record their creation
+func newTestRegistry(created *[]string) *lib.StackRegistry {
+	registry := lib.NewStackRegistry()
+	register := func(name, suffix string, dependsOn ...string) {
+		registry.Register(lib.StackRegistration{
+			Name:      name,
+			Suffix:    suffix,
+			DependsOn: dependsOn,
+			New: func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+				for _, dependency := range dependsOn {
+					if props.Dependencies[dependency] == nil {
+						panic("dependency " + dependency + " was not created before " + name)
+					}
+				}
+				*created = append(*created, id)
+				return lib.PlainStack(awscdk.NewStack(scope, &id, &props.StackProps)), nil
+			},
+		})
+	}
+	register("vaultwarden", "VaultwardenStack", "core")
+	register("lambda", "LambdaStack", "core")
+	register("core", "CoreStack")
+	return registry
+}
+
+func TestStackRegistryBuildsDependencies(t *testing.T) {
+	var created []string
+	registry := newTestRegistry(&created)
+
+	env := lib.Environment{Name: "pr", Kind: lib.KindPR, PRNumber: "7", IsPR: true}
+	stacks, err := registry.Build(awscdk.NewApp(nil), []string{"lambda"}, lib.StackFactoryProps{Environment: env})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Join(created, ",") != "pr-7-core-stack,pr-7-lambda-stack" {
+		t.Fatalf("expected core to be created before lambda, got %v", created)
+	}
+	if _, ok := stacks["vaultwarden"]; ok {
+		t.Fatal("vaultwarden was not selected and must not be built")
+	}
+	dependencies := stacks["lambda"].CdkStack().Dependencies()
+	if dependencies == nil || len(*dependencies) != 1 || *(*dependencies)[0].StackName() != "pr-7-core-stack" {
+		t.Fatal("expected the lambda stack to depend on the core stack")
+	}
+}
+
+func TestStackRegistryBuildsEverythingByDefault(t *testing.T) {
+	var created []string
+	registry := newTestRegistry(&created)
+
+	env := lib.Environment{Name: "staging", Kind: lib.KindStaging}
+	if _, err := registry.Build(awscdk.NewApp(nil), nil, lib.StackFactoryProps{Environment: env}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(created, ",") != "s-core-stack,s-lambda-stack,s-vaultwarden-stack" {
+		t.Fatalf("expected every stack in dependency order, got %v", created)
+	}
+}
+
+func TestStackRegistryRejectsUnknownStacks(t *testing.T) {
+	var created []string
+	registry := newTestRegistry(&created)
+
+	_, err := registry.Resolve([]string{"core", "database"})
+	if err == nil || !strings.Contains(err.Error(), `unknown stack "database" (known stacks: core, lambda, vaultwarden)`) {
+		t.Fatalf("expected unknown stack error, got %v", err)
+	}
+}
+
+func TestStackRegistryRejectsCycles(t *testing.T) {
+	registry := lib.NewStackRegistry()
+	factory := func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+		return lib.PlainStack(awscdk.NewStack(scope, &id, &props.StackProps)), nil
+	}
+	registry.Register(lib.StackRegistration{Name: "a", Suffix: "A", DependsOn: []string{"b"}, New: factory})
+	registry.Register(lib.StackRegistration{Name: "b", Suffix: "B", DependsOn: []string{"a"}, New: factory})
+
+	_, err := registry.Resolve([]string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "stack dependency cycle: a -> b -> a") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestStackRegistryReportsAllFailures(t *testing.T) {
+	registry := lib.NewStackRegistry()
+	var created []string
+	factory := func(err error) lib.StackFactory {
+		return func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, id)
+			return lib.PlainStack(awscdk.NewStack(scope, &id, &props.StackProps)), nil
+		}
+	}
+	registry.Register(lib.StackRegistration{Name: "core", Suffix: "CoreStack", New: factory(errors.New("network cidr is invalid"))})
+	registry.Register(lib.StackRegistration{Name: "lambda", Suffix: "LambdaStack", DependsOn: []string{"core"}, New: factory(nil)})
+	registry.Register(lib.StackRegistration{Name: "reaper", Suffix: "ReaperStack", New: factory(errors.New("reaper needs a staging environment"))})
+	registry.Register(lib.StackRegistration{Name: "web", Suffix: "WebStack", New: factory(nil)})
+
+	env := lib.Environment{Name: "staging", Kind: lib.KindStaging}
+	_, err := registry.Build(awscdk.NewApp(nil), nil, lib.StackFactoryProps{Environment: env})
+	if err == nil || err.Error() != "stack \"core\": network cidr is invalid\nstack \"reaper\": reaper needs a staging environment" {
+		t.Fatalf("expected the errors of core and reaper, got %v", err)
+	}
+
+	// Stacks depending on a failed stack are skipped, independent ones are still built
+	if strings.Join(created, ",") != "s-web-stack" {
+		t.Fatalf("expected only web to be created, got %v", created)
+	}
+}
+
+func TestStackRegistryValidatesEveryStackBeforeBuilding(t *testing.T) {
+	registry := lib.NewStackRegistry()
+	var created []string
+	factory := func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+		created = append(created, id)
+		return lib.PlainStack(awscdk.NewStack(scope, &id, &props.StackProps)), nil
+	}
+	validate := func(err error) func(props *lib.StackFactoryProps) error {
+		return func(props *lib.StackFactoryProps) error {
+			if props.Dependencies != nil {
+				t.Error("dependencies must not be set while validating")
+			}
+			return err
+		}
+	}
+	registry.Register(lib.StackRegistration{Name: "core", Suffix: "CoreStack", Validate: validate(errors.New("network cidr is invalid")), New: factory})
+	registry.Register(lib.StackRegistration{Name: "vaultwarden", Suffix: "VaultwardenStack", DependsOn: []string{"core"}, Validate: validate(errors.New("lifecycle policy of 5 days is not supported")), New: factory})
+	registry.Register(lib.StackRegistration{Name: "web", Suffix: "WebStack", Validate: validate(nil), New: factory})
+
+	env := lib.Environment{Name: "staging", Kind: lib.KindStaging}
+	_, err := registry.Build(awscdk.NewApp(nil), nil, lib.StackFactoryProps{Environment: env})
+
+	// The problems of a stack are reported even if a stack it depends on is invalid too
+	if err == nil || err.Error() != "stack \"core\": network cidr is invalid\nstack \"vaultwarden\": lifecycle policy of 5 days is not supported" {
+		t.Fatalf("expected the errors of core and vaultwarden, got %v", err)
+	}
+	if len(created) != 0 {
+		t.Fatalf("expected no stack to be created, got %v", created)
+	}
+}
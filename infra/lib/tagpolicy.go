@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// TagRule declares a tag that is allowed on resources and the values it may take
+type TagRule struct {
+	Key string
+
+	// Required makes the tag mandatory on every taggable resource
+	Required bool
+
+	// RequiredFor makes the tag mandatory only in environments of these kinds
+	RequiredFor []EnvironmentKind
+
+	// Pattern the tag value must match, nil allows any value
+	Pattern *regexp.Regexp
+}
+
+// requiredIn reports whether the tag is mandatory in an environment of the given kind
+func (r TagRule) requiredIn(kind EnvironmentKind) bool {
+	if r.Required {
+		return true
+	}
+	for _, k := range r.RequiredFor {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// TagPolicy declares which tags resources must and may carry and enforces them at synth time
+type TagPolicy struct {
+	Rules []TagRule
+
+	// AllowedPrefixes lists key prefixes that may be used without a rule, e.g. tags added by CDK itself
+	AllowedPrefixes []string
+
+	kind       EnvironmentKind
+	mu         sync.Mutex
+	violations []string
+}
+
+// DefaultTagPolicy returns the tag policy used for all environments
+func DefaultTagPolicy() *TagPolicy {
+	return &TagPolicy{
+		Rules: []TagRule{
+			{Key: "Environment", Required: true, Pattern: regexp.MustCompile(`^[a-z][a-z0-9-]*$`)},
+			{Key: "Owner", Required: true, Pattern: regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)},
+			{Key: "CostCenter", Required: true, Pattern: regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)},
+			{Key: "ManagedBy", Required: true, Pattern: regexp.MustCompile(`^CDK$`)},
+			{Key: "PR", RequiredFor: []EnvironmentKind{KindPR}, Pattern: prNumberPattern},
+			{Key: "Version", Pattern: semverPattern},
+			{Key: "CommitSha", Pattern: commitSHAPattern},
+			{Key: "Dirty", Pattern: regexp.MustCompile(`^(true|false)$`)},
+			{Key: "BuildTime", Pattern: regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}Z$`)},
+			{Key: "ExpiresAt", RequiredFor: []EnvironmentKind{KindDevelopment, KindPR}, Pattern: regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}Z$`)},
+			{Key: "Name"},
+		},
+		AllowedPrefixes: []string{"aws-cdk:", "x:"},
+	}
+}
+
+// Apply tags every resource below scope with the environment's tags and registers an aspect
+// that records every resource violating the policy and fails its synthesis with an error
+// annotation, so the CDK toolkit refuses to deploy the cloud assembly
+func (p *TagPolicy) Apply(scope constructs.IConstruct, env Environment) {
+	p.kind = env.kind()
+
+	for key, value := range env.Tags() {
+		awscdk.Tags_Of(scope).Add(jsii.String(key), jsii.String(value), nil)
+	}
+
+	// Run after all mutating aspects, such as tags, have been applied
+	awscdk.Aspects_Of(scope).Add(&tagPolicyAspect{policy: p}, &awscdk.AspectOptions{
+		Priority: awscdk.AspectPriority_READONLY(),
+	})
+}
+
+// Check returns a description of every way the tags violate the policy
+func (p *TagPolicy) Check(tags map[string]string) []string {
+	var problems []string
+
+	rules := map[string]TagRule{}
+	for _, rule := range p.Rules {
+		rules[rule.Key] = rule
+		value, ok := tags[rule.Key]
+		if !ok {
+			if rule.requiredIn(p.kind) {
+				problems = append(problems, fmt.Sprintf("missing required tag %s", rule.Key))
+			}
+			continue
+		}
+		if rule.Pattern != nil && !rule.Pattern.MatchString(value) {
+			problems = append(problems, fmt.Sprintf("tag %s has invalid value %q", rule.Key, value))
+		}
+	}
+
+	for key := range tags {
+		if _, ok := rules[key]; !ok && !p.hasAllowedPrefix(key) {
+			problems = append(problems, fmt.Sprintf("tag %s is not allowed", key))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+func (p *TagPolicy) hasAllowedPrefix(key string) bool {
+	for _, prefix := range p.AllowedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns all violations recorded during synthesis, or nil if every resource complied
+func (p *TagPolicy) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.violations) == 0 {
+		return nil
+	}
+	violations := append([]string(nil), p.violations...)
+	sort.Strings(violations)
+	return fmt.Errorf("tag policy violations:\n  %s", strings.Join(violations, "\n  "))
+}
+
+func (p *TagPolicy) record(path string, problems []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, problem := range problems {
+		p.violations = append(p.violations, path+": "+problem)
+	}
+}
+
+// tagPolicyAspect visits every taggable CloudFormation resource and checks its tags
+type tagPolicyAspect struct {
+	policy *TagPolicy
+}
+
+func (a *tagPolicyAspect) Visit(node constructs.IConstruct) {
+	if !*awscdk.CfnResource_IsCfnResource(node) {
+		return
+	}
+	tagManager := awscdk.TagManager_Of(node)
+	if tagManager == nil {
+		return
+	}
+
+	tags := map[string]string{}
+	if values := tagManager.TagValues(); values != nil {
+		for key, value := range *values {
+			tags[key] = *value
+		}
+	}
+
+	problems := a.policy.Check(tags)
+	for _, problem := range problems {
+		awscdk.Annotations_Of(node).AddError(jsii.String("tag policy: " + problem))
+	}
+	if len(problems) > 0 {
+		a.policy.record(*node.Node().Path(), problems)
+	}
+}
@@ -0,0 +1,88 @@
+package lib_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+)
+
+func synthWithTagPolicy(env lib.Environment, extraTags map[string]string) (awscdk.Stack, error) {
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("TagStack"), nil)
+	awssqs.NewQueue(stack, jsii.String("Queue"), nil)
+
+	for key, value := range extraTags {
+		awscdk.Tags_Of(stack).Add(jsii.String(key), jsii.String(value), nil)
+	}
+
+	policy := lib.DefaultTagPolicy()
+	policy.Apply(app, env)
+	app.Synth(nil)
+	return stack, policy.Err()
+}
+
+func TestTagPolicyAcceptsCompliantResources(t *testing.T) {
+	env := lib.Environment{
+		Name:      "pr",
+		Kind:      lib.KindPR,
+		PRNumber:  "42",
+		Username:  "alice",
+		ExpiresAt: time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC),
+		Profile:   lib.Profile{Kind: lib.KindPR, CostCenter: "engineering"},
+	}
+
+	stack, err := synthWithTagPolicy(env, map[string]string{"x:stack": "test"})
+	if err != nil {
+		t.Fatalf("unexpected tag policy error: %v", err)
+	}
+	assertions.Annotations_FromStack(stack).HasNoError(jsii.String("*"), assertions.Match_AnyValue())
+}
+
+func TestTagPolicyReportsOffendingResources(t *testing.T) {
+	// No cost center, no expiry and an unknown tag
+	env := lib.Environment{
+		Name:     "pr",
+		Kind:     lib.KindPR,
+		PRNumber: "42",
+		Username: "alice",
+	}
+
+	stack, err := synthWithTagPolicy(env, map[string]string{"Team": "platform"})
+	if err == nil {
+		t.Fatal("expected tag policy violations")
+	}
+	// The toolkit refuses to deploy an assembly with error annotations
+	assertions.Annotations_FromStack(stack).HasError(jsii.String("/TagStack/Queue/Resource"),
+		assertions.Match_StringLikeRegexp(jsii.String("tag policy: missing required tag CostCenter")))
+	for _, expected := range []string{
+		"TagStack/Queue/Resource: missing required tag CostCenter",
+		"TagStack/Queue/Resource: missing required tag ExpiresAt",
+		"TagStack/Queue/Resource: tag Team is not allowed",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
+
+func TestTagPolicyCheckValidatesValues(t *testing.T) {
+	policy := lib.DefaultTagPolicy()
+	problems := policy.Check(map[string]string{
+		"Environment": "staging",
+		"Owner":       "alice",
+		"CostCenter":  "engineering",
+		"ManagedBy":   "CDK",
+		"Version":     "latest",
+	})
+
+	if len(problems) != 1 || !strings.Contains(problems[0], `tag Version has invalid value "latest"`) {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+}
@@ -0,0 +1,185 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// StackName is the name of the bootstrap stack, it is deployed once per account
+const StackName = "github-bootstrap-stack"
+
+// DefaultQualifier is the qualifier of the CDK bootstrap stack created by `cdk bootstrap`
+const DefaultQualifier = "hnb659fds"
+
+// bootstrapRoles are the roles of the CDK bootstrap stack the CLI assumes to deploy
+var bootstrapRoles = []string{"deploy-role", "file-publishing-role", "image-publishing-role", "lookup-role"}
+
+type BootstrapStackProps struct {
+	awscdk.StackProps
+
+	// Profiles get a deploy role each if they have deploy trust and target the stack's account
+	Profiles *lib.ProfileSet
+
+	// Repository is the GitHub repository as owner/name whose workflows may deploy
+	Repository string
+
+	// Qualifier of the CDK bootstrap stack, defaults to DefaultQualifier
+	Qualifier string
+
+	// OIDCProviderArn imports an existing GitHub OIDC provider, an account can only have one
+	// provider per URL. A provider is created when it is not set.
+	OIDCProviderArn string
+}
+
+// BootstrapStack lets GitHub Actions deploy the environments of an account
+type BootstrapStack struct {
+	Stack awscdk.Stack
+
+	// Provider is the GitHub OIDC provider trusted by the deploy roles
+	Provider awsiam.IOidcProvider
+
+	// DeployRoles are the deploy roles by environment name
+	DeployRoles map[string]awsiam.Role
+}
+
+// CdkStack returns the underlying CDK stack
+func (s *BootstrapStack) CdkStack() awscdk.Stack {
+	return s.Stack
+}
+
+// Validate reports every problem of the props
+func (p *BootstrapStackProps) Validate() error {
+	if p == nil {
+		return errors.New("bootstrap stack props must be set")
+	}
+	var errs []error
+	if p.Profiles == nil {
+		errs = append(errs, errors.New("bootstrap stack needs the environment profiles"))
+	}
+	if err := lib.ValidateGitHubRepository(p.Repository); err != nil {
+		errs = append(errs, fmt.Errorf("invalid github repository: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// NewBootstrapStack creates the GitHub OIDC provider and a deploy role per environment. Each
+// role is only trusted by the workflow runs of the environment's DeployTrust and may do
+// nothing but assume the CDK bootstrap roles of the environment's account and regions.
+func NewBootstrapStack(scope constructs.Construct, id string, props *BootstrapStackProps) (*BootstrapStack, error) {
+	if err := props.Validate(); err != nil {
+		return nil, err
+	}
+	stack := awscdk.NewStack(scope, &id, &props.StackProps)
+
+	qualifier := props.Qualifier
+	if qualifier == "" {
+		qualifier = DefaultQualifier
+	}
+
+	var provider awsiam.IOidcProvider
+	if props.OIDCProviderArn != "" {
+		provider = awsiam.OidcProviderNative_FromOidcProviderArn(stack, jsii.String("GitHubOidcProvider"), jsii.String(props.OIDCProviderArn))
+	} else {
+		provider = awsiam.NewOidcProviderNative(stack, jsii.String("GitHubOidcProvider"), &awsiam.OidcProviderNativeProps{
+			Url:       jsii.String(lib.GitHubOIDCProviderURL),
+			ClientIds: jsii.Strings(lib.GitHubOIDCAudience),
+		})
+	}
+
+	var account string
+	if props.Env != nil && props.Env.Account != nil {
+		account = *props.Env.Account
+	}
+
+	issuer := strings.TrimPrefix(lib.GitHubOIDCProviderURL, "https://")
+	roles := map[string]awsiam.Role{}
+	for _, name := range props.Profiles.Names() {
+		profile, _ := props.Profiles.Get(name)
+		if profile.Deploy == nil {
+			continue
+		}
+		// Environments in other accounts get their roles from the bootstrap stack of that account
+		if profile.Account != "" && profile.Account != account {
+			continue
+		}
+
+		role := awsiam.NewRole(stack, jsii.String(constructID(name)+"DeployRole"), &awsiam.RoleProps{
+			RoleName:    jsii.String(naming.Role.Format("github-deploy", name)),
+			Description: jsii.String(fmt.Sprintf("Deploys the %s environment from GitHub Actions workflows of %s", name, props.Repository)),
+			AssumedBy: awsiam.NewOpenIdConnectPrincipal(provider, &map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					issuer + ":aud": lib.GitHubOIDCAudience,
+				},
+				"StringLike": map[string]interface{}{
+					issuer + ":sub": profile.Deploy.Subjects(props.Repository),
+				},
+			}),
+			MaxSessionDuration: awscdk.Duration_Hours(jsii.Number(1)),
+		})
+		role.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Actions:   jsii.Strings("sts:AssumeRole"),
+			Resources: bootstrapRoleArns(qualifier, profile),
+		}))
+		roles[name] = role
+
+		awscdk.NewCfnOutput(stack, jsii.String(constructID(name)+"DeployRoleArn"), &awscdk.CfnOutputProps{
+			Description: jsii.String(fmt.Sprintf("Role GitHub Actions assumes to deploy the %s environment", name)),
+			Value:       role.RoleArn(),
+		})
+	}
+
+	if len(roles) == 0 {
+		awscdk.Annotations_Of(stack).AddWarningV2(jsii.String("aws-infra-sandbox:noDeployRoles"),
+			jsii.String(fmt.Sprintf("no environment with deploy trust targets account %s, the stack creates no deploy roles", account)))
+	}
+
+	return &BootstrapStack{
+		Stack:       stack,
+		Provider:    provider,
+		DeployRoles: roles,
+	}, nil
+}
+
+// bootstrapRoleArns returns the CDK bootstrap roles of the regions profile deploys to. Stacks
+// that need CloudFront or Route 53 health checks are deployed to us-east-1 as well.
+func bootstrapRoleArns(qualifier string, profile lib.Profile) *[]*string {
+	account := "${AWS::AccountId}"
+	if profile.Account != "" {
+		account = profile.Account
+	}
+	regions := []string{"${AWS::Region}"}
+	if profile.Region != "" {
+		regions = []string{profile.Region}
+	}
+	if (profile.DNSSEC != nil || profile.Failover != nil) && profile.Region != lib.GlobalRegion {
+		regions = append(regions, lib.GlobalRegion)
+	}
+
+	var arns []*string
+	for _, region := range regions {
+		for _, role := range bootstrapRoles {
+			arns = append(arns, awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:${AWS::Partition}:iam::%s:role/cdk-%s-%s-%s-%s", account, qualifier, role, account, region)), nil))
+		}
+	}
+	return &arns
+}
+
+// constructID turns an environment name such as "pr" or "load-test" into "Pr" or "LoadTest"
+func constructID(name string) string {
+	var id strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return id.String()
+}
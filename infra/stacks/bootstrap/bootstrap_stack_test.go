@@ -0,0 +1,158 @@
+package bootstrap_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/stacks/bootstrap"
+)
+
+func testProfiles() *lib.ProfileSet {
+	return &lib.ProfileSet{Environments: map[string]lib.Profile{
+		"development": {
+			Kind:    lib.KindDevelopment,
+			Account: "111111111111",
+			Region:  "eu-central-1",
+			Deploy:  &lib.DeployTrust{PullRequests: true, Branches: []string{"feature/*"}},
+		},
+		"staging": {
+			Kind:   lib.KindStaging,
+			Region: "eu-central-1",
+			Deploy: &lib.DeployTrust{Branches: []string{"main"}},
+		},
+		"production": {
+			Kind:     lib.KindProduction,
+			Region:   "eu-central-1",
+			Failover: &lib.FailoverConfig{},
+			Deploy:   &lib.DeployTrust{Tags: []string{"v*"}, GitHubEnvironments: []string{"production"}},
+		},
+		"local": {
+			Kind: lib.KindDevelopment,
+		},
+	}}
+}
+
+func TestBootstrapStackCreatesDeployRolesOfTheAccount(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	// WHEN
+	stack, err := bootstrap.NewBootstrapStack(app, bootstrap.StackName, &bootstrap.BootstrapStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Profiles:   testProfiles(),
+		Repository: "ebbo/aws-infra-sandbox",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN
+	if len(stack.DeployRoles) != 2 || stack.DeployRoles["staging"] == nil || stack.DeployRoles["production"] == nil {
+		t.Fatalf("expected deploy roles for staging and production, got %v", stack.DeployRoles)
+	}
+
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::IAM::OIDCProvider"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::IAM::OIDCProvider"), map[string]interface{}{
+		"Url":          lib.GitHubOIDCProviderURL,
+		"ClientIdList": []interface{}{lib.GitHubOIDCAudience},
+	})
+	template.ResourceCountIs(jsii.String("AWS::IAM::Role"), jsii.Number(2))
+	template.HasResourceProperties(jsii.String("AWS::IAM::Role"), map[string]interface{}{
+		"RoleName": "github-deploy-production",
+		"AssumeRolePolicyDocument": assertions.Match_ObjectLike(&map[string]interface{}{
+			"Statement": []interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Action": "sts:AssumeRoleWithWebIdentity",
+					"Condition": map[string]interface{}{
+						"StringEquals": map[string]interface{}{
+							"token.actions.githubusercontent.com:aud": "sts.amazonaws.com",
+						},
+						"StringLike": map[string]interface{}{
+							"token.actions.githubusercontent.com:sub": []interface{}{
+								"repo:ebbo/aws-infra-sandbox:ref:refs/tags/v*",
+								"repo:ebbo/aws-infra-sandbox:environment:production",
+							},
+						},
+					},
+				}),
+			},
+		}),
+	})
+
+	// The only permission is assuming the bootstrap roles, including us-east-1 for failover
+	template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), map[string]interface{}{
+		"PolicyDocument": map[string]interface{}{
+			"Statement": []interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Action": "sts:AssumeRole",
+					"Resource": assertions.Match_ArrayWith(&[]interface{}{
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-hnb659fds-deploy-role-${AWS::AccountId}-eu-central-1"},
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-hnb659fds-deploy-role-${AWS::AccountId}-us-east-1"},
+					}),
+				}),
+			},
+			"Version": "2012-10-17",
+		},
+	})
+
+	template.HasOutput(jsii.String("StagingDeployRoleArn"), map[string]interface{}{})
+	template.HasOutput(jsii.String("ProductionDeployRoleArn"), map[string]interface{}{})
+}
+
+func TestBootstrapStackImportsAnExistingProvider(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	// WHEN
+	stack, err := bootstrap.NewBootstrapStack(app, bootstrap.StackName, &bootstrap.BootstrapStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("111111111111"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Profiles:        testProfiles(),
+		Repository:      "ebbo/aws-infra-sandbox",
+		Qualifier:       "custom",
+		OIDCProviderArn: "arn:aws:iam::111111111111:oidc-provider/token.actions.githubusercontent.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::IAM::OIDCProvider"), jsii.Number(0))
+	template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), map[string]interface{}{
+		"PolicyDocument": assertions.Match_ObjectLike(&map[string]interface{}{
+			"Statement": []interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Resource": assertions.Match_ArrayWith(&[]interface{}{
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:iam::111111111111:role/cdk-custom-lookup-role-111111111111-eu-central-1"},
+					}),
+				}),
+			},
+		}),
+	})
+	template.HasOutput(jsii.String("DevelopmentDeployRoleArn"), map[string]interface{}{})
+}
+
+func TestBootstrapStackReportsEveryPropsProblem(t *testing.T) {
+	_, err := bootstrap.NewBootstrapStack(awscdk.NewApp(nil), bootstrap.StackName, &bootstrap.BootstrapStackProps{
+		Repository: "aws-infra-sandbox",
+	})
+	if err == nil || !strings.Contains(err.Error(), "needs the environment profiles") || !strings.Contains(err.Error(), "invalid github repository") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
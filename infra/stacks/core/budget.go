@@ -0,0 +1,155 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsbudgets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsce"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// newCostAlerts creates a monthly budget and, if configured, a cost anomaly monitor of the
+// environment's cost tags, and returns the topic both alert
+func newCostAlerts(scope constructs.Construct, config *lib.BudgetConfig, env lib.Environment) awssns.ITopic {
+	topic := awssns.NewTopic(scope, jsii.String("CostAlerts"), &awssns.TopicProps{
+		DisplayName: jsii.String(fmt.Sprintf("Cost alerts of the %s environment", env.Name)),
+	})
+	topic.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid: jsii.String("AllowCostAlerts"),
+		Principals: &[]awsiam.IPrincipal{
+			awsiam.NewServicePrincipal(jsii.String("budgets.amazonaws.com"), nil),
+			awsiam.NewServicePrincipal(jsii.String("costalerts.amazonaws.com"), nil),
+		},
+		Actions:   jsii.Strings("SNS:Publish"),
+		Resources: jsii.Strings(*topic.TopicArn()),
+	}))
+	for _, subscriber := range config.Subscribers {
+		topic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(subscriber), nil))
+	}
+
+	subscribers := []interface{}{&awsbudgets.CfnBudget_SubscriberProperty{
+		SubscriptionType: jsii.String("SNS"),
+		Address:          topic.TopicArn(),
+	}}
+	notification := func(notificationType string, threshold float64) interface{} {
+		return &awsbudgets.CfnBudget_NotificationWithSubscribersProperty{
+			Notification: &awsbudgets.CfnBudget_NotificationProperty{
+				NotificationType:   jsii.String(notificationType),
+				ComparisonOperator: jsii.String("GREATER_THAN"),
+				Threshold:          jsii.Number(threshold),
+				ThresholdType:      jsii.String("PERCENTAGE"),
+			},
+			Subscribers: subscribers,
+		}
+	}
+	var notifications []interface{}
+	for _, threshold := range config.ActualThresholds() {
+		notifications = append(notifications, notification("ACTUAL", threshold))
+	}
+	notifications = append(notifications, notification("FORECASTED", 100))
+
+	var filters []interface{}
+	for _, tag := range costTagExpressions(env) {
+		filters = append(filters, &awsbudgets.CfnBudget_ExpressionProperty{
+			Tags: &awsbudgets.CfnBudget_TagValuesProperty{
+				Key:          jsii.String(tag.Tags.Key),
+				Values:       jsii.Strings(tag.Tags.Values...),
+				MatchOptions: jsii.Strings(tag.Tags.MatchOptions...),
+			},
+		})
+	}
+	filter := &awsbudgets.CfnBudget_ExpressionProperty{And: filters}
+	if len(filters) == 1 {
+		filter = filters[0].(*awsbudgets.CfnBudget_ExpressionProperty)
+	}
+
+	awsbudgets.NewCfnBudget(scope, jsii.String("Budget"), &awsbudgets.CfnBudgetProps{
+		Budget: &awsbudgets.CfnBudget_BudgetDataProperty{
+			BudgetName: jsii.String(env.ResourceName(naming.Budget, "monthly")),
+			BudgetType: jsii.String("COST"),
+			TimeUnit:   jsii.String("MONTHLY"),
+			BudgetLimit: &awsbudgets.CfnBudget_SpendProperty{
+				Amount: jsii.Number(config.MonthlyLimit),
+				Unit:   jsii.String("USD"),
+			},
+			FilterExpression: filter,
+		},
+		NotificationsWithSubscribers: notifications,
+	})
+
+	if config.AnomalyThreshold > 0 {
+		monitor := awsce.NewCfnAnomalyMonitor(scope, jsii.String("CostAnomalyMonitor"), &awsce.CfnAnomalyMonitorProps{
+			MonitorName:          jsii.String(env.ResourceName(naming.AnomalyMonitor, "cost")),
+			MonitorType:          jsii.String("CUSTOM"),
+			MonitorSpecification: jsii.String(costExpression(costTagExpressions(env))),
+		})
+		threshold, _ := json.Marshal(map[string]interface{}{
+			"Dimensions": map[string]interface{}{
+				"Key":          "ANOMALY_TOTAL_IMPACT_ABSOLUTE",
+				"MatchOptions": []string{"GREATER_THAN_OR_EQUAL"},
+				"Values":       []string{fmt.Sprintf("%g", config.AnomalyThreshold)},
+			},
+		})
+		awsce.NewCfnAnomalySubscription(scope, jsii.String("CostAnomalySubscription"), &awsce.CfnAnomalySubscriptionProps{
+			SubscriptionName: jsii.String(env.ResourceName(naming.AnomalyMonitor, "cost-alerts")),
+			// Anomalies are only published to SNS as they are detected
+			Frequency:           jsii.String("IMMEDIATE"),
+			MonitorArnList:      jsii.Strings(*monitor.AttrMonitorArn()),
+			ThresholdExpression: jsii.String(string(threshold)),
+			Subscribers: []interface{}{&awsce.CfnAnomalySubscription_SubscriberProperty{
+				Type:    jsii.String("SNS"),
+				Address: topic.TopicArn(),
+			}},
+		})
+	}
+
+	return topic
+}
+
+// tagExpression is a Cost Explorer expression matching one tag value
+type tagExpression struct {
+	Tags struct {
+		Key          string
+		Values       []string
+		MatchOptions []string
+	}
+}
+
+// costTagExpressions returns one expression per cost tag of the environment, ordered by key
+func costTagExpressions(env lib.Environment) []tagExpression {
+	tags := env.CostTags()
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	expressions := make([]tagExpression, 0, len(keys))
+	for _, key := range keys {
+		var expression tagExpression
+		expression.Tags.Key = key
+		expression.Tags.Values = []string{tags[key]}
+		expression.Tags.MatchOptions = []string{"EQUALS"}
+		expressions = append(expressions, expression)
+	}
+	return expressions
+}
+
+// costExpression renders the expressions as the JSON the anomaly monitor expects
+func costExpression(expressions []tagExpression) string {
+	var value interface{} = map[string]interface{}{"And": expressions}
+	if len(expressions) == 1 {
+		value = expressions[0]
+	}
+	rendered, _ := json.Marshal(value)
+	return string(rendered)
+}
@@ -1,8 +1,14 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 
@@ -11,14 +17,88 @@ import (
 
 type CoreStackProps struct {
 	awscdk.StackProps
-	Environment lib.Environment
+	Environment  lib.Environment
 	DomainConfig *lib.DomainConfig
 }
 
-func NewCoreStack(scope constructs.Construct, id string, props *CoreStackProps) awscdk.Stack {
-	var sprops awscdk.StackProps
-	if props != nil {
-		sprops = props.StackProps
+// CoreStack holds the resources the core stack shares with the stacks that depend on it
+type CoreStack struct {
+	Stack awscdk.Stack
+
+	// HostedZone is the environment's own zone, delegated from the root domain
+	HostedZone awsroute53.IHostedZone
+
+	// Certificate is the *.<environment domain> wildcard certificate, it is used by every
+	// endpoint of the environment whose hostname it covers
+	Certificate awscertificatemanager.ICertificate
+
+	// Email is the SES identity of the environment domain, nil unless email is enabled
+	Email *EmailIdentity
+
+	// Maintenance is the page public endpoints fail over to, nil unless failover is enabled
+	Maintenance *MaintenanceSite
+
+	// Keys are the customer managed KMS keys the stacks of the environment encrypt with
+	Keys *EnvironmentKeys
+
+	// Observability is the alarm topic, dashboard and log retention of the environment
+	Observability *Observability
+
+	// Network is the VPC of the environment, nil unless the profile declares a network
+	Network *EnvironmentNetwork
+
+	// CostAlerts receives the budget and cost anomaly alerts of the environment, nil unless the
+	// profile declares a budget. Stacks may publish their own cost related alerts to it.
+	CostAlerts awssns.ITopic
+}
+
+// CdkStack returns the CloudFormation stack
+func (s *CoreStack) CdkStack() awscdk.Stack {
+	return s.Stack
+}
+
+// domainConfig returns the domain config from the props or the default
+func (p *CoreStackProps) domainConfig() *lib.DomainConfig {
+	if p.DomainConfig == nil {
+		return lib.DefaultDomainConfig()
+	}
+	return p.DomainConfig
+}
+
+// Validate reports every problem of the domain and of the parts of the profile the core stack
+// creates, such as the network, the budget and the alarm subscribers
+func (p *CoreStackProps) Validate() error {
+	if p == nil {
+		return errors.New("core stack props must be set")
+	}
+
+	errs := []error{p.domainConfig().Validate(p.Environment)}
+	profile := p.Environment.Profile
+	if profile.Network != nil {
+		errs = append(errs, profile.Network.Validate())
+	}
+	if profile.Budget != nil {
+		errs = append(errs, profile.Budget.Validate())
+	}
+	if profile.Observability != nil {
+		errs = append(errs, profile.Observability.Validate())
+	}
+	errs = append(errs, profile.Retention.Validate())
+	return errors.Join(errs...)
+}
+
+// NewCoreStack creates the zone, certificate, keys, network and observability of an environment,
+// it returns the problems of the props instead when they are invalid
+func NewCoreStack(scope constructs.Construct, id string, props *CoreStackProps) (*CoreStack, error) {
+	if err := props.Validate(); err != nil {
+		return nil, err
+	}
+	sprops := props.StackProps
+	domainConfig := props.domainConfig()
+
+	// DNSSEC signing keys and the maintenance site in us-east-1 are referenced across regions
+	if (domainConfig.DNSSEC != nil || domainConfig.Failover != nil) && lib.NeedsGlobalStack(sprops) {
+		sprops.CrossRegionReferences = jsii.Bool(true)
 	}
 	stack := awscdk.NewStack(scope, &id, &sprops)
 
@@ -27,29 +107,199 @@ func NewCoreStack(scope constructs.Construct, id string, props *CoreStackProps)
 		Value: jsii.String(props.Environment.Username),
 	})
 
-	// Use domain config from props or default
-	domainConfig := props.DomainConfig
-	if domainConfig == nil {
-		domainConfig = lib.DefaultDomainConfig()
-	}
-
-	// Create a hosted zone for ebbo.dev if it doesn't exist
-	// We're using the existing hosted zone with ID from the domain config
-	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.HostedZoneAttributes{
+	// Reference the existing root zone from the domain config, it holds the NS delegation
+	// of the environment's zone
+	rootZone := awsroute53.PublicHostedZone_FromPublicHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.PublicHostedZoneAttributes{
 		HostedZoneId: jsii.String(domainConfig.HostedZoneId),
 		ZoneName:     jsii.String(domainConfig.RootDomain),
 	})
 
+	// Create a child zone per environment, so an environment can only change its own records.
+	// Environments served from the root domain itself use the root zone.
+	environmentDomain := domainConfig.GetEnvironmentDomain(props.Environment)
+	hostedZone := rootZone
+	var delegation constructs.IConstruct
+	if !domainConfig.IsApex(props.Environment) {
+		environmentZone := awsroute53.NewPublicHostedZone(stack, jsii.String("EnvironmentZone"), &awsroute53.PublicHostedZoneProps{
+			ZoneName: jsii.String(environmentDomain),
+			Comment:  jsii.String(fmt.Sprintf("Records of the %s environment", props.Environment.Name)),
+		})
+		if props.Environment.Profile.Retention.RemovalPolicy == "retain" {
+			environmentZone.ApplyRemovalPolicy(awscdk.RemovalPolicy_RETAIN)
+		}
+		hostedZone = environmentZone
+
+		// Delegate the environment domain to the child zone. The delegation is removed with the
+		// stack; across accounts a custom resource assumes the delegation role of the root account.
+		if domainConfig.DelegationRoleArn != "" {
+			delegation = awsroute53.NewCrossAccountZoneDelegationRecord(stack, jsii.String("ZoneDelegation"), &awsroute53.CrossAccountZoneDelegationRecordProps{
+				DelegatedZone:      environmentZone,
+				ParentHostedZoneId: jsii.String(domainConfig.HostedZoneId),
+				DelegationRole:     awsiam.Role_FromRoleArn(stack, jsii.String("DelegationRole"), jsii.String(domainConfig.DelegationRoleArn), nil),
+				RemovalPolicy:      awscdk.RemovalPolicy_DESTROY,
+			})
+		} else {
+			delegation = awsroute53.NewZoneDelegationRecord(stack, jsii.String("ZoneDelegation"), &awsroute53.ZoneDelegationRecordProps{
+				Zone:        rootZone,
+				RecordName:  jsii.String(environmentDomain),
+				NameServers: environmentZone.HostedZoneNameServers(),
+			})
+		}
+	}
+
 	// Output the hosted zone ID
 	awscdk.NewCfnOutput(stack, jsii.String("HostedZoneId"), &awscdk.CfnOutputProps{
 		Value: hostedZone.HostedZoneId(),
 	})
 
 	// Output the environment domain
-	environmentDomain := domainConfig.GetEnvironmentDomain(props.Environment)
 	awscdk.NewCfnOutput(stack, jsii.String("EnvironmentDomain"), &awscdk.CfnOutputProps{
 		Value: jsii.String(environmentDomain),
 	})
 
-	return stack
+	// Create the one wildcard certificate of the environment, dependent stacks use it for their endpoints
+	certificate := awscertificatemanager.NewCertificate(stack, jsii.String("WildcardCertificate"), &awscertificatemanager.CertificateProps{
+		DomainName: jsii.String(fmt.Sprintf("*.%s", environmentDomain)),
+		Validation: awscertificatemanager.CertificateValidation_FromDns(hostedZone),
+	})
+
+	// DNS validation only succeeds once the child zone is reachable through the delegation
+	if delegation != nil {
+		certificate.Node().AddDependency(delegation)
+	}
+
+	awscdk.NewCfnOutput(stack, jsii.String("WildcardCertificateArn"), &awscdk.CfnOutputProps{
+		Value: certificate.CertificateArn(),
+	})
+
+	// Create the declared records, with a TXT record naming their owner next to each name
+	records, err := lib.MergeDNSRecords(domainConfig.Records)
+	if err != nil {
+		return nil, err
+	}
+	owned := map[string]bool{}
+	for _, record := range records {
+		fqdn := record.FQDN(environmentDomain)
+		var values []*string
+		for _, value := range record.ZoneValues() {
+			values = append(values, jsii.String(value))
+		}
+		awsroute53.NewRecordSet(stack, jsii.String(fmt.Sprintf("Record-%s-%s", record.Type, fqdn)), &awsroute53.RecordSetProps{
+			Zone:       hostedZone,
+			RecordName: jsii.String(fqdn),
+			RecordType: awsroute53.RecordType(record.Type),
+			Target:     awsroute53.RecordTarget_FromValues(values...),
+			Ttl:        awscdk.Duration_Seconds(jsii.Number(float64(record.TTL))),
+		})
+
+		if !owned[fqdn] {
+			owned[fqdn] = true
+			awsroute53.NewTxtRecord(stack, jsii.String("Owner-"+fqdn), &awsroute53.TxtRecordProps{
+				Zone:       hostedZone,
+				RecordName: jsii.String(lib.DNSOwnershipPrefix + "." + fqdn),
+				Values:     jsii.Strings(lib.DNSOwnershipValue(props.Environment, *stack.StackName())),
+				Ttl:        awscdk.Duration_Seconds(jsii.Number(lib.DefaultDNSRecordTTL)),
+			})
+		}
+	}
+
+	// Sign the zone, Route 53 only accepts signing keys from us-east-1
+	if domainConfig.DNSSEC != nil {
+		var keyScope constructs.Construct = stack
+		if lib.NeedsGlobalStack(sprops) {
+			keyScope = newDnssecKeyStack(scope, sprops, props.Environment)
+		} else if *awscdk.Token_IsUnresolved(stack.Region()) {
+			awscdk.Annotations_Of(stack).AddWarningV2(jsii.String("aws-infra-sandbox:dnssecRegion"),
+				jsii.String("DNSSEC signing keys must be in us-east-1, give the stack an explicit region"))
+		}
+		dsRecords := enableDnssec(stack, keyScope, hostedZone.HostedZoneId(), domainConfig.DNSSEC, props.Environment)
+
+		var values []*string
+		for i := range domainConfig.DNSSEC.Keys() {
+			ds := dsRecords.GetResponseField(jsii.String(fmt.Sprintf("KeySigningKeys.%d.DSRecord", i)))
+			values = append(values, ds)
+			awscdk.NewCfnOutput(stack, jsii.String(fmt.Sprintf("DsRecord%d", i+1)), &awscdk.CfnOutputProps{
+				Description: jsii.String("DS record of a key-signing key, to be published in the parent zone or at the registrar"),
+				Value: awscdk.Fn_Join(jsii.String(": "), &[]*string{
+					dsRecords.GetResponseField(jsii.String(fmt.Sprintf("KeySigningKeys.%d.Name", i))),
+					ds,
+				}),
+			})
+		}
+
+		// Complete the chain of trust of delegated zones in the root zone of the same account
+		if !domainConfig.IsApex(props.Environment) && domainConfig.DelegationRoleArn == "" {
+			awsroute53.NewDsRecord(stack, jsii.String("DsDelegation"), &awsroute53.DsRecordProps{
+				Zone:       rootZone,
+				RecordName: jsii.String(environmentDomain),
+				Values:     &values,
+				Ttl:        awscdk.Duration_Seconds(jsii.Number(lib.DefaultDNSRecordTTL)),
+			})
+		}
+	}
+
+	// Verify the environment domain with SES so its stacks can send mail
+	var email *EmailIdentity
+	if domainConfig.Email != nil {
+		email = newEmailIdentity(stack, hostedZone, environmentDomain, domainConfig.Email, props.Environment)
+		if delegation != nil {
+			email.Identity.Node().AddDependency(delegation)
+		}
+		awscdk.NewCfnOutput(stack, jsii.String("EmailIdentityName"), &awscdk.CfnOutputProps{
+			Value: email.Identity.EmailIdentityName(),
+		})
+	}
+
+	// Serve a maintenance page that the public endpoints fail over to, CloudFront and the
+	// metrics of Route 53 health checks require us-east-1
+	var maintenance *MaintenanceSite
+	if domainConfig.Failover != nil {
+		if lib.NeedsGlobalStack(sprops) {
+			maintenanceStack := newMaintenanceStack(scope, sprops, props.Environment)
+			maintenanceStack.AddDependency(stack, jsii.String("the maintenance page is served from the environment's zone"))
+			maintenance = newMaintenanceSite(maintenanceStack, hostedZone, environmentDomain, nil, domainConfig.Failover, props.Environment)
+		} else {
+			if *awscdk.Token_IsUnresolved(stack.Region()) {
+				awscdk.Annotations_Of(stack).AddWarningV2(jsii.String("aws-infra-sandbox:maintenanceRegion"),
+					jsii.String("The maintenance page and health check alarms must be in us-east-1, give the stack an explicit region"))
+			}
+			maintenance = newMaintenanceSite(stack, hostedZone, environmentDomain, certificate, domainConfig.Failover, props.Environment)
+		}
+	}
+
+	// Create the keys the stacks of the environment encrypt their data, logs and secrets with
+	keys := newEnvironmentKeys(stack, props.Environment)
+
+	// Notify on alarms and show the metrics of every stack on one dashboard
+	observability := newObservability(stack, scope, sprops, props.Environment)
+
+	// Create the VPC the stacks of the environment share
+	var network *EnvironmentNetwork
+	if props.Environment.Profile.Network != nil {
+		network = NewEnvironmentNetwork(stack, "Network", props.Environment.Profile.Network)
+		awscdk.NewCfnOutput(stack, jsii.String("VpcId"), &awscdk.CfnOutputProps{
+			Value: network.Vpc.VpcId(),
+		})
+	}
+
+	// Alert on the cost of the environment, measured on its cost allocation tags
+	var costAlerts awssns.ITopic
+	if props.Environment.Profile.Budget != nil {
+		costAlerts = newCostAlerts(stack, props.Environment.Profile.Budget, props.Environment)
+		awscdk.NewCfnOutput(stack, jsii.String("CostAlertsTopicArn"), &awscdk.CfnOutputProps{
+			Value: costAlerts.TopicArn(),
+		})
+	}
+
+	return &CoreStack{
+		Stack:         stack,
+		HostedZone:    hostedZone,
+		Certificate:   certificate,
+		Email:         email,
+		Maintenance:   maintenance,
+		Keys:          keys,
+		Network:       network,
+		Observability: observability,
+		CostAlerts:    costAlerts,
+	}, nil
 }
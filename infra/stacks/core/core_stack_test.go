@@ -1,11 +1,14 @@
 package core_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
 	"github.com/aws/jsii-runtime-go"
-	
+
 	"aws-infra-sandbox/lib"
 	"aws-infra-sandbox/stacks/core"
 )
@@ -13,15 +16,15 @@ import (
 func TestCoreStackSynthesizes(t *testing.T) {
 	// GIVEN
 	app := awscdk.NewApp(nil)
-	
+
 	// Create a test environment
 	env := lib.Environment{
 		Name:     "test",
 		Username: "tester",
 	}
-	
+
 	// WHEN
-	stack := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
 		StackProps: awscdk.StackProps{
 			Env: &awscdk.Environment{
 				Account: jsii.String("123456789012"),
@@ -30,9 +33,546 @@ func TestCoreStackSynthesizes(t *testing.T) {
 		},
 		Environment: env,
 	})
-	
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// THEN - the stack should synthesize without errors
-	if stack == nil {
-		t.Fatal("Stack should not be nil")
+	if stack == nil || stack.HostedZone == nil || stack.Certificate == nil {
+		t.Fatal("Stack should share its hosted zone and certificate")
+	}
+
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::CertificateManager::Certificate"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::CertificateManager::Certificate"), map[string]interface{}{
+		"DomainName": "*." + env.GetEnvPrefix() + ".ebbo.dev",
+	})
+}
+
+func TestCoreStackDelegatesEnvironmentZone(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "pr",
+		Kind:     lib.KindPR,
+		PRNumber: "42",
+		IsPR:     true,
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the environment gets its own zone, delegated from the root zone
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Route53::HostedZone"), map[string]interface{}{
+		"Name": "pr-42.ebbo.dev.",
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"HostedZoneId": lib.DefaultDomainConfig().HostedZoneId,
+		"Name":         "pr-42.ebbo.dev.",
+		"Type":         "NS",
+	})
+}
+
+func TestCoreStackDelegatesAcrossAccounts(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "pr",
+		Kind:     lib.KindPR,
+		PRNumber: "42",
+		IsPR:     true,
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.DelegationRoleArn = "arn:aws:iam::111111111111:role/dns-delegation"
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("222222222222"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the delegation is written by a custom resource assuming the delegation role
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Route53::RecordSet"), jsii.Number(0))
+	template.HasResourceProperties(jsii.String("Custom::CrossAccountZoneDelegation"), map[string]interface{}{
+		"AssumeRoleArn":     domainConfig.DelegationRoleArn,
+		"ParentZoneId":      domainConfig.HostedZoneId,
+		"DelegatedZoneName": "pr-42.ebbo.dev",
+	})
+}
+
+func TestCoreStackUsesRootZoneForApexEnvironments(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "production",
+		Kind: lib.KindProduction,
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Strategy = lib.ApexForProduction{}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - production is served from the root zone without a zone of its own
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Route53::HostedZone"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::Route53::RecordSet"), jsii.Number(0))
+	template.HasResourceProperties(jsii.String("AWS::CertificateManager::Certificate"), map[string]interface{}{
+		"DomainName": "*.ebbo.dev",
+	})
+}
+
+func TestCoreStackCreatesDeclaredRecords(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Records = []lib.DNSRecord{
+		lib.CAARecord(""),
+		lib.SPFRecord("", "include:amazonses.com"),
+		lib.VerificationRecord("", "google-site-verification=abc"),
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - TXT records of one name share a record set, and the name has an ownership record
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":            "staging.ebbo.dev.",
+		"Type":            "TXT",
+		"ResourceRecords": []interface{}{`"v=spf1 include:amazonses.com -all"`, `"google-site-verification=abc"`},
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":            "_owner.staging.ebbo.dev.",
+		"Type":            "TXT",
+		"ResourceRecords": []interface{}{`"managed-by=cdk,environment=staging,stack=TestCoreStack"`},
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name": "staging.ebbo.dev.",
+		"Type": "CAA",
+	})
+}
+
+func TestCoreStackSignsZoneWithKeysFromUsEast1(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "pr",
+		Kind:     lib.KindPR,
+		PRNumber: "42",
+		IsPR:     true,
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.DNSSEC = &lib.DNSSECConfig{KeyNames: []string{"ksk1", "ksk2"}}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the KMS keys live in a us-east-1 stack, the zone is signed by one key-signing key per key
+	var keyStack awscdk.Stack
+	for _, child := range *app.Node().Children() {
+		if candidate, ok := child.(awscdk.Stack); ok && *candidate.Region() == "us-east-1" {
+			keyStack = candidate
+		}
+	}
+	if keyStack == nil {
+		t.Fatal("expected a stack in us-east-1 holding the signing keys")
+	}
+	keys := assertions.Template_FromStack(keyStack, nil)
+	keys.ResourceCountIs(jsii.String("AWS::KMS::Key"), jsii.Number(2))
+	keys.HasResourceProperties(jsii.String("AWS::KMS::Key"), map[string]interface{}{
+		"KeySpec":  "ECC_NIST_P256",
+		"KeyUsage": "SIGN_VERIFY",
+	})
+
+	// THEN - reapers of other regions recognize the key stack by its tag
+	if got := (*keyStack.Tags().TagValues())[lib.GlobalStackTag]; got == nil || *got != "true" {
+		t.Errorf("expected the key stack to be tagged %s, got %v", lib.GlobalStackTag, got)
+	}
+
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Route53::KeySigningKey"), jsii.Number(2))
+	template.ResourceCountIs(jsii.String("AWS::Route53::DNSSEC"), jsii.Number(1))
+	template.HasOutput(jsii.String("DsRecord2"), map[string]interface{}{})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name": "pr-42.ebbo.dev.",
+		"Type": "DS",
+	})
+}
+
+func TestCoreStackCreatesEmailIdentity(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Email = &lib.EmailConfig{MailFromSubdomain: "bounces"}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the identity is verified with DKIM and reports bounces and complaints to SNS
+	if stack.Email == nil || stack.Email.Domain != "staging.ebbo.dev" || stack.Email.MailFromDomain != "bounces.staging.ebbo.dev" {
+		t.Fatalf("expected the email identity to be shared, got %+v", stack.Email)
+	}
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::SES::EmailIdentity"), map[string]interface{}{
+		"EmailIdentity": "staging.ebbo.dev",
+		"MailFromAttributes": map[string]interface{}{
+			"MailFromDomain": "bounces.staging.ebbo.dev",
+		},
+	})
+	template.HasResourceProperties(jsii.String("AWS::SES::ConfigurationSetEventDestination"), map[string]interface{}{
+		"EventDestination": map[string]interface{}{
+			"MatchingEventTypes": []interface{}{"bounce", "complaint"},
+		},
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name": "bounces.staging.ebbo.dev.",
+		"Type": "MX",
+	})
+}
+
+func TestCoreStackAlertsOnBudgetAndAnomalies(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "pr",
+		Kind:     lib.KindPR,
+		PRNumber: "42",
+		IsPR:     true,
+		Profile: lib.Profile{
+			Budget: &lib.BudgetConfig{MonthlyLimit: 25, Thresholds: []float64{80}, AnomalyThreshold: 5},
+		},
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the budget only counts the cost of this PR, both alerts go to the shared topic
+	if stack.CostAlerts == nil {
+		t.Fatal("expected the cost alerts topic to be shared")
+	}
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Budgets::Budget"), map[string]interface{}{
+		"Budget": assertions.Match_ObjectLike(&map[string]interface{}{
+			"BudgetLimit": map[string]interface{}{"Amount": 25, "Unit": "USD"},
+			"FilterExpression": map[string]interface{}{
+				"And": []interface{}{
+					map[string]interface{}{"Tags": map[string]interface{}{"Key": "Environment", "Values": []interface{}{"pr"}, "MatchOptions": []interface{}{"EQUALS"}}},
+					map[string]interface{}{"Tags": map[string]interface{}{"Key": "PR", "Values": []interface{}{"42"}, "MatchOptions": []interface{}{"EQUALS"}}},
+				},
+			},
+		}),
+		"NotificationsWithSubscribers": assertions.Match_ArrayWith(&[]interface{}{
+			assertions.Match_ObjectLike(&map[string]interface{}{
+				"Notification": map[string]interface{}{
+					"NotificationType":   "FORECASTED",
+					"ComparisonOperator": "GREATER_THAN",
+					"Threshold":          100,
+					"ThresholdType":      "PERCENTAGE",
+				},
+			}),
+		}),
+	})
+	template.HasResourceProperties(jsii.String("AWS::CE::AnomalyMonitor"), map[string]interface{}{
+		"MonitorType":          "CUSTOM",
+		"MonitorSpecification": `{"And":[{"Tags":{"Key":"Environment","Values":["pr"],"MatchOptions":["EQUALS"]}},{"Tags":{"Key":"PR","Values":["42"],"MatchOptions":["EQUALS"]}}]}`,
+	})
+	template.HasResourceProperties(jsii.String("AWS::CE::AnomalySubscription"), map[string]interface{}{
+		"Frequency": "IMMEDIATE",
+	})
+}
+
+func TestCoreStackCreatesEnvironmentKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		kind          lib.EnvironmentKind
+		removalPolicy string
+		deletion      string
+	}{
+		{name: "staging", kind: lib.KindStaging, removalPolicy: "destroy", deletion: "Delete"},
+		{name: "staging", kind: lib.KindStaging, removalPolicy: "retain", deletion: "Retain"},
+		{name: "production", kind: lib.KindProduction, removalPolicy: "destroy", deletion: "Retain"},
+	} {
+		t.Run(tc.name+"-"+tc.removalPolicy, func(t *testing.T) {
+			// GIVEN
+			app := awscdk.NewApp(nil)
+			env := lib.Environment{
+				Name: tc.name,
+				Kind: tc.kind,
+				Profile: lib.Profile{
+					Encryption: &lib.EncryptionConfig{DataStores: true},
+					Retention:  lib.RetentionPolicy{RemovalPolicy: tc.removalPolicy},
+				},
+			}
+
+			// WHEN
+			stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+				Environment: env,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// THEN - one rotated key per purpose, aliased with the environment prefix
+			if stack.Keys == nil || stack.Keys.Data == nil || stack.Keys.Logs == nil || stack.Keys.Secrets == nil {
+				t.Fatalf("expected the keys to be shared, got %+v", stack.Keys)
+			}
+			template := assertions.Template_FromStack(stack.Stack, nil)
+			for _, purpose := range []string{"data", "logs", "secrets"} {
+				template.HasResourceProperties(jsii.String("AWS::KMS::Alias"), map[string]interface{}{
+					"AliasName": "alias/" + tc.name + "-" + purpose,
+				})
+			}
+			template.AllResources(jsii.String("AWS::KMS::Key"), map[string]interface{}{
+				"DeletionPolicy": tc.deletion,
+				"Properties":     assertions.Match_ObjectLike(&map[string]interface{}{"EnableKeyRotation": true}),
+			})
+		})
+	}
+}
+
+func TestCoreStackLeavesDataStoresWithoutDataKeyByDefault(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - logs and secrets are encrypted, data stores keep their current encryption
+	if stack.Keys.Data != nil || stack.Keys.Logs == nil || stack.Keys.Secrets == nil {
+		t.Fatalf("expected only the logs and secrets keys, got %+v", stack.Keys)
+	}
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::KMS::Key"), jsii.Number(2))
+	template.ResourcePropertiesCountIs(jsii.String("AWS::KMS::Alias"), map[string]interface{}{
+		"AliasName": "alias/staging-data",
+	}, jsii.Number(0))
+}
+
+func TestCoreStackCreatesEnvironmentNetwork(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+		Profile: lib.Profile{
+			Network: &lib.NetworkConfig{
+				Cidr:   "10.20.0.0/16",
+				MaxAzs: 3,
+				Subnets: []lib.SubnetConfig{
+					{Name: "ingress", Type: lib.SubnetPublic, CidrMask: 24},
+					{Name: "apps", Type: lib.SubnetPrivate, CidrMask: 22},
+				},
+			},
+		},
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - one VPC with the subnet layout, a NAT gateway and the endpoint set
+	if stack.Network == nil || *stack.Network.WorkloadSubnets.SubnetGroupName != "apps" {
+		t.Fatalf("expected the network to be shared with the apps subnets, got %+v", stack.Network)
+	}
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::EC2::VPC"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::EC2::VPC"), map[string]interface{}{
+		"CidrBlock": "10.20.0.0/16",
+	})
+	template.ResourceCountIs(jsii.String("AWS::EC2::Subnet"), jsii.Number(6))
+	template.ResourceCountIs(jsii.String("AWS::EC2::NatGateway"), jsii.Number(1))
+	template.ResourceCountIs(jsii.String("AWS::EC2::VPCEndpoint"), jsii.Number(4))
+	template.HasOutput(jsii.String("VpcId"), map[string]interface{}{})
+}
+
+func TestCoreStackCreatesNoNetworkUnlessDeclared(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: lib.Environment{Name: "pr-42", Kind: lib.KindPR},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN
+	if stack.Network != nil {
+		t.Fatal("expected no network")
+	}
+	assertions.Template_FromStack(stack.Stack, nil).ResourceCountIs(jsii.String("AWS::EC2::VPC"), jsii.Number(0))
+}
+
+func TestCoreStackCreatesObservability(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+		Profile: lib.Profile{
+			Kind:          lib.KindStaging,
+			Observability: &lib.ObservabilityConfig{AlarmSubscribers: []string{"ops@ebbo.dev"}},
+		},
+	}
+
+	// WHEN
+	stack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the alarm topic is in the core stack, the dashboard in a stack of its own
+	if stack.Observability == nil || stack.Observability.LogRetention != awslogs.RetentionDays_ONE_MONTH {
+		t.Fatalf("expected observability with the staging log retention, got %+v", stack.Observability)
+	}
+	template := assertions.Template_FromStack(stack.Stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::SNS::Subscription"), map[string]interface{}{
+		"Protocol": "email",
+		"Endpoint": "ops@ebbo.dev",
+	})
+	template.HasOutput(jsii.String("AlarmTopicArn"), map[string]interface{}{})
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Dashboard"), jsii.Number(0))
+
+	dashboardStack := awscdk.Stack_Of(stack.Observability.Dashboard)
+	if *dashboardStack.StackName() != env.GetStackName("DashboardStack") {
+		t.Fatalf("unexpected dashboard stack %s", *dashboardStack.StackName())
+	}
+	assertions.Template_FromStack(dashboardStack, nil).HasResourceProperties(jsii.String("AWS::CloudWatch::Dashboard"), map[string]interface{}{
+		"DashboardName": "staging-dashboard",
+	})
+}
+
+func TestCoreStackReportsEveryConfigProblem(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+		Profile: lib.Profile{
+			Network: &lib.NetworkConfig{Cidr: "10.20.0.1/16"},
+		},
+	}
+
+	// WHEN
+	_, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment:  env,
+		DomainConfig: &lib.DomainConfig{RootDomain: "example.com"},
+	})
+
+	// THEN
+	if err == nil {
+		t.Fatal("expected the config to be rejected")
+	}
+	for _, problem := range []string{
+		"hosted zone ID of example.com must be set",
+		`network cidr "10.20.0.1/16" must be an IPv4 network address`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+
+	if _, err := core.NewCoreStack(app, "NilCoreStack", nil); err == nil {
+		t.Error("expected nil props to be rejected")
+	}
+	if _, err := core.NewCoreStack(app, "NoDomainCoreStack", &core.CoreStackProps{
+		Environment:  env,
+		DomainConfig: &lib.DomainConfig{},
+	}); err == nil || !strings.Contains(err.Error(), "root domain must be set") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFromDependenciesRejectsMissingCoreStack(t *testing.T) {
+	for name, dependencies := range map[string]map[string]lib.BuiltStack{
+		"missing": {},
+		"nil":     {"core": nil},
+		"other":   {"core": lib.PlainStack(awscdk.NewStack(awscdk.NewApp(nil), jsii.String("Other"), nil))},
+	} {
+		if _, err := core.FromDependencies(&lib.StackFactoryProps{Dependencies: dependencies}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
 	}
 }
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/customresources"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+)
+
+// newDnssecKeyStack creates the stack holding the signing keys of a zone in lib.GlobalRegion
+func newDnssecKeyStack(scope constructs.Construct, props awscdk.StackProps, env lib.Environment) awscdk.Stack {
+	return lib.NewGlobalStack(scope, env.GetStackName("DnssecKeyStack"), props)
+}
+
+// newDnssecKey creates an asymmetric KMS key that Route 53 may use to sign the zones of the account
+func newDnssecKey(scope constructs.Construct, name string, env lib.Environment) awskms.Key {
+	removalPolicy, pendingWindow := keyRemovalPolicy(env)
+
+	key := awskms.NewKey(scope, jsii.String("DnssecKey-"+name), &awskms.KeyProps{
+		Description:   jsii.String(fmt.Sprintf("DNSSEC key-signing key %s of the %s environment", name, env.Name)),
+		KeySpec:       awskms.KeySpec_ECC_NIST_P256,
+		KeyUsage:      awskms.KeyUsage_SIGN_VERIFY,
+		RemovalPolicy: removalPolicy,
+		PendingWindow: pendingWindow,
+	})
+
+	// Route 53 signs with the key on behalf of the hosted zones of this account
+	account := awscdk.Stack_Of(key).Account()
+	key.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowRoute53DNSSECService"),
+		Principals: &[]awsiam.IPrincipal{awsiam.NewServicePrincipal(jsii.String("dnssec-route53.amazonaws.com"), nil)},
+		Actions:    jsii.Strings("kms:DescribeKey", "kms:GetPublicKey", "kms:Sign"),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"StringEquals": map[string]interface{}{"aws:SourceAccount": account},
+			"ArnLike":      map[string]interface{}{"aws:SourceArn": "arn:aws:route53:::hostedzone/*"},
+		},
+	}), nil)
+	key.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowRoute53DNSSECServiceToCreateGrant"),
+		Principals: &[]awsiam.IPrincipal{awsiam.NewServicePrincipal(jsii.String("dnssec-route53.amazonaws.com"), nil)},
+		Actions:    jsii.Strings("kms:CreateGrant"),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"Bool": map[string]interface{}{"kms:GrantIsForAWSResource": true},
+		},
+	}), nil)
+
+	return key
+}
+
+// enableDnssec signs the zone with one key-signing key per key name and returns a custom
+// resource reading the DS records of the keys, in the order Route 53 lists them
+func enableDnssec(stack awscdk.Stack, keyScope constructs.Construct, zoneId *string, config *lib.DNSSECConfig, env lib.Environment) customresources.AwsCustomResource {
+	dnssec := awsroute53.NewCfnDNSSEC(stack, jsii.String("Dnssec"), &awsroute53.CfnDNSSECProps{
+		HostedZoneId: zoneId,
+	})
+
+	for _, name := range config.Keys() {
+		key := newDnssecKey(keyScope, name, env)
+		ksk := awsroute53.NewCfnKeySigningKey(stack, jsii.String("KeySigningKey-"+name), &awsroute53.CfnKeySigningKeyProps{
+			HostedZoneId:            zoneId,
+			KeyManagementServiceArn: key.KeyArn(),
+			Name:                    jsii.String(name),
+			Status:                  jsii.String("ACTIVE"),
+		})
+		dnssec.AddDependency(ksk)
+	}
+
+	// CloudFormation does not expose the DS records, so they are read from Route 53
+	getDnssec := &customresources.AwsSdkCall{
+		Service: jsii.String("Route53"),
+		Action:  jsii.String("getDNSSEC"),
+		Parameters: map[string]interface{}{
+			"HostedZoneId": zoneId,
+		},
+		// A new physical ID rereads the records whenever the keys change
+		PhysicalResourceId: customresources.PhysicalResourceId_Of(jsii.String("dnssec-" + strings.Join(config.Keys(), "-"))),
+		OutputPaths:        jsii.Strings(dsRecordOutputPaths(len(config.Keys()))...),
+	}
+	dsRecords := customresources.NewAwsCustomResource(stack, jsii.String("DsRecords"), &customresources.AwsCustomResourceProps{
+		OnCreate: getDnssec,
+		OnUpdate: getDnssec,
+		Policy: customresources.AwsCustomResourcePolicy_FromStatements(&[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions:   jsii.Strings("route53:GetDNSSEC"),
+				Resources: jsii.Strings("*"),
+			}),
+		}),
+		InstallLatestAwsSdk: jsii.Bool(false),
+	})
+	dsRecords.Node().AddDependency(dnssec)
+
+	return dsRecords
+}
+
+// dsRecordOutputPaths lists the fields of GetDNSSEC holding the name and DS record of each key
+func dsRecordOutputPaths(keys int) []string {
+	var paths []string
+	for i := 0; i < keys; i++ {
+		paths = append(paths, fmt.Sprintf("KeySigningKeys.%d.Name", i), fmt.Sprintf("KeySigningKeys.%d.DSRecord", i))
+	}
+	return paths
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsses"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// EmailIdentity is the SES identity of an environment domain, shared with the stacks sending mail
+type EmailIdentity struct {
+	// Identity is verified with Easy DKIM records in the environment's zone
+	Identity awsses.IEmailIdentity
+
+	// Domain is the verified domain, mail may be sent from any address in it
+	Domain string
+
+	// MailFromDomain receives bounces, its MX and SPF records are in the environment's zone
+	MailFromDomain string
+
+	// ConfigurationSet is the default configuration set of the identity
+	ConfigurationSet awsses.IConfigurationSet
+
+	// Events receives the bounce and complaint events of the configuration set
+	Events awssns.ITopic
+}
+
+// GrantSend allows grantee to send mail from the identity through the SES API
+func (e *EmailIdentity) GrantSend(grantee awsiam.IGrantable) awsiam.Grant {
+	return e.Identity.GrantSendEmail(grantee)
+}
+
+// SmtpEndpoint returns the SES SMTP endpoint of the region the identity lives in
+func (e *EmailIdentity) SmtpEndpoint() *string {
+	return awscdk.Fn_Join(jsii.String(""), &[]*string{
+		jsii.String("email-smtp."),
+		awscdk.Stack_Of(e.Identity).Region(),
+		jsii.String(".amazonaws.com"),
+	})
+}
+
+// newEmailIdentity creates the SES identity of the zone's domain with its DKIM and MAIL FROM
+// records, and a configuration set publishing bounces and complaints to a topic
+func newEmailIdentity(scope constructs.Construct, zone awsroute53.IPublicHostedZone, domain string, config *lib.EmailConfig, env lib.Environment) *EmailIdentity {
+	topic := awssns.NewTopic(scope, jsii.String("EmailEvents"), &awssns.TopicProps{
+		DisplayName: jsii.String(fmt.Sprintf("Bounces and complaints of %s", domain)),
+	})
+
+	configurationSet := awsses.NewConfigurationSet(scope, jsii.String("EmailConfigurationSet"), &awsses.ConfigurationSetProps{
+		ConfigurationSetName: jsii.String(env.ResourceName(naming.SESConfigurationSet, "mail")),
+		ReputationMetrics:    jsii.Bool(true),
+	})
+	configurationSet.AddEventDestination(jsii.String("BouncesAndComplaints"), &awsses.ConfigurationSetEventDestinationOptions{
+		Destination: awsses.EventDestination_SnsTopic(topic),
+		Events:      &[]awsses.EmailSendingEvent{awsses.EmailSendingEvent_BOUNCE, awsses.EmailSendingEvent_COMPLAINT},
+	})
+
+	mailFromDomain := config.MailFromDomain(domain)
+	identity := awsses.NewEmailIdentity(scope, jsii.String("EmailIdentity"), &awsses.EmailIdentityProps{
+		Identity:                    awsses.Identity_PublicHostedZone(zone),
+		ConfigurationSet:            configurationSet,
+		MailFromDomain:              jsii.String(mailFromDomain),
+		MailFromBehaviorOnMxFailure: awsses.MailFromBehaviorOnMxFailure_REJECT_MESSAGE,
+	})
+
+	return &EmailIdentity{
+		Identity:         identity,
+		Domain:           domain,
+		MailFromDomain:   mailFromDomain,
+		ConfigurationSet: configurationSet,
+		Events:           topic,
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// EnvironmentKeys are the customer managed KMS keys of an environment, one per purpose
+type EnvironmentKeys struct {
+	// Data encrypts stored data such as EFS file systems and ECR repositories, it is nil unless
+	// the profile opts its data stores in
+	Data awskms.IKey
+
+	// Logs encrypts the CloudWatch Logs log groups of the environment
+	Logs awskms.IKey
+
+	// Secrets encrypts secrets and Lambda environment variables
+	Secrets awskms.IKey
+}
+
+// keyRemovalPolicy returns the removal policy and pending window of the environment's keys. Keys
+// of environments whose resources are destroyed are deleted after the shortest pending window,
+// production keys are always retained since deleting them loses every backup encrypted with them.
+func keyRemovalPolicy(env lib.Environment) (awscdk.RemovalPolicy, awscdk.Duration) {
+	if env.Kind != lib.KindProduction && env.Profile.Retention.CdkRemovalPolicy() == awscdk.RemovalPolicy_DESTROY {
+		return awscdk.RemovalPolicy_DESTROY, awscdk.Duration_Days(jsii.Number(7))
+	}
+	return awscdk.RemovalPolicy_RETAIN, nil
+}
+
+// newEnvironmentKeys creates the keys of the environment, each may only be used through the
+// services of its purpose besides the administrators of the account
+func newEnvironmentKeys(scope constructs.Construct, env lib.Environment) *EnvironmentKeys {
+	region := awscdk.Stack_Of(scope).Region()
+	account := awscdk.Stack_Of(scope).Account()
+
+	keys := &EnvironmentKeys{}
+	if env.Profile.Encryption.EncryptsDataStores() {
+		data := newEnvironmentKey(scope, "Data", "data", env)
+		allowViaServices(data, account, "elasticfilesystem", "ecr")
+		keys.Data = data
+	}
+
+	logs := newEnvironmentKey(scope, "Logs", "logs", env)
+	logs.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowCloudWatchLogs"),
+		Principals: &[]awsiam.IPrincipal{awsiam.NewServicePrincipal(jsii.String(fmt.Sprintf("logs.%s.amazonaws.com", *region)), nil)},
+		Actions:    jsii.Strings("kms:Encrypt*", "kms:Decrypt*", "kms:ReEncrypt*", "kms:GenerateDataKey*", "kms:Describe*"),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"ArnLike": map[string]interface{}{
+				"kms:EncryptionContext:aws:logs:arn": fmt.Sprintf("arn:%s:logs:%s:%s:log-group:*", *awscdk.Aws_PARTITION(), *region, *account),
+			},
+		},
+	}), nil)
+
+	secrets := newEnvironmentKey(scope, "Secrets", "secrets", env)
+	allowViaServices(secrets, account, "lambda", "secretsmanager")
+
+	keys.Logs = logs
+	keys.Secrets = secrets
+	return keys
+}
+
+// newEnvironmentKey creates a symmetric key with rotation and an alias of the environment
+func newEnvironmentKey(scope constructs.Construct, id, purpose string, env lib.Environment) awskms.Key {
+	removalPolicy, pendingWindow := keyRemovalPolicy(env)
+	return awskms.NewKey(scope, jsii.String(id+"Key"), &awskms.KeyProps{
+		Alias:             jsii.String("alias/" + env.ResourceName(naming.KMSAlias, purpose)),
+		Description:       jsii.String(fmt.Sprintf("Encrypts %s of the %s environment", purpose, env.Name)),
+		EnableKeyRotation: jsii.Bool(true),
+		RemovalPolicy:     removalPolicy,
+		PendingWindow:     pendingWindow,
+	})
+}
+
+// allowViaServices lets principals of the account use key through the given services of its region
+func allowViaServices(key awskms.Key, account *string, services ...string) {
+	region := awscdk.Stack_Of(key).Region()
+	var viaServices []*string
+	for _, service := range services {
+		viaServices = append(viaServices, jsii.String(fmt.Sprintf("%s.%s.amazonaws.com", service, *region)))
+	}
+	key.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowViaServices"),
+		Principals: &[]awsiam.IPrincipal{awsiam.NewAnyPrincipal()},
+		Actions:    jsii.Strings("kms:Encrypt", "kms:Decrypt", "kms:ReEncrypt*", "kms:GenerateDataKey*", "kms:CreateGrant", "kms:DescribeKey"),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"kms:CallerAccount": account,
+				"kms:ViaService":    viaServices,
+			},
+		},
+	}), nil)
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfront"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfrontorigins"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3deployment"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// maintenancePage is served with status 503 for every path while an endpoint fails over
+const maintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Down for maintenance</title>
+</head>
+<body>
+<h1>Down for maintenance</h1>
+<p>This service is temporarily unavailable. Please try again in a few minutes.</p>
+</body>
+</html>
+`
+
+// MaintenanceSite is a static maintenance page in S3 and CloudFront that the public endpoints of
+// an environment fail over to. CloudFront certificates and Route 53 health check metrics only
+// exist in us-east-1, so the site, the health checks and their alarms live there.
+type MaintenanceSite struct {
+	// Stack holds the site, it is the core stack itself if that is deployed to us-east-1
+	Stack awscdk.Stack
+
+	// Distribution serves the page for every hostname covered by the wildcard certificate
+	Distribution awscloudfront.Distribution
+
+	// Alarms is notified when a health check fails or recovers
+	Alarms awssns.ITopic
+
+	scope  constructs.Construct
+	zone   awsroute53.IHostedZone
+	domain string
+	config *lib.FailoverConfig
+	env    lib.Environment
+}
+
+// newMaintenanceStack creates the stack holding the maintenance site in lib.GlobalRegion
+func newMaintenanceStack(scope constructs.Construct, props awscdk.StackProps, env lib.Environment) awscdk.Stack {
+	return lib.NewGlobalStack(scope, env.GetStackName("MaintenanceStack"), props)
+}
+
+// newMaintenanceSite creates the maintenance page of the environment domain in scope. The
+// certificate is only created if the wildcard certificate of the core stack is in another region.
+func newMaintenanceSite(scope constructs.Construct, zone awsroute53.IHostedZone, domain string, certificate awscertificatemanager.ICertificate, config *lib.FailoverConfig, env lib.Environment) *MaintenanceSite {
+	if certificate == nil {
+		certificate = awscertificatemanager.NewCertificate(scope, jsii.String("MaintenanceCertificate"), &awscertificatemanager.CertificateProps{
+			DomainName: jsii.String(fmt.Sprintf("*.%s", domain)),
+			Validation: awscertificatemanager.CertificateValidation_FromDns(zone),
+		})
+	}
+
+	removalPolicy := env.Profile.Retention.CdkRemovalPolicy()
+	bucket := awss3.NewBucket(scope, jsii.String("MaintenanceBucket"), &awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		EnforceSSL:        jsii.Bool(true),
+		RemovalPolicy:     removalPolicy,
+		AutoDeleteObjects: jsii.Bool(removalPolicy == awscdk.RemovalPolicy_DESTROY),
+	})
+
+	// Every request is answered with the page, origins return 403 for keys that do not exist
+	errorResponse := func(status float64) *awscloudfront.ErrorResponse {
+		return &awscloudfront.ErrorResponse{
+			HttpStatus:         jsii.Number(status),
+			ResponseHttpStatus: jsii.Number(503),
+			ResponsePagePath:   jsii.String("/index.html"),
+			Ttl:                awscdk.Duration_Seconds(jsii.Number(10)),
+		}
+	}
+	distribution := awscloudfront.NewDistribution(scope, jsii.String("MaintenanceDistribution"), &awscloudfront.DistributionProps{
+		Comment: jsii.String(fmt.Sprintf("Maintenance page of the %s environment", env.Name)),
+		DefaultBehavior: &awscloudfront.BehaviorOptions{
+			Origin:               awscloudfrontorigins.S3BucketOrigin_WithOriginAccessControl(bucket, nil),
+			ViewerProtocolPolicy: awscloudfront.ViewerProtocolPolicy_REDIRECT_TO_HTTPS,
+		},
+		DefaultRootObject: jsii.String("index.html"),
+		DomainNames:       jsii.Strings(fmt.Sprintf("*.%s", domain)),
+		Certificate:       certificate,
+		ErrorResponses:    &[]*awscloudfront.ErrorResponse{errorResponse(403), errorResponse(404)},
+		PriceClass:        awscloudfront.PriceClass_PRICE_CLASS_100,
+	})
+
+	awss3deployment.NewBucketDeployment(scope, jsii.String("MaintenancePage"), &awss3deployment.BucketDeploymentProps{
+		DestinationBucket: bucket,
+		Sources:           &[]awss3deployment.ISource{awss3deployment.Source_Data(jsii.String("index.html"), jsii.String(maintenancePage), nil)},
+		Distribution:      distribution,
+	})
+
+	awscdk.NewCfnOutput(scope, jsii.String("MaintenanceDistributionDomainName"), &awscdk.CfnOutputProps{
+		Value: distribution.DistributionDomainName(),
+	})
+
+	alarms := awssns.NewTopic(scope, jsii.String("HealthAlarms"), &awssns.TopicProps{
+		DisplayName: jsii.String(fmt.Sprintf("Health of the %s endpoints", env.Name)),
+	})
+	if config.AlarmEmail != "" {
+		alarms.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(config.AlarmEmail), nil))
+	}
+
+	return &MaintenanceSite{
+		Stack:        awscdk.Stack_Of(scope),
+		Distribution: distribution,
+		Alarms:       alarms,
+		scope:        scope,
+		zone:         zone,
+		domain:       domain,
+		config:       config,
+		env:          env,
+	}
+}
+
+// WithCrossRegionReferences enables cross-region references in the props of a stack using the
+// site when the stack is deployed to another region than the site
+func (m *MaintenanceSite) WithCrossRegionReferences(props awscdk.StackProps) awscdk.StackProps {
+	if props.Env != nil && props.Env.Region != nil && !*awscdk.Token_IsUnresolved(m.Stack.Region()) && *props.Env.Region != *m.Stack.Region() {
+		props.CrossRegionReferences = jsii.Bool(true)
+	}
+	return props
+}
+
+// Covers reports whether hostname can fail over, the maintenance page is only served for
+// hostnames covered by the wildcard certificate of the environment domain
+func (m *MaintenanceSite) Covers(hostname string) bool {
+	return lib.CoveredByWildcard(m.domain, hostname)
+}
+
+// OriginHostname returns the hostname that reaches app directly, the stack of app must route it
+// to the app's endpoint for the health check to pass
+func (m *MaintenanceSite) OriginHostname(app string) string {
+	return m.config.OriginHostname(app, m.domain)
+}
+
+// AddFailover health checks healthPath of app on its origin hostname, alarms on the check and
+// routes hostname to the maintenance page while it fails. The stack of app creates the primary
+// record of hostname with NewPrimaryRecord and the returned health check.
+func (m *MaintenanceSite) AddFailover(app, hostname, healthPath string) awsroute53.IHealthCheck {
+	healthCheck := awsroute53.NewHealthCheck(m.scope, jsii.String(app+"HealthCheck"), &awsroute53.HealthCheckProps{
+		Type:             awsroute53.HealthCheckType_HTTPS,
+		Fqdn:             jsii.String(m.OriginHostname(app)),
+		Port:             jsii.Number(443),
+		ResourcePath:     jsii.String(healthPath),
+		EnableSNI:        jsii.Bool(true),
+		RequestInterval:  awscdk.Duration_Seconds(jsii.Number(float64(m.config.Interval()))),
+		FailureThreshold: jsii.Number(float64(m.config.Threshold())),
+	})
+	awscdk.Tags_Of(healthCheck).Add(jsii.String("Name"), jsii.String(hostname), nil)
+
+	alarm := awscloudwatch.NewAlarm(m.scope, jsii.String(app+"HealthAlarm"), &awscloudwatch.AlarmProps{
+		AlarmName:        jsii.String(m.env.ResourceName(naming.Alarm, app+"-health")),
+		AlarmDescription: jsii.String(fmt.Sprintf("%s fails its health check at %s%s", hostname, m.OriginHostname(app), healthPath)),
+		Metric: awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+			Namespace:     jsii.String("AWS/Route53"),
+			MetricName:    jsii.String("HealthCheckStatus"),
+			DimensionsMap: &map[string]*string{"HealthCheckId": healthCheck.HealthCheckId()},
+			Statistic:     jsii.String("Minimum"),
+			Period:        awscdk.Duration_Minutes(jsii.Number(1)),
+		}),
+		ComparisonOperator: awscloudwatch.ComparisonOperator_LESS_THAN_THRESHOLD,
+		Threshold:          jsii.Number(1),
+		EvaluationPeriods:  jsii.Number(1),
+		TreatMissingData:   awscloudwatch.TreatMissingData_BREACHING,
+	})
+	alarm.AddAlarmAction(awscloudwatchactions.NewSnsAction(m.Alarms))
+	alarm.AddOkAction(awscloudwatchactions.NewSnsAction(m.Alarms))
+
+	record := awsroute53.NewARecord(m.scope, jsii.String(app+"MaintenanceRecord"), &awsroute53.ARecordProps{
+		Zone:       m.zone,
+		RecordName: jsii.String(hostname),
+		Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewCloudFrontTarget(m.Distribution)),
+	})
+	setFailover(record, "SECONDARY", "maintenance")
+
+	return healthCheck
+}
+
+// NewPrimaryRecord creates the alias record of hostname that is answered while healthCheck passes
+func NewPrimaryRecord(scope constructs.Construct, id string, zone awsroute53.IHostedZone, hostname string, target awsroute53.RecordTarget, healthCheck awsroute53.IHealthCheck) awsroute53.ARecord {
+	record := awsroute53.NewARecord(scope, jsii.String(id), &awsroute53.ARecordProps{
+		Zone:        zone,
+		RecordName:  jsii.String(hostname),
+		Target:      target,
+		HealthCheck: healthCheck,
+	})
+	setFailover(record, "PRIMARY", "primary")
+	return record
+}
+
+// setFailover makes record part of a failover pair, the L2 record has no failover routing
+func setFailover(record awsroute53.ARecord, role, setIdentifier string) {
+	recordSet := record.Node().DefaultChild().(awsroute53.CfnRecordSet)
+	recordSet.SetFailover(jsii.String(role))
+	recordSet.SetSetIdentifier(jsii.String(setIdentifier))
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+)
+
+// subnetTypes maps the subnet types of the profile to their CDK counterparts
+var subnetTypes = map[string]awsec2.SubnetType{
+	lib.SubnetPublic:   awsec2.SubnetType_PUBLIC,
+	lib.SubnetPrivate:  awsec2.SubnetType_PRIVATE_WITH_EGRESS,
+	lib.SubnetIsolated: awsec2.SubnetType_PRIVATE_ISOLATED,
+}
+
+// EnvironmentNetwork is the VPC the stacks of an environment run in. Stacks attach their own
+// security groups, the VPC endpoints accept HTTPS from the whole VPC.
+type EnvironmentNetwork struct {
+	Vpc awsec2.Vpc
+
+	// WorkloadSubnets selects the subnets tasks, file systems and the VPC endpoints are placed
+	// in, private subnets if the layout has them and isolated ones otherwise
+	WorkloadSubnets *awsec2.SubnetSelection
+}
+
+// NewEnvironmentNetwork creates the VPC of an environment with endpoints for pulling images from
+// ECR, which stores the layers in S3, and for shipping logs to CloudWatch Logs
+func NewEnvironmentNetwork(scope constructs.Construct, id string, config *lib.NetworkConfig) *EnvironmentNetwork {
+	construct := constructs.NewConstruct(scope, &id)
+
+	var subnets []*awsec2.SubnetConfiguration
+	for _, subnet := range config.Layout() {
+		subnets = append(subnets, &awsec2.SubnetConfiguration{
+			Name:       jsii.String(subnet.Name),
+			SubnetType: subnetTypes[subnet.Type],
+			CidrMask:   jsii.Number(float64(subnet.CidrMask)),
+		})
+	}
+
+	vpc := awsec2.NewVpc(construct, jsii.String("Vpc"), &awsec2.VpcProps{
+		IpAddresses:         awsec2.IpAddresses_Cidr(jsii.String(config.VpcCidr())),
+		MaxAzs:              jsii.Number(float64(config.AzCount())),
+		NatGateways:         jsii.Number(float64(config.NatGatewayCount())),
+		SubnetConfiguration: &subnets,
+	})
+
+	workload, _ := config.WorkloadSubnets()
+	workloadSubnets := &awsec2.SubnetSelection{
+		SubnetGroupName: jsii.String(workload.Name),
+	}
+
+	for _, endpoint := range []struct {
+		id      string
+		service awsec2.InterfaceVpcEndpointAwsService
+	}{
+		{"EcrEndpoint", awsec2.InterfaceVpcEndpointAwsService_ECR()},
+		{"EcrRepositoryEndpoint", awsec2.InterfaceVpcEndpointAwsService_ECR_DOCKER()},
+		{"CloudwatchEndpoint", awsec2.InterfaceVpcEndpointAwsService_CLOUDWATCH_LOGS()},
+	} {
+		vpc.AddInterfaceEndpoint(jsii.String(endpoint.id), &awsec2.InterfaceVpcEndpointOptions{
+			Service:           endpoint.service,
+			PrivateDnsEnabled: jsii.Bool(true),
+			Subnets:           workloadSubnets,
+		})
+	}
+	vpc.AddGatewayEndpoint(jsii.String("S3Endpoint"), &awsec2.GatewayVpcEndpointOptions{
+		Service: awsec2.GatewayVpcEndpointAwsService_S3(),
+		Subnets: &[]*awsec2.SubnetSelection{workloadSubnets},
+	})
+
+	return &EnvironmentNetwork{
+		Vpc:             vpc,
+		WorkloadSubnets: workloadSubnets,
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// Observability collects the alarms and dashboard widgets of the stacks of an environment. The
+// stacks create their alarms and widgets themselves and register them with AddAlarms and
+// AddWidgets.
+type Observability struct {
+	// Alarms is notified when an alarm of the environment fires or recovers
+	Alarms awssns.ITopic
+
+	// Dashboard shows the widgets of every stack. It lives in a stack of its own that depends on
+	// the stacks whose metrics it shows, as the core stack cannot reference its dependents.
+	Dashboard awscloudwatch.Dashboard
+
+	// LogRetention is how long the environment keeps logs, see lib.Profile.CdkLogRetention
+	LogRetention awslogs.RetentionDays
+}
+
+// newObservability creates the alarm topic in the core stack and the dashboard in a stack next to it
+func newObservability(stack awscdk.Stack, scope constructs.Construct, sprops awscdk.StackProps, env lib.Environment) *Observability {
+	alarms := awssns.NewTopic(stack, jsii.String("Alarms"), &awssns.TopicProps{
+		DisplayName: jsii.String(fmt.Sprintf("Alarms of the %s environment", env.Name)),
+	})
+	if config := env.Profile.Observability; config != nil {
+		for _, subscriber := range config.AlarmSubscribers {
+			alarms.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(subscriber), nil))
+		}
+	}
+	awscdk.NewCfnOutput(stack, jsii.String("AlarmTopicArn"), &awscdk.CfnOutputProps{
+		Value: alarms.TopicArn(),
+	})
+
+	dashboardStack := awscdk.NewStack(scope, jsii.String(env.GetStackName("DashboardStack")), &awscdk.StackProps{
+		Env: sprops.Env,
+	})
+	dashboardStack.AddDependency(stack, jsii.String("the dashboard belongs to the environment"))
+	dashboard := awscloudwatch.NewDashboard(dashboardStack, jsii.String("Dashboard"), &awscloudwatch.DashboardProps{
+		DashboardName:   jsii.String(env.ResourceName(naming.Dashboard, "dashboard")),
+		DefaultInterval: awscdk.Duration_Hours(jsii.Number(3)),
+	})
+	dashboard.AddWidgets(awscloudwatch.NewTextWidget(&awscloudwatch.TextWidgetProps{
+		Markdown: jsii.String(fmt.Sprintf("# %s environment", env.Name)),
+		Width:    jsii.Number(24),
+		Height:   jsii.Number(1),
+	}))
+
+	return &Observability{
+		Alarms:       alarms,
+		Dashboard:    dashboard,
+		LogRetention: env.Profile.CdkLogRetention(),
+	}
+}
+
+// AddAlarms notifies the alarm topic when the alarms fire and when they recover
+func (o *Observability) AddAlarms(alarms ...awscloudwatch.Alarm) {
+	for _, alarm := range alarms {
+		alarm.AddAlarmAction(awscloudwatchactions.NewSnsAction(o.Alarms))
+		alarm.AddOkAction(awscloudwatchactions.NewSnsAction(o.Alarms))
+	}
+}
+
+// AddWidgets adds a row of widgets to the dashboard of the environment
+func (o *Observability) AddWidgets(widgets ...awscloudwatch.IWidget) {
+	o.Dashboard.AddWidgets(widgets...)
+}
+
+// RetainLogs applies the log retention of the environment to a log group that a service creates
+// on its own, such as the execution logs of API Gateway
+func (o *Observability) RetainLogs(scope constructs.Construct, id string, logGroupName *string) {
+	awslogs.NewLogRetention(scope, jsii.String(id), &awslogs.LogRetentionProps{
+		LogGroupName: logGroupName,
+		Retention:    o.LogRetention,
+	})
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/constructs-go/constructs/v10"
+
+	"aws-infra-sandbox/lib"
+)
+
+func init() {
+	lib.RegisterStack(lib.StackRegistration{
+		Name:   "core",
+		Suffix: "CoreStack",
+		Validate: func(props *lib.StackFactoryProps) error {
+			return coreStackProps(props).Validate()
+		},
+		New: func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+			coreStack, err := NewCoreStack(scope, id, coreStackProps(props))
+			if err != nil {
+				return nil, err
+			}
+			return coreStack, nil
+		},
+	})
+}
+
+// coreStackProps returns the props of the core stack of the environment
+func coreStackProps(props *lib.StackFactoryProps) *CoreStackProps {
+	return &CoreStackProps{
+		StackProps:   props.StackProps,
+		Environment:  props.Environment,
+		DomainConfig: props.DomainConfig,
+	}
+}
+
+// FromDependencies returns the core stack among the dependencies of a stack depending on it
+func FromDependencies(props *lib.StackFactoryProps) (*CoreStack, error) {
+	coreStack, ok := props.Dependencies["core"].(*CoreStack)
+	if !ok || coreStack == nil {
+		return nil, fmt.Errorf("depends on the core stack, got %T", props.Dependencies["core"])
+	}
+	return coreStack, nil
+}
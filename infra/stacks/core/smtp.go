@@ -0,0 +1,133 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/bundle"
+)
+
+// Keys of the SMTP credentials in their secret
+const (
+	SmtpUsernameKey = "username"
+	SmtpPasswordKey = "password"
+)
+
+// SmtpPasswordFunctionDir is the function deriving SMTP passwords, relative to the working
+// directory of the app
+const SmtpPasswordFunctionDir = "./functions/smtp-password"
+
+// BuildSmtpPasswordFunction builds SmtpPasswordFunctionDir and returns the path of its zip
+func BuildSmtpPasswordFunction() (string, error) {
+	return bundle.Function(SmtpPasswordFunctionDir, bundle.Options{Architecture: lib.ArchitectureArm64})
+}
+
+// SmtpCredentials are the SES SMTP credentials of a sender, kept in a secret under
+// SmtpUsernameKey and SmtpPasswordKey
+type SmtpCredentials struct {
+	Secret awssecretsmanager.ISecret
+
+	// Password is the resource deriving the password, consumers of the secret depend on it
+	Password constructs.IDependable
+}
+
+// SmtpCredentialsProps configure the SMTP credentials of a sender
+type SmtpCredentialsProps struct {
+	Environment lib.Environment
+
+	// SecretsKey encrypts the secrets and LogsKey the logs of the password function unless they
+	// are nil
+	SecretsKey awskms.IKey
+	LogsKey    awskms.IKey
+
+	// CodePath is the SMTP password function, see BuildSmtpPasswordFunction
+	CodePath string
+}
+
+// NewSmtpCredentials creates an IAM user that may only send mail from the identity and its SMTP
+// credentials. The password is derived from an access key of the user during deployment and
+// never appears in the template, the access key itself is kept in a secret of its own so the
+// password can be derived again on every update.
+func (e *EmailIdentity) NewSmtpCredentials(scope constructs.Construct, id string, props *SmtpCredentialsProps) *SmtpCredentials {
+	construct := constructs.NewConstruct(scope, &id)
+	identityStack := awscdk.Stack_Of(e.Identity)
+	env := props.Environment
+
+	user := awsiam.NewUser(construct, jsii.String("User"), nil)
+	user.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Actions: jsii.Strings("ses:SendRawEmail"),
+		Resources: &[]*string{
+			e.Identity.EmailIdentityArn(),
+			identityStack.FormatArn(&awscdk.ArnComponents{
+				Service:      jsii.String("ses"),
+				Resource:     jsii.String("configuration-set"),
+				ResourceName: e.ConfigurationSet.ConfigurationSetName(),
+			}),
+		},
+	}))
+	accessKey := awsiam.NewAccessKey(construct, jsii.String("AccessKey"), &awsiam.AccessKeyProps{User: user})
+
+	// Grants on the key of another stack would extend its key policy with principals of this
+	// one, grants on the imported key only extend the policies of the principals
+	key := props.SecretsKey
+	if key != nil {
+		key = awskms.Key_FromKeyArn(construct, jsii.String("Key"), key.KeyArn())
+	}
+
+	accessKeySecret := awssecretsmanager.NewSecret(construct, jsii.String("AccessKeySecret"), &awssecretsmanager.SecretProps{
+		Description:   jsii.String(fmt.Sprintf("SES SMTP access key for %s", e.Domain)),
+		EncryptionKey: key,
+		SecretObjectValue: &map[string]awscdk.SecretValue{
+			SmtpUsernameKey:   awscdk.SecretValue_UnsafePlainText(accessKey.AccessKeyId()),
+			"secretAccessKey": accessKey.SecretAccessKey(),
+		},
+	})
+	// The generated value is replaced by the password function before it is read
+	secret := awssecretsmanager.NewSecret(construct, jsii.String("Secret"), &awssecretsmanager.SecretProps{
+		Description:   jsii.String(fmt.Sprintf("SES SMTP credentials for %s", e.Domain)),
+		EncryptionKey: key,
+	})
+
+	handler := awslambda.NewFunction(construct, jsii.String("PasswordFunction"), &awslambda.FunctionProps{
+		Code:         awslambda.Code_FromAsset(jsii.String(props.CodePath), nil),
+		Runtime:      awslambda.Runtime_PROVIDED_AL2023(),
+		Architecture: awslambda.Architecture_ARM_64(),
+		Handler:      jsii.String("bootstrap"), // Must be "bootstrap" for provided.al2023
+		Timeout:      awscdk.Duration_Seconds(jsii.Number(30)),
+		LogGroup: awslogs.NewLogGroup(construct, jsii.String("PasswordFunctionLogGroup"), &awslogs.LogGroupProps{
+			EncryptionKey: props.LogsKey,
+			Retention:     env.Profile.CdkLogRetention(),
+			RemovalPolicy: env.Profile.Retention.CdkLogRemovalPolicy(),
+		}),
+	})
+	accessKeySecret.GrantRead(handler, nil)
+	secret.GrantWrite(handler)
+
+	// A new access key or region derives the password again
+	password := awscdk.NewCustomResource(construct, jsii.String("Password"), &awscdk.CustomResourceProps{
+		ServiceToken: handler.FunctionArn(),
+		ResourceType: jsii.String("Custom::SmtpPassword"),
+		Properties: &map[string]interface{}{
+			"SourceSecretId": accessKeySecret.SecretArn(),
+			"SecretId":       secret.SecretArn(),
+			"Region":         identityStack.Region(),
+			"AccessKeyId":    accessKey.AccessKeyId(),
+		},
+	})
+	// The function must be able to read and write the secrets before it is invoked
+	password.Node().AddDependency(handler)
+
+	return &SmtpCredentials{
+		Secret:   secret,
+		Password: password,
+	}
+}
@@ -1,14 +1,23 @@
 package lambda
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awss3assets"
@@ -16,43 +25,140 @@ import (
 	"github.com/aws/jsii-runtime-go"
 
 	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/bundle"
+	"aws-infra-sandbox/lib/naming"
+	"aws-infra-sandbox/stacks/core"
 )
 
+// IndexFunction is the folder of the built-in index handler in FunctionsDir. It serves the root
+// of the API unless a function declares root in its manifest, and has no routes of its own.
+const IndexFunction = "index"
+
+// HealthPath answers the health checks of the API with a mock integration, so they never invoke
+// a function. Functions cannot route it.
+const HealthPath = "/health"
+
 type LambdaStackProps struct {
 	awscdk.StackProps
-	Environment lib.Environment
+	Environment  lib.Environment
 	DomainConfig *lib.DomainConfig
+
+	// HostedZone and Certificate are shared by CoreStack, the stack imports the zone and
+	// creates its own certificate when they are not set
+	HostedZone  awsroute53.IHostedZone
+	Certificate awscertificatemanager.ICertificate
+
+	// Maintenance is shared by CoreStack when failover is enabled, the API then fails over to
+	// the maintenance page while its health check fails
+	Maintenance *core.MaintenanceSite
+
+	// Keys are shared by CoreStack, functions encrypt their environment variables and logs with
+	// them when set
+	Keys *core.EnvironmentKeys
+
+	// Observability is shared by CoreStack, the stack registers alarms on API 5xx responses and
+	// function errors and adds its metrics to the environment dashboard when set
+	Observability *core.Observability
+
+	// FunctionsDir holds a folder per function with an optional function.yaml manifest,
+	// defaults to ./functions
+	FunctionsDir string
+
+	// CodeDir holds prebuilt functions as <folder>.zip, the functions are built during
+	// synthesis when it is not set
+	CodeDir string
+
+	// ExcludeFunctions lists folders in FunctionsDir that are not served through the API
+	ExcludeFunctions []string
 }
 
-func NewLambdaStack(scope constructs.Construct, id string, props *LambdaStackProps) awscdk.Stack {
-	var sprops awscdk.StackProps
-	if props != nil {
-		sprops = props.StackProps
+// domainConfig returns the domain config from the props or the default
+func (p *LambdaStackProps) domainConfig() *lib.DomainConfig {
+	if p.DomainConfig == nil {
+		return lib.DefaultDomainConfig()
 	}
-	stack := awscdk.NewStack(scope, &id, &sprops)
+	return p.DomainConfig
+}
 
-	// Use domain config from props or default
-	domainConfig := props.DomainConfig
-	if domainConfig == nil {
-		domainConfig = lib.DefaultDomainConfig()
+// functionsDir returns the folder of the functions from the props or the default
+func (p *LambdaStackProps) functionsDir() string {
+	if p.FunctionsDir == "" {
+		return "./functions"
 	}
+	return p.FunctionsDir
+}
 
-	// Reference the hosted zone from CoreStack
-	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.HostedZoneAttributes{
-		HostedZoneId: jsii.String(domainConfig.HostedZoneId),
-		ZoneName:     jsii.String(domainConfig.RootDomain),
-	})
+// Validate reports every problem of the props and of the manifests and routes of the functions,
+// functions that fail to build are reported by NewLambdaStack
+func (p *LambdaStackProps) Validate() error {
+	if p == nil {
+		return errors.New("lambda stack props must be set")
+	}
+	_, err := p.loadFunctions()
+	return errors.Join(p.domainConfig().Validate(p.Environment), err)
+}
 
-	// Create a wildcard certificate for the environment domain
+// NewLambdaStack creates the API of an environment with a function per folder of FunctionsDir,
+// it returns every problem of the props and the functions instead when there are any. Nothing
+// is added to scope unless the stack can be created.
+func NewLambdaStack(scope constructs.Construct, id string, props *LambdaStackProps) (awscdk.Stack, error) {
+	if err := props.Validate(); err != nil {
+		return nil, err
+	}
+	functions, err := props.loadFunctions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Build every function before the stack is created
+	codePaths := map[string]string{}
+	var errs []error
+	manifests := functions.all()
+	for _, folder := range slices.Sorted(maps.Keys(manifests)) {
+		codePath, err := props.codePath(folder, manifests[folder])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		codePaths[folder] = codePath
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	sprops := props.StackProps
+	if props.Maintenance != nil {
+		sprops = props.Maintenance.WithCrossRegionReferences(sprops)
+	}
+	stack := awscdk.NewStack(scope, &id, &sprops)
+	domainConfig := props.domainConfig()
+
+	// Use the hosted zone from CoreStack
+	hostedZone := props.HostedZone
+	if hostedZone == nil {
+		hostedZone = awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.HostedZoneAttributes{
+			HostedZoneId: jsii.String(domainConfig.HostedZoneId),
+			ZoneName:     jsii.String(domainConfig.RootDomain),
+		})
+	}
+
+	// Resolve the hostnames of the API through the domain strategy
+	apiDomainName := domainConfig.GetAppDomain("api", props.Environment)
+	apiAliases := domainConfig.GetAliasDomains("api", props.Environment)
 	environmentDomain := domainConfig.GetEnvironmentDomain(props.Environment)
-	wildcardDomain := fmt.Sprintf("*.%s", environmentDomain)
-	certificate := awscertificatemanager.NewCertificate(stack, jsii.String("ApiCertificate"), &awscertificatemanager.CertificateProps{
-		DomainName: jsii.String(wildcardDomain),
-		Validation: awscertificatemanager.CertificateValidation_FromDns(hostedZone),
-	})
+
+	// Use the wildcard certificate from CoreStack if it covers every hostname of the API
+	certificate := props.Certificate
+	if certificate == nil || !lib.CoveredByWildcard(environmentDomain, append([]string{apiDomainName}, apiAliases...)...) {
+		certificate = awscertificatemanager.NewCertificate(stack, jsii.String("ApiCertificate"), &awscertificatemanager.CertificateProps{
+			DomainName:              jsii.String(apiDomainName),
+			SubjectAlternativeNames: jsii.Strings(apiAliases...),
+			Validation:              awscertificatemanager.CertificateValidation_FromDns(hostedZone),
+		})
+	}
 
 	// Create a single API Gateway for all Lambda functions
-	apiName := fmt.Sprintf("%s-api", props.Environment.GetEnvPrefix())
+	apiName := props.Environment.ResourceName(naming.RestApi, "api")
 	mainApi := awsapigateway.NewRestApi(stack, jsii.String("MainApi"), &awsapigateway.RestApiProps{
 		RestApiName: jsii.String(apiName),
 		// Enable CORS
@@ -71,36 +177,7 @@ func NewLambdaStack(scope constructs.Construct, id string, props *LambdaStackPro
 		},
 	})
 
-	// Create a Lambda function for the root path
-	rootLambdaFn := awslambda.NewFunction(stack, jsii.String("RootLambda"), &awslambda.FunctionProps{
-		Runtime: awslambda.Runtime_NODEJS_18_X(),
-		Handler: jsii.String("index.handler"),
-		Code: awslambda.Code_FromInline(jsii.String(`
-			exports.handler = async function(event) {
-				return {
-					statusCode: 200,
-					headers: {
-						"Content-Type": "application/json",
-						"Access-Control-Allow-Origin": "*",
-						"Access-Control-Allow-Methods": "GET,OPTIONS",
-						"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key"
-					},
-					body: JSON.stringify({ message: "Hello, from ebbo.dev" })
-				};
-			}
-		`)),
-	})
-
-	// Add the root Lambda integration to the root path
-	rootIntegration := awsapigateway.NewLambdaIntegration(rootLambdaFn, &awsapigateway.LambdaIntegrationOptions{
-		Proxy: jsii.Bool(true),
-	})
-
-	// Add GET method to the root path
-	mainApi.Root().AddMethod(jsii.String("GET"), rootIntegration, nil)
-
 	// Create custom domain name for the API
-	apiDomainName := domainConfig.GetAppDomain("api", props.Environment)
 	apiDomain := awsapigateway.NewDomainName(stack, jsii.String("api-serverDomain"), &awsapigateway.DomainNameProps{
 		DomainName:   jsii.String(apiDomainName),
 		Certificate:  certificate,
@@ -113,67 +190,480 @@ func NewLambdaStack(scope constructs.Construct, id string, props *LambdaStackPro
 		RestApi:    mainApi,
 	})
 
-	// Create Route53 A record for the custom domain
-	awsroute53.NewARecord(stack, jsii.String("api-dnsRecord"), &awsroute53.ARecordProps{
-		Zone:       hostedZone,
-		RecordName: jsii.String(fmt.Sprintf("api.%s", props.Environment.GetEnvPrefix())),
-		Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayDomain(apiDomain)),
-	})
+	// Create Route53 A record for the custom domain, with failover to the maintenance page the
+	// API is health checked on an origin hostname of its own, as its domain may be failed over
+	apiTarget := awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayDomain(apiDomain))
+	if props.Maintenance != nil && props.Maintenance.Covers(apiDomainName) {
+		originCertificate := props.Certificate
+		if originCertificate == nil {
+			originCertificate = certificate
+		}
+		originDomainName := props.Maintenance.OriginHostname("api")
+		originDomain := awsapigateway.NewDomainName(stack, jsii.String("ApiOriginDomain"), &awsapigateway.DomainNameProps{
+			DomainName:   jsii.String(originDomainName),
+			Certificate:  originCertificate,
+			EndpointType: awsapigateway.EndpointType_REGIONAL,
+		})
+		awsapigateway.NewBasePathMapping(stack, jsii.String("ApiOriginMapping"), &awsapigateway.BasePathMappingProps{
+			DomainName: originDomain,
+			RestApi:    mainApi,
+		})
+		awsroute53.NewARecord(stack, jsii.String("ApiOriginRecord"), &awsroute53.ARecordProps{
+			Zone:       hostedZone,
+			RecordName: jsii.String(originDomainName),
+			Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayDomain(originDomain)),
+		})
+
+		healthCheck := props.Maintenance.AddFailover("api", apiDomainName, HealthPath)
+		core.NewPrimaryRecord(stack, "ApiPrimaryRecord", hostedZone, apiDomainName, apiTarget, healthCheck)
+	} else {
+		if props.Maintenance != nil {
+			awscdk.Annotations_Of(stack).AddWarningV2(jsii.String("aws-infra-sandbox:failoverHostname"),
+				jsii.String(fmt.Sprintf("%s is not covered by the maintenance page and does not fail over", apiDomainName)))
+		}
+		awsroute53.NewARecord(stack, jsii.String("api-dnsRecord"), &awsroute53.ARecordProps{
+			Zone:       hostedZone,
+			RecordName: jsii.String(apiDomainName),
+			Target:     apiTarget,
+		})
+	}
+
+	// Redirect every alias of the API to its canonical hostname
+	if len(apiAliases) > 0 {
+		redirectApi := newRedirectApi(stack, "ApiAliasRedirect", props.Environment.ResourceName(naming.RestApi, "api-alias-redirect"), apiDomainName)
+		for _, alias := range apiAliases {
+			aliasDomain := awsapigateway.NewDomainName(stack, jsii.String("ApiAlias-"+alias), &awsapigateway.DomainNameProps{
+				DomainName:   jsii.String(alias),
+				Certificate:  certificate,
+				EndpointType: awsapigateway.EndpointType_REGIONAL,
+			})
+			awsapigateway.NewBasePathMapping(stack, jsii.String("ApiAliasMapping-"+alias), &awsapigateway.BasePathMappingProps{
+				DomainName: aliasDomain,
+				RestApi:    redirectApi,
+			})
+			awsroute53.NewARecord(stack, jsii.String("ApiAliasRecord-"+alias), &awsroute53.ARecordProps{
+				Zone:       hostedZone,
+				RecordName: jsii.String(alias),
+				Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayDomain(aliasDomain)),
+			})
+		}
+	}
+
+	// API Gateway creates the log group of its execution logs on its own
+	if props.Observability != nil {
+		props.Observability.RetainLogs(stack, "ApiExecutionLogRetention",
+			awscdk.Fn_Join(jsii.String(""), &[]*string{jsii.String("API-Gateway-Execution-Logs_"), mainApi.RestApiId(), jsii.String("/prod")}))
+	}
 
 	// Add custom domain URL as stack output
 	awscdk.NewCfnOutput(stack, jsii.String("ApiCustomDomainUrl"), &awscdk.CfnOutputProps{
 		Value: jsii.String(fmt.Sprintf("https://%s", apiDomainName)),
 	})
 
-	// iterate over all folders in functions and create lambdas
-	// read the folders from functions folder, from the filesystem and operating system
-	folders, err := readFolders("./functions")
+	// Create a function per folder, serving its routes through the main API Gateway
+	lambdaFunctions := map[string]awslambda.Function{}
+	integrations := map[string]awsapigateway.Integration{}
+	functionUrls := map[string]string{}
+	for _, folder := range functions.folders {
+		manifest := functions.manifests[folder]
+		lambdaFn := newFunction(stack, props, folder, manifest, codePaths[folder], nil)
+		lambdaFunctions[folder] = lambdaFn
+
+		// Add Lambda integration with proxy configuration
+		integrations[folder] = awsapigateway.NewLambdaIntegration(lambdaFn, &awsapigateway.LambdaIntegrationOptions{
+			// Enable proxy integration to pass all request data to Lambda
+			Proxy: jsii.Bool(true),
+		})
+
+		// Add Lambda URL as stack output
+		functionUrls[folder] = fmt.Sprintf("https://%s%s", apiDomainName, manifest.APIRoutes(folder)[0].Path)
+		awscdk.NewCfnOutput(stack, jsii.String(folder+"LambdaEndpoint"), &awscdk.CfnOutputProps{
+			Value: jsii.String(functionUrls[folder]),
+		})
+	}
+	addHealthPath(mainApi)
+	functions.routes.attach(mainApi, integrations)
+
+	// Without a root function the built-in index handler answers on the root of the API with
+	// the functions of the environment
+	if functions.root != "" {
+		mainApi.Root().AddMethod(jsii.String("ANY"), integrations[functions.root], nil)
+	} else {
+		indexFn := newIndexFunction(stack, props, functions.index, codePaths[IndexFunction], functionUrls)
+		lambdaFunctions[IndexFunction] = indexFn
+		mainApi.Root().AddMethod(jsii.String("GET"), awsapigateway.NewLambdaIntegration(indexFn, &awsapigateway.LambdaIntegrationOptions{
+			Proxy: jsii.Bool(true),
+		}), nil)
+	}
+
+	if props.Observability != nil {
+		observeApi(stack, props.Observability, mainApi, lambdaFunctions, props.Environment)
+	}
+	return stack, nil
+}
+
+// apiFunctions are the functions of FunctionsDir with their manifests and routes
+type apiFunctions struct {
+	// folders of the functions served through the API, in alphabetical order
+	folders   []string
+	manifests map[string]*lib.FunctionManifest
+	routes    *apiRoutes
+
+	// root is the function serving the root of the API, the index handler with the manifest
+	// index serves it when it is empty
+	root  string
+	index *lib.FunctionManifest
+}
+
+// all returns the manifests of every function to build by folder, including the index handler
+func (f *apiFunctions) all() map[string]*lib.FunctionManifest {
+	all := maps.Clone(f.manifests)
+	if f.index != nil {
+		all[IndexFunction] = f.index
+	}
+	return all
+}
+
+// loadFunctions reads the manifests of the functions in FunctionsDir and checks their routes,
+// it returns every problem it finds
+func (p *LambdaStackProps) loadFunctions() (*apiFunctions, error) {
+	functionsDir := p.functionsDir()
+	folders, err := readFolders(functionsDir)
 	if err != nil {
-		fmt.Println("Error reading folders:", err)
-		return nil
+		return nil, err
 	}
 
+	functions := &apiFunctions{
+		manifests: map[string]*lib.FunctionManifest{},
+		routes:    newApiRoutes(),
+	}
+	var errs []error
 	for _, folder := range folders {
-		lambdaName := props.Environment.GetStackName(folder) + folder
-
-		// Create the Lambda function
-		lambdaFn := awslambda.NewFunction(stack, jsii.String(lambdaName), &awslambda.FunctionProps{
-			Code:         awslambda.Code_FromAsset(jsii.String("build/dist/"+folder+".zip"), &awss3assets.AssetOptions{}),
-			Timeout:      awscdk.Duration_Seconds(jsii.Number(300)),
-			Runtime:      awslambda.Runtime_PROVIDED_AL2023(),
-			Architecture: awslambda.Architecture_ARM_64(),
-			Handler:      jsii.String("bootstrap"), // Must be "bootstrap" for provided.al2023
-		})
+		if folder == IndexFunction || slices.Contains(p.ExcludeFunctions, folder) {
+			continue
+		}
+		manifest, err := lib.LoadFunctionManifest(filepath.Join(functionsDir, folder))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		functions.folders = append(functions.folders, folder)
+		functions.manifests[folder] = manifest
+
+		for _, route := range manifest.APIRoutes(folder) {
+			if err := functions.routes.add(route, folder); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if manifest.Root {
+			if functions.root != "" {
+				errs = append(errs, fmt.Errorf("functions %s and %s both declare root, only one function may serve the root of the API", functions.root, folder))
+			} else {
+				functions.root = folder
+			}
+		}
+	}
 
-		lambdaFn.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(
-			jsii.String("AWSLambda_ReadOnlyAccess"),
-		))
+	// The index folder is reserved for the index handler even when a function serves the root
+	_, err = os.Stat(filepath.Join(functionsDir, IndexFunction))
+	if functions.root == "" || err == nil {
+		index, err := loadIndexManifest(functionsDir)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if functions.root == "" {
+			functions.index = index
+		}
+	}
+	return functions, errors.Join(errs...)
+}
 
-		// Create a resource for this Lambda in the main API Gateway
-		resource := mainApi.Root().AddResource(jsii.String(folder), nil)
+// codePath returns the zip of a function, built from its folder unless CodeDir holds it
+func (p *LambdaStackProps) codePath(folder string, manifest *lib.FunctionManifest) (string, error) {
+	if p.CodeDir != "" {
+		return filepath.Join(p.CodeDir, folder+".zip"), nil
+	}
+	return bundle.Function(filepath.Join(p.functionsDir(), folder), bundle.Options{
+		Architecture: manifest.CPUArchitecture(),
+	})
+}
 
-		// Add a proxy resource to handle all paths under this resource
-		proxyResource := resource.AddResource(jsii.String("{proxy+}"), nil)
+// newFunction creates the function in a folder of FunctionsDir from its built code as declared by
+// its manifest, with the environment variables of the manifest followed by environment
+func newFunction(stack awscdk.Stack, props *LambdaStackProps, folder string, manifest *lib.FunctionManifest, codePath string, environment map[string]string) awslambda.Function {
+	lambdaName := props.Environment.GetStackName(folder)
 
-		// Add Lambda integration with proxy configuration
-		integration := awsapigateway.NewLambdaIntegration(lambdaFn, &awsapigateway.LambdaIntegrationOptions{
-			// Enable proxy integration to pass all request data to Lambda
-			Proxy: jsii.Bool(true),
-		})
+	variables := props.Environment.Build.EnvironmentVariables()
+	for name, value := range manifest.Environment {
+		(*variables)[name] = jsii.String(value)
+	}
+	for name, value := range environment {
+		(*variables)[name] = jsii.String(value)
+	}
+	var reservedConcurrency *float64
+	if manifest.ReservedConcurrency != nil {
+		reservedConcurrency = jsii.Number(float64(*manifest.ReservedConcurrency))
+	}
+	functionName := props.Environment.ResourceName(naming.Function, folder)
+	lambdaFn := awslambda.NewFunction(stack, jsii.String(lambdaName), &awslambda.FunctionProps{
+		FunctionName:                 jsii.String(functionName),
+		Code:                         awslambda.Code_FromAsset(jsii.String(codePath), &awss3assets.AssetOptions{}),
+		MemorySize:                   jsii.Number(float64(manifest.Memory())),
+		Timeout:                      awscdk.Duration_Seconds(jsii.Number(float64(manifest.Timeout()))),
+		Runtime:                      awslambda.Runtime_PROVIDED_AL2023(),
+		Architecture:                 architectures[manifest.CPUArchitecture()],
+		Handler:                      jsii.String("bootstrap"), // Must be "bootstrap" for provided.al2023
+		Environment:                  variables,
+		ReservedConcurrentExecutions: reservedConcurrency,
 
-		// Add methods to the resources
-		resource.AddMethod(jsii.String("ANY"), integration, nil)
+		EnvironmentEncryption: secretsKey(props.Keys),
+		LogGroup:              newFunctionLogGroup(stack, lambdaName, functionName, props.Keys, props.Environment),
+	})
+	for key, value := range manifest.Tags {
+		awscdk.Tags_Of(lambdaFn).Add(jsii.String(key), jsii.String(value), nil)
+	}
 
-		// For paths under the function (e.g., /gin-server/{proxy+})
-		proxyResource.AddMethod(jsii.String("ANY"), integration, nil)
+	if manifest.Permissions != nil {
+		grantPermissions(folder, lambdaFn, manifest.Permissions)
+	}
+	return lambdaFn
+}
 
-		// Add Lambda URL as stack output
-		awscdk.NewCfnOutput(stack, jsii.String(folder+"LambdaEndpoint"), &awscdk.CfnOutputProps{
-			Value: jsii.String(fmt.Sprintf("https://%s/%s", apiDomainName, folder)),
-		})
+// loadIndexManifest reads the manifest of the built-in index handler, which may not declare routes
+func loadIndexManifest(functionsDir string) (*lib.FunctionManifest, error) {
+	folder := filepath.Join(functionsDir, IndexFunction)
+	if _, err := os.Stat(folder); err != nil {
+		return nil, fmt.Errorf("no function serves the root of the API, add the index handler to %s or declare root in the manifest of a function", folder)
+	}
+	manifest, err := lib.LoadFunctionManifest(folder)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Root || len(manifest.Routes) > 0 {
+		return nil, fmt.Errorf("%s: %s is reserved for the built-in index handler, which only serves the root of the API and cannot declare routes or root, move the function to another folder", filepath.Join(folder, lib.FunctionManifestFile), IndexFunction)
 	}
+	return manifest, nil
+}
 
-	return stack
+// newIndexFunction creates the built-in index handler, which lists the environment, its build
+// version and the URL of each function
+func newIndexFunction(stack awscdk.Stack, props *LambdaStackProps, manifest *lib.FunctionManifest, codePath string, functionUrls map[string]string) awslambda.Function {
+	// Marshalling a map of strings cannot fail
+	urls, _ := json.Marshal(functionUrls)
+	return newFunction(stack, props, IndexFunction, manifest, codePath, map[string]string{
+		"ENVIRONMENT_NAME": props.Environment.Name,
+		"FUNCTION_URLS":    string(urls),
+	})
+}
+
+// grantPermissions adds the permissions of a function's manifest to its role and warns about
+// statements on wildcard resources
+func grantPermissions(name string, fn awslambda.Function, permissions *lib.FunctionPermissions) {
+	for _, statement := range permissions.PolicyStatements() {
+		var resources []*string
+		for _, resource := range statement.Resources {
+			if strings.Contains(resource, "${") {
+				resources = append(resources, awscdk.Fn_Sub(jsii.String(resource), nil))
+			} else {
+				resources = append(resources, jsii.String(resource))
+			}
+		}
+		fn.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Actions:   jsii.Strings(statement.Actions...),
+			Resources: &resources,
+		}))
+
+		if statement.HasWildcardResource() {
+			awscdk.Annotations_Of(fn).AddWarningV2(jsii.String("aws-infra-sandbox:wildcardPermissions"),
+				jsii.String(fmt.Sprintf("function %s may %s on wildcard resources %s", name, strings.Join(statement.Actions, ", "), strings.Join(statement.Resources, ", "))))
+		}
+	}
+}
+
+// architectures maps the architectures of function manifests to their CDK counterparts
+var architectures = map[string]awslambda.Architecture{
+	lib.ArchitectureArm64:  awslambda.Architecture_ARM_64(),
+	lib.ArchitectureX86_64: awslambda.Architecture_X86_64(),
+}
+
+// apiRoutes collects the routes of the functions, rejecting routes that another function already
+// serves, and adds them to an API once every function has been checked
+type apiRoutes struct {
+	// parameters are the path parameters below each path, API Gateway allows one per level
+	parameters map[string]string
+
+	// routes are the methods and paths of each function in the order they were added
+	routes []apiRoute
+}
+
+// apiRoute is a method and path served by a function
+type apiRoute struct {
+	method, path, function string
+}
+
+// overlaps reports whether API Gateway would serve method on path by the route, ANY covers
+// every method
+func (r apiRoute) overlaps(method, path string) bool {
+	return r.path == path && (r.method == method || r.method == "ANY" || method == "ANY")
+}
+
+func newApiRoutes() *apiRoutes {
+	return &apiRoutes{
+		parameters: map[string]string{},
+	}
+}
+
+// add serves the methods of the route by the function, it leaves the routes unchanged when the
+// route conflicts with them
+func (r *apiRoutes) add(route lib.FunctionRoute, function string) error {
+	if route.Path == HealthPath {
+		return fmt.Errorf("function %s routes %s, which is the health path of the API", function, route.Path)
+	}
+	for _, method := range route.HTTPMethods() {
+		for _, existing := range r.routes {
+			if !existing.overlaps(method, route.Path) {
+				continue
+			}
+			if existing.method == method {
+				return fmt.Errorf("function %s routes %s %s, which function %s already serves", function, method, route.Path, existing.function)
+			}
+			return fmt.Errorf("function %s routes %s %s, which overlaps %s %s of function %s", function, method, route.Path, existing.method, existing.path, existing.function)
+		}
+	}
+
+	parameters := map[string]string{}
+	path := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(route.Path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			if parameter, ok := r.parameters[path]; ok && parameter != segment {
+				return fmt.Errorf("function %s routes %s, but %s/%s is already a path parameter", function, route.Path, path, parameter)
+			}
+			parameters[path] = segment
+		}
+		path += "/" + segment
+	}
+
+	maps.Copy(r.parameters, parameters)
+	for _, method := range route.HTTPMethods() {
+		r.routes = append(r.routes, apiRoute{method: method, path: route.Path, function: function})
+	}
+	return nil
+}
+
+// attach adds the routes to the API with the integrations of their functions, sharing the
+// resources of common path prefixes and those already in the API
+func (r *apiRoutes) attach(api awsapigateway.RestApi, integrations map[string]awsapigateway.Integration) {
+	for _, route := range r.routes {
+		resource := api.Root()
+		for _, segment := range strings.Split(strings.TrimPrefix(route.path, "/"), "/") {
+			child := resource.GetResource(jsii.String(segment))
+			if child == nil {
+				child = resource.AddResource(jsii.String(segment), nil)
+			}
+			resource = child
+		}
+		resource.AddMethod(jsii.String(route.method), integrations[route.function], nil)
+	}
+}
+
+// addHealthPath answers GET requests on HealthPath of the API without a backend
+func addHealthPath(api awsapigateway.RestApi) {
+	health := api.Root().AddResource(jsii.String(strings.TrimPrefix(HealthPath, "/")), nil)
+	health.AddMethod(jsii.String("GET"), awsapigateway.NewMockIntegration(&awsapigateway.IntegrationOptions{
+		// The API treats every payload as binary, mapping templates only apply to text
+		ContentHandling: awsapigateway.ContentHandling_CONVERT_TO_TEXT,
+		RequestTemplates: &map[string]*string{
+			"application/json": jsii.String(`{"statusCode": 200}`),
+		},
+		IntegrationResponses: &[]*awsapigateway.IntegrationResponse{{
+			StatusCode: jsii.String("200"),
+			ResponseTemplates: &map[string]*string{
+				"application/json": jsii.String(`{"status": "ok"}`),
+			},
+		}},
+	}), &awsapigateway.MethodOptions{
+		MethodResponses: &[]*awsapigateway.MethodResponse{{StatusCode: jsii.String("200")}},
+	})
+}
+
+// observeApi alarms on server errors of the API and errors of its functions, keyed by name, and
+// adds their metrics to the environment dashboard
+func observeApi(stack awscdk.Stack, observability *core.Observability, api awsapigateway.RestApi, functions map[string]awslambda.Function, env lib.Environment) {
+	period := awscdk.Duration_Minutes(jsii.Number(5))
+	serverErrors := api.MetricServerError(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+	observability.AddAlarms(serverErrors.CreateAlarm(stack, jsii.String("ApiServerErrorsAlarm"), &awscloudwatch.CreateAlarmOptions{
+		AlarmName:          jsii.String(env.ResourceName(naming.Alarm, "api-5xx")),
+		AlarmDescription:   jsii.String("The API answered requests with 5xx responses"),
+		Threshold:          jsii.Number(5),
+		EvaluationPeriods:  jsii.Number(1),
+		ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+		TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+	}))
+
+	names := slices.Sorted(maps.Keys(functions))
+	var errorMetrics, durationMetrics []awscloudwatch.IMetric
+	for _, name := range names {
+		fn := functions[name]
+		fnErrors := fn.MetricErrors(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")})
+		errorMetrics = append(errorMetrics, fnErrors.With(&awscloudwatch.MetricOptions{Label: jsii.String(name)}))
+		durationMetrics = append(durationMetrics, fn.MetricDuration(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("p90"), Label: jsii.String(name)}))
+
+		observability.AddAlarms(fnErrors.CreateAlarm(stack, jsii.String("FunctionErrorsAlarm-"+name), &awscloudwatch.CreateAlarmOptions{
+			AlarmName:          jsii.String(env.ResourceName(naming.Alarm, name, "errors")),
+			AlarmDescription:   jsii.String(fmt.Sprintf("The %s function failed", name)),
+			Threshold:          jsii.Number(1),
+			EvaluationPeriods:  jsii.Number(1),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+			TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+		}))
+	}
+
+	observability.AddWidgets(
+		awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("API requests"),
+			Left: &[]awscloudwatch.IMetric{
+				api.MetricCount(&awscloudwatch.MetricOptions{Period: period}),
+				api.MetricClientError(&awscloudwatch.MetricOptions{Period: period, Statistic: jsii.String("Sum")}),
+				serverErrors,
+			},
+			Width: jsii.Number(8),
+		}),
+		awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("Lambda errors"),
+			Left:  &errorMetrics,
+			Width: jsii.Number(8),
+		}),
+		awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+			Title: jsii.String("Lambda duration (p90)"),
+			Left:  &durationMetrics,
+			Width: jsii.Number(8),
+		}),
+	)
+}
+
+// secretsKey returns the key encrypting the environment variables of functions, nil for the
+// AWS managed key
+func secretsKey(keys *core.EnvironmentKeys) awskms.IKey {
+	if keys == nil {
+		return nil
+	}
+	return keys.Secrets
+}
+
+// newFunctionLogGroup creates the log group of the function named functionName with the log
+// retention of the environment, encrypted with the logs key of the environment if keys are set
+func newFunctionLogGroup(scope constructs.Construct, function, functionName string, keys *core.EnvironmentKeys, env lib.Environment) awslogs.ILogGroup {
+	var key awskms.IKey
+	if keys != nil {
+		key = keys.Logs
+	}
+	return awslogs.NewLogGroup(scope, jsii.String(function+"LogGroup"), &awslogs.LogGroupProps{
+		// Where Lambda would create it, so the console links to it
+		LogGroupName:  jsii.String("/aws/lambda/" + functionName),
+		EncryptionKey: key,
+		Retention:     env.Profile.CdkLogRetention(),
+		RemovalPolicy: env.Profile.Retention.CdkLogRemovalPolicy(),
+	})
 }
 
 // readFolders reads all folders in the specified path
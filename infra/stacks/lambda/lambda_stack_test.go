@@ -1,12 +1,20 @@
 package lambda_test
 
 import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
 	"github.com/aws/jsii-runtime-go"
 	
 	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+	"aws-infra-sandbox/stacks/core"
 	"aws-infra-sandbox/stacks/lambda"
 )
 
@@ -20,19 +28,654 @@ func TestLambdaStackSynthesizes(t *testing.T) {
 		Username: "tester",
 	}
 	
+	functionsDir, codeDir := writeFunctions(t, nil)
+
 	// WHEN
-	stack := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
 		StackProps: awscdk.StackProps{
 			Env: &awscdk.Environment{
 				Account: jsii.String("123456789012"),
 				Region:  jsii.String("us-east-1"),
 			},
 		},
-		Environment: env,
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	
 	// THEN - the stack should synthesize without errors
 	if stack == nil {
 		t.Fatal("Stack should not be nil")
 	}
+
+	// AND - functions and their log groups are named after the environment
+	functionName := env.ResourceName(naming.Function, lambda.IndexFunction)
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"FunctionName": functionName,
+	})
+	template.HasResourceProperties(jsii.String("AWS::Logs::LogGroup"), map[string]interface{}{
+		"LogGroupName": "/aws/lambda/" + functionName,
+	})
+}
+
+func TestLambdaStackUsesCoreCertificate(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	awsEnv := &awscdk.Environment{
+		Account: jsii.String("123456789012"),
+		Region:  jsii.String("us-east-1"),
+	}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:  awscdk.StackProps{Env: awsEnv},
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionsDir, codeDir := writeFunctions(t, nil)
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+		HostedZone:   coreStack.HostedZone,
+		Certificate:  coreStack.Certificate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the API domain uses the certificate of the core stack
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::CertificateManager::Certificate"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::ApiGateway::DomainName"), jsii.Number(1))
+}
+
+func TestLambdaStackRedirectsAliases(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	awsEnv := &awscdk.Environment{
+		Account: jsii.String("123456789012"),
+		Region:  jsii.String("us-east-1"),
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Strategy = lib.CustomHostnames{
+		Overrides: map[string]lib.HostnameOverride{
+			"api": {Aliases: []string{"www.api." + env.GetEnvPrefix() + ".ebbo.dev"}},
+		},
+	}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionsDir, codeDir := writeFunctions(t, nil)
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+		DomainConfig: domainConfig,
+		HostedZone:   coreStack.HostedZone,
+		Certificate:  coreStack.Certificate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the wildcard certificate does not cover the alias, so the API gets its own
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::CertificateManager::Certificate"), map[string]interface{}{
+		"DomainName":              "api." + env.GetEnvPrefix() + ".ebbo.dev",
+		"SubjectAlternativeNames": []interface{}{"www.api." + env.GetEnvPrefix() + ".ebbo.dev"},
+	})
+	template.ResourceCountIs(jsii.String("AWS::ApiGateway::DomainName"), jsii.Number(2))
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"Integration": map[string]interface{}{"Type": "MOCK"},
+	})
+}
+
+func TestLambdaStackFailsOverToMaintenancePage(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	awsEnv := &awscdk.Environment{
+		Account: jsii.String("123456789012"),
+		Region:  jsii.String("eu-central-1"),
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Failover = &lib.FailoverConfig{HealthCheckIntervalSeconds: 10}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionsDir, codeDir := writeFunctions(t, nil)
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+		DomainConfig: domainConfig,
+		HostedZone:   coreStack.HostedZone,
+		Certificate:  coreStack.Certificate,
+		Maintenance:  coreStack.Maintenance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Synth(nil)
+
+	// THEN - the API is the health checked primary, the maintenance page in us-east-1 the secondary
+	apiDomainName := "api." + env.GetEnvPrefix() + ".ebbo.dev."
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":          apiDomainName,
+		"Failover":      "PRIMARY",
+		"SetIdentifier": "primary",
+		"HealthCheckId": assertions.Match_AnyValue(),
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name": "origin-api." + env.GetEnvPrefix() + ".ebbo.dev.",
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Resource"), map[string]interface{}{
+		"PathPart": "health",
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod":  "GET",
+		"Integration": assertions.Match_ObjectLike(&map[string]interface{}{"Type": "MOCK"}),
+	})
+
+	if *coreStack.Maintenance.Stack.Region() != "us-east-1" {
+		t.Fatalf("expected the maintenance page in us-east-1, got %s", *coreStack.Maintenance.Stack.Region())
+	}
+	maintenance := assertions.Template_FromStack(coreStack.Maintenance.Stack, nil)
+	maintenance.HasResourceProperties(jsii.String("AWS::Route53::HealthCheck"), map[string]interface{}{
+		"HealthCheckConfig": assertions.Match_ObjectLike(&map[string]interface{}{
+			"FullyQualifiedDomainName": "origin-api." + env.GetEnvPrefix() + ".ebbo.dev",
+			"ResourcePath":             lambda.HealthPath,
+			"RequestInterval":          10,
+		}),
+	})
+	maintenance.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), map[string]interface{}{
+		"MetricName": "HealthCheckStatus",
+		"Namespace":  "AWS/Route53",
+	})
+	maintenance.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":     apiDomainName,
+		"Failover": "SECONDARY",
+	})
+	maintenance.ResourceCountIs(jsii.String("AWS::CloudFront::Distribution"), jsii.Number(1))
+}
+
+func TestLambdaStackEncryptsWithEnvironmentKeys(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+		Profile: lib.Profile{
+			Retention: lib.RetentionPolicy{LogRetentionDays: 7},
+		},
+	}
+	awsEnv := &awscdk.Environment{
+		Account: jsii.String("123456789012"),
+		Region:  jsii.String("eu-central-1"),
+	}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:  awscdk.StackProps{Env: awsEnv},
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionsDir, codeDir := writeFunctions(t, nil)
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		StackProps:   awscdk.StackProps{Env: awsEnv},
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+		HostedZone:   coreStack.HostedZone,
+		Certificate:  coreStack.Certificate,
+		Keys:         coreStack.Keys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - environment variables and logs are encrypted with the keys of the core stack
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"KmsKeyArn": assertions.Match_AnyValue(),
+	})
+	template.HasResourceProperties(jsii.String("AWS::Logs::LogGroup"), map[string]interface{}{
+		"KmsKeyId":        assertions.Match_AnyValue(),
+		"RetentionInDays": 7,
+	})
+}
+
+func TestLambdaStackRegistersAlarmsAndWidgets(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Kind:     lib.KindStaging,
+		Username: "tester",
+		Profile:  lib.Profile{Kind: lib.KindStaging},
+	}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		Environment: env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionsDir, codeDir := writeFunctions(t, nil)
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:   env,
+		FunctionsDir:  functionsDir,
+		CodeDir:       codeDir,
+		HostedZone:    coreStack.HostedZone,
+		Certificate:   coreStack.Certificate,
+		Observability: coreStack.Observability,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - API 5xx and function errors notify the alarm topic of the core stack
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), map[string]interface{}{
+		"AlarmName":    "test-api-5xx",
+		"MetricName":   "5XXError",
+		"AlarmActions": assertions.Match_AnyValue(),
+		"OKActions":    assertions.Match_AnyValue(),
+	})
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), map[string]interface{}{
+		"AlarmName":  "test-index-errors",
+		"MetricName": "Errors",
+	})
+	template.HasResourceProperties(jsii.String("Custom::LogRetention"), map[string]interface{}{
+		"RetentionInDays": 30,
+	})
+	template.AllResourcesProperties(jsii.String("AWS::Logs::LogGroup"), map[string]interface{}{
+		"RetentionInDays": 30,
+	})
+
+	// The widgets are on the dashboard next to the core stack
+	dashboard := assertions.Template_FromStack(awscdk.Stack_Of(coreStack.Observability.Dashboard), nil)
+	dashboard.HasResourceProperties(jsii.String("AWS::CloudWatch::Dashboard"), map[string]interface{}{
+		"DashboardBody": assertions.Match_AnyValue(),
+	})
+}
+
+// writeFunctions creates a functions folder with the manifests by function name, next to the
+// index handler, and a zipped placeholder of each function, it returns the functions and code folders
+func writeFunctions(t *testing.T, given map[string]string) (string, string) {
+	t.Helper()
+	manifests := map[string]string{lambda.IndexFunction: ""}
+	maps.Copy(manifests, given)
+	dir := t.TempDir()
+	functionsDir := filepath.Join(dir, "functions")
+	codeDir := filepath.Join(dir, "dist")
+	if err := os.MkdirAll(codeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, manifest := range manifests {
+		if err := os.MkdirAll(filepath.Join(functionsDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if manifest != "" {
+			if err := os.WriteFile(filepath.Join(functionsDir, name, lib.FunctionManifestFile), []byte(manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(codeDir, name+".zip"), []byte("placeholder"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return functionsDir, codeDir
+}
+
+func TestLambdaStackBuildsFunctionsFromManifests(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	functionsDir, codeDir := writeFunctions(t, map[string]string{
+		"plain": "",
+		"users": `
+memoryMiB: 512
+timeoutSeconds: 20
+architecture: x86_64
+environment:
+  LOG_LEVEL: debug
+routes:
+  - path: /users
+    methods: [GET, POST]
+  - path: /users/{id}
+    methods: [GET]
+reservedConcurrency: 5
+tags:
+  x:team: platform
+`,
+	})
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the function with a manifest is configured by it
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"MemorySize":                   512,
+		"Timeout":                      20,
+		"Architectures":                []interface{}{"x86_64"},
+		"ReservedConcurrentExecutions": 5,
+		"Environment": map[string]interface{}{
+			"Variables": assertions.Match_ObjectLike(&map[string]interface{}{"LOG_LEVEL": "debug"}),
+		},
+		"Tags": assertions.Match_ArrayWith(&[]interface{}{
+			map[string]interface{}{"Key": "x:team", "Value": "platform"},
+		}),
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Resource"), map[string]interface{}{
+		"PathPart": "{id}",
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod": "POST",
+	})
+
+	// The function without a manifest keeps the defaults
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"MemorySize":    128,
+		"Timeout":       300,
+		"Architectures": []interface{}{"arm64"},
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Resource"), map[string]interface{}{
+		"PathPart": "{proxy+}",
+	})
+}
+
+func TestLambdaStackRejectsInvalidManifestsAndConflictingRoutes(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	functionsDir, codeDir := writeFunctions(t, map[string]string{
+		"broken": "memoryMiB: 64",
+		"orders": "routes: [{path: /orders, methods: [GET]}]",
+		"legacy": "routes: [{path: /orders, methods: [GET]}]",
+		"probe":  "routes: [{path: /health}]",
+		"carts":  "routes: [{path: /carts}]",
+		"basket": "routes: [{path: /carts, methods: [GET]}]",
+		"users":  `routes: [{path: "/users/{id}"}]`,
+		"teams":  `routes: [{path: "/users/{name}/teams"}]`,
+	})
+
+	// WHEN
+	_, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+
+	// THEN - every problem is reported together
+	if err == nil {
+		t.Fatal("expected invalid functions to be rejected")
+	}
+	for _, problem := range []string{
+		"broken/function.yaml: memoryMiB 64",
+		"function orders routes GET /orders, which function legacy already serves",
+		"function probe routes /health, which is the health path of the API",
+		"function carts routes ANY /carts, which overlaps GET /carts of function basket",
+		"function users routes /users/{id}, but /users/{name} is already a path parameter",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+	if app.Node().TryFindChild(jsii.String("TestLambdaStack")) != nil {
+		t.Error("expected no stack to be left in the app")
+	}
+}
+
+func TestLambdaStackGrantsDeclaredPermissionsOnly(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	functionsDir, codeDir := writeFunctions(t, map[string]string{
+		"plain": "",
+		"users": `
+permissions:
+  readTables: [users]
+  statements:
+    - actions: [lambda:GetAccountSettings]
+      resources: ["*"]
+`,
+	})
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the functions have no managed policies beyond logging and only the declared statements
+	template := assertions.Template_FromStack(stack, nil)
+	body, err := json.Marshal(template.ToJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "AWSLambda_ReadOnlyAccess") {
+		t.Error("functions should not have AWSLambda_ReadOnlyAccess")
+	}
+	template.ResourceCountIs(jsii.String("AWS::IAM::Policy"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), map[string]interface{}{
+		"PolicyDocument": map[string]interface{}{
+			"Statement": []interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Action":   assertions.Match_ArrayWith(&[]interface{}{"dynamodb:GetItem", "dynamodb:Query"}),
+					"Resource": []interface{}{
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/users"},
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/users/index/*"},
+					},
+				}),
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Action":   "lambda:GetAccountSettings",
+					"Resource": "*",
+				}),
+			},
+			"Version": "2012-10-17",
+		},
+	})
+
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasWarning(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("function users may lambda:GetAccountSettings on wildcard resources")))
+}
+
+func TestLambdaStackBuildsFunctionsWithoutPrebuiltCode(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	functionsDir, _ := writeFunctions(t, map[string]string{"shared": ""})
+	if err := os.WriteFile(filepath.Join(functionsDir, "shared", "shared.go"), []byte("package shared\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN
+	_, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+	})
+
+	// THEN - folders without a main package fail synthesis
+	if err == nil || !strings.Contains(err.Error(), "shared is package shared, functions need a main package") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLambdaStackServesTheRootByTheIndexHandler(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:  "staging",
+		Kind:  lib.KindStaging,
+		Build: lib.BuildInfo{Version: "v1.5.0"},
+	}
+	functionsDir, codeDir := writeFunctions(t, map[string]string{"plain": ""})
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the index handler is a Go function that knows the functions and the build
+	template := assertions.Template_FromStack(stack, nil)
+	template.AllResourcesProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"Runtime": "provided.al2023",
+	})
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"Environment": map[string]interface{}{
+			"Variables": assertions.Match_ObjectLike(&map[string]interface{}{
+				"ENVIRONMENT_NAME": "staging",
+				"FUNCTION_URLS":    `{"plain":"https://api.staging.ebbo.dev/plain"}`,
+				"BUILD_VERSION":    "v1.5.0",
+			}),
+		},
+	})
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod": "GET",
+		"ResourceId": map[string]interface{}{"Fn::GetAtt": assertions.Match_ArrayWith(&[]interface{}{"RootResourceId"})},
+	})
+}
+
+func TestLambdaStackServesTheRootByTheRootFunction(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name:     "test",
+		Username: "tester",
+	}
+	functionsDir, codeDir := writeFunctions(t, map[string]string{"site": "root: true"})
+
+	// WHEN
+	stack, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  env,
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the index handler is not deployed
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::Lambda::Function"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::ApiGateway::Method"), map[string]interface{}{
+		"HttpMethod": "ANY",
+		"ResourceId": map[string]interface{}{"Fn::GetAtt": assertions.Match_ArrayWith(&[]interface{}{"RootResourceId"})},
+	})
+}
+
+func TestLambdaStackRejectsSecondRootFunction(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	functionsDir, codeDir := writeFunctions(t, map[string]string{
+		"site":    "root: true",
+		"welcome": "root: true",
+	})
+
+	// WHEN
+	_, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  lib.Environment{Name: "test", Username: "tester"},
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+
+	// THEN
+	if err == nil || !strings.Contains(err.Error(), "both declare root") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLambdaStackRejectsFunctionInTheIndexFolder(t *testing.T) {
+	// GIVEN - a function with routes in the folder of the index handler, next to a root function
+	app := awscdk.NewApp(nil)
+	functionsDir, codeDir := writeFunctions(t, map[string]string{
+		"site":               "root: true",
+		lambda.IndexFunction: "routes: [{path: /search}]",
+	})
+
+	// WHEN
+	_, err := lambda.NewLambdaStack(app, "TestLambdaStack", &lambda.LambdaStackProps{
+		Environment:  lib.Environment{Name: "test", Username: "tester"},
+		FunctionsDir: functionsDir,
+		CodeDir:      codeDir,
+	})
+
+	// THEN - the folder is reserved instead of silently skipped
+	if err == nil || !strings.Contains(err.Error(), "index is reserved for the built-in index handler") {
+		t.Fatalf("unexpected error: %v", err)
+	}
 }
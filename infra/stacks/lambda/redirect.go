@@ -0,0 +1,47 @@
+package lambda
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigateway"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// newRedirectApi creates a REST API that answers every request with a permanent
+// redirect to the same path on host
+func newRedirectApi(scope constructs.Construct, id string, name string, host string) awsapigateway.RestApi {
+	api := awsapigateway.NewRestApi(scope, jsii.String(id), &awsapigateway.RestApiProps{
+		RestApiName: jsii.String(name),
+		DeployOptions: &awsapigateway.StageOptions{
+			StageName: jsii.String("prod"),
+		},
+	})
+
+	// A mock integration answers without a backend, the response template sets the Location header
+	integration := awsapigateway.NewMockIntegration(&awsapigateway.IntegrationOptions{
+		PassthroughBehavior: awsapigateway.PassthroughBehavior_NEVER,
+		RequestTemplates: &map[string]*string{
+			"application/json": jsii.String(`{"statusCode": 301}`),
+		},
+		IntegrationResponses: &[]*awsapigateway.IntegrationResponse{{
+			StatusCode: jsii.String("301"),
+			ResponseTemplates: &map[string]*string{
+				"application/json": jsii.String(fmt.Sprintf(`#set($context.responseOverride.header.Location = "https://%s$context.path")`, host)),
+			},
+		}},
+	})
+
+	methodOptions := &awsapigateway.MethodOptions{
+		MethodResponses: &[]*awsapigateway.MethodResponse{{
+			StatusCode: jsii.String("301"),
+			ResponseParameters: &map[string]*bool{
+				"method.response.header.Location": jsii.Bool(true),
+			},
+		}},
+	}
+	api.Root().AddMethod(jsii.String("ANY"), integration, methodOptions)
+	api.Root().AddResource(jsii.String("{proxy+}"), nil).AddMethod(jsii.String("ANY"), integration, methodOptions)
+
+	return api
+}
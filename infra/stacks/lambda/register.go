@@ -0,0 +1,50 @@
+package lambda
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/stacks/core"
+)
+
+func init() {
+	lib.RegisterStack(lib.StackRegistration{
+		Name:      "lambda",
+		Suffix:    "LambdaStack",
+		DependsOn: []string{"core"},
+		Validate: func(props *lib.StackFactoryProps) error {
+			return lambdaStackProps(props, nil).Validate()
+		},
+		New: func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+			coreStack, err := core.FromDependencies(props)
+			if err != nil {
+				return nil, err
+			}
+			stack, err := NewLambdaStack(scope, id, lambdaStackProps(props, coreStack))
+			if err != nil {
+				return nil, err
+			}
+			return lib.PlainStack(stack), nil
+		},
+	})
+}
+
+// lambdaStackProps returns the props of the lambda stack of the environment with the resources
+// shared by its core stack, which is nil while the props are validated
+func lambdaStackProps(props *lib.StackFactoryProps, coreStack *core.CoreStack) *LambdaStackProps {
+	stackProps := &LambdaStackProps{
+		StackProps:   props.StackProps,
+		Environment:  props.Environment,
+		DomainConfig: props.DomainConfig,
+		// The reaper and the SMTP password function are deployed by the stacks that use them
+		ExcludeFunctions: []string{"reaper", "smtp-password"},
+	}
+	if coreStack != nil {
+		stackProps.HostedZone = coreStack.HostedZone
+		stackProps.Certificate = coreStack.Certificate
+		stackProps.Maintenance = coreStack.Maintenance
+		stackProps.Keys = coreStack.Keys
+		stackProps.Observability = coreStack.Observability
+	}
+	return stackProps
+}
@@ -0,0 +1,150 @@
+package reaper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/bundle"
+)
+
+// StackName is the name of the reaper stack, it is deployed once per account and region
+const StackName = "environment-reaper-stack"
+
+type ReaperStackProps struct {
+	awscdk.StackProps
+	Environment lib.Environment
+
+	// CodePath is the prebuilt reaper function, functions/reaper is built during synthesis when
+	// it is not set
+	CodePath string
+
+	// Schedule defaults to once an hour
+	Schedule awsevents.Schedule
+
+	// DryRun only reports expired stacks instead of deleting them
+	DryRun bool
+
+	// NotificationEmail is subscribed to the reaper topic if set
+	NotificationEmail string
+}
+
+// Validate reports every problem of the props
+func (p *ReaperStackProps) Validate() error {
+	if p == nil {
+		return errors.New("reaper stack props must be set")
+	}
+	if p.Environment.Kind.IsEphemeral() {
+		return fmt.Errorf("the reaper cannot be deployed with the %s environment %q, it would delete itself once the environment expires", p.Environment.Kind, p.Environment.Name)
+	}
+	return nil
+}
+
+// NewReaperStack creates a scheduled function that deletes expired ephemeral environments, it
+// returns the problems of the props or the build of the function instead
+func NewReaperStack(scope constructs.Construct, id string, props *ReaperStackProps) (awscdk.Stack, error) {
+	if err := props.Validate(); err != nil {
+		return nil, err
+	}
+
+	codePath := props.CodePath
+	if codePath == "" {
+		var err error
+		codePath, err = bundle.Function("./functions/reaper", bundle.Options{Architecture: lib.ArchitectureArm64})
+		if err != nil {
+			return nil, err
+		}
+	}
+	stack := awscdk.NewStack(scope, &id, &props.StackProps)
+
+	schedule := props.Schedule
+	if schedule == nil {
+		schedule = awsevents.Schedule_Rate(awscdk.Duration_Hours(jsii.Number(1)))
+	}
+
+	// Topic receiving a summary of every run that found expired stacks
+	topic := awssns.NewTopic(stack, jsii.String("ReaperTopic"), &awssns.TopicProps{
+		DisplayName: jsii.String("Environment reaper"),
+	})
+	if props.NotificationEmail != "" {
+		awssns.NewSubscription(stack, jsii.String("ReaperEmail"), &awssns.SubscriptionProps{
+			Topic:    topic,
+			Protocol: awssns.SubscriptionProtocol_EMAIL,
+			Endpoint: jsii.String(props.NotificationEmail),
+		})
+	}
+
+	// Stacks are deleted with the CDK execution role, which owns all resources of the environments
+	executionRoleArn := awscdk.Fn_Sub(jsii.String("arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-hnb659fds-cfn-exec-role-${AWS::AccountId}-${AWS::Region}"), nil)
+
+	environment := props.Environment.Build.EnvironmentVariables()
+	(*environment)["DRY_RUN"] = jsii.String(fmt.Sprintf("%t", props.DryRun))
+	(*environment)["TOPIC_ARN"] = topic.TopicArn()
+	(*environment)["CLOUDFORMATION_ROLE_ARN"] = executionRoleArn
+
+	// Reapers outside the global region also delete the global stacks of their environments,
+	// with the execution role of the global region
+	deletableStacks := []*string{
+		awscdk.Fn_Sub(jsii.String("arn:${AWS::Partition}:cloudformation:${AWS::Region}:${AWS::AccountId}:stack/*"), nil),
+	}
+	executionRoleArns := []*string{executionRoleArn}
+	if lib.NeedsGlobalStack(props.StackProps) {
+		globalExecutionRoleArn := awscdk.Fn_Sub(jsii.String("arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-hnb659fds-cfn-exec-role-${AWS::AccountId}-"+lib.GlobalRegion), nil)
+		(*environment)["GLOBAL_REGION"] = jsii.String(lib.GlobalRegion)
+		(*environment)["GLOBAL_CLOUDFORMATION_ROLE_ARN"] = globalExecutionRoleArn
+		deletableStacks = append(deletableStacks, awscdk.Fn_Sub(jsii.String("arn:${AWS::Partition}:cloudformation:"+lib.GlobalRegion+":${AWS::AccountId}:stack/*"), nil))
+		executionRoleArns = append(executionRoleArns, globalExecutionRoleArn)
+	}
+
+	reaperFn := awslambda.NewFunction(stack, jsii.String("ReaperFunction"), &awslambda.FunctionProps{
+		Code:         awslambda.Code_FromAsset(jsii.String(codePath), nil),
+		Timeout:      awscdk.Duration_Minutes(jsii.Number(15)),
+		Runtime:      awslambda.Runtime_PROVIDED_AL2023(),
+		Architecture: awslambda.Architecture_ARM_64(),
+		Handler:      jsii.String("bootstrap"), // Must be "bootstrap" for provided.al2023
+		Environment:  environment,
+		// Only one run at a time, overlapping runs would race on the same stacks
+		ReservedConcurrentExecutions: jsii.Number(1),
+		LogGroup: awslogs.NewLogGroup(stack, jsii.String("ReaperLogGroup"), &awslogs.LogGroupProps{
+			Retention:     props.Environment.Profile.CdkLogRetention(),
+			RemovalPolicy: props.Environment.Profile.Retention.CdkLogRemovalPolicy(),
+		}),
+	})
+
+	reaperFn.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Actions:   jsii.Strings("cloudformation:DescribeStacks", "cloudformation:ListImports"),
+		Resources: jsii.Strings("*"),
+	}))
+	reaperFn.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Actions:   jsii.Strings("cloudformation:DeleteStack"),
+		Resources: &deletableStacks,
+	}))
+	reaperFn.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Actions:   jsii.Strings("iam:PassRole"),
+		Resources: &executionRoleArns,
+	}))
+	topic.GrantPublish(reaperFn)
+
+	awsevents.NewRule(stack, jsii.String("ReaperSchedule"), &awsevents.RuleProps{
+		Schedule: schedule,
+		Targets: &[]awsevents.IRuleTarget{
+			awseventstargets.NewLambdaFunction(reaperFn, nil),
+		},
+	})
+
+	awscdk.NewCfnOutput(stack, jsii.String("ReaperTopicArn"), &awscdk.CfnOutputProps{
+		Value: topic.TopicArn(),
+	})
+
+	return stack, nil
+}
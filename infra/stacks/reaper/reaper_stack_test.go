@@ -0,0 +1,123 @@
+package reaper_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/stacks/reaper"
+)
+
+func TestReaperStackSynthesizes(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	codePath := filepath.Join(t.TempDir(), "reaper.zip")
+	if err := os.WriteFile(codePath, []byte("placeholder"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := lib.Environment{
+		Name: "production",
+		Kind: lib.KindProduction,
+	}
+
+	// WHEN
+	stack, err := reaper.NewReaperStack(app, reaper.StackName, &reaper.ReaperStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("us-east-1"),
+			},
+		},
+		Environment: env,
+		CodePath:    codePath,
+		DryRun:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::SNS::Topic"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::Events::Rule"), map[string]interface{}{
+		"ScheduleExpression": "rate(1 hour)",
+	})
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"Environment": map[string]interface{}{
+			"Variables": assertions.Match_ObjectLike(&map[string]interface{}{
+				"DRY_RUN": "true",
+			}),
+		},
+	})
+}
+
+func TestReaperStackRejectsEphemeralEnvironments(t *testing.T) {
+	_, err := reaper.NewReaperStack(awscdk.NewApp(nil), reaper.StackName, &reaper.ReaperStackProps{
+		Environment: lib.Environment{Name: "pr-42", Kind: lib.KindPR},
+		CodePath:    "reaper.zip",
+	})
+	if err == nil {
+		t.Fatal("expected the reaper to be rejected in an ephemeral environment")
+	}
+}
+
+func TestReaperStackReapsGlobalStacksFromOtherRegions(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	codePath := filepath.Join(t.TempDir(), "reaper.zip")
+	if err := os.WriteFile(codePath, []byte("placeholder"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN
+	stack, err := reaper.NewReaperStack(app, reaper.StackName, &reaper.ReaperStackProps{
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("eu-central-1"),
+			},
+		},
+		Environment: lib.Environment{
+			Name:    "staging",
+			Kind:    lib.KindStaging,
+			Profile: lib.Profile{Kind: lib.KindStaging},
+		},
+		CodePath: codePath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the function deletes the global stacks in us-east-1 and keeps its logs for a while
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"Environment": map[string]interface{}{
+			"Variables": assertions.Match_ObjectLike(&map[string]interface{}{
+				"GLOBAL_REGION": lib.GlobalRegion,
+			}),
+		},
+	})
+	template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), map[string]interface{}{
+		"PolicyDocument": map[string]interface{}{
+			"Statement": assertions.Match_ArrayWith(&[]interface{}{
+				assertions.Match_ObjectLike(&map[string]interface{}{
+					"Action": "cloudformation:DeleteStack",
+					"Resource": assertions.Match_ArrayWith(&[]interface{}{
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:cloudformation:us-east-1:${AWS::AccountId}:stack/*"},
+					}),
+				}),
+			}),
+		},
+	})
+	template.HasResourceProperties(jsii.String("AWS::Logs::LogGroup"), map[string]interface{}{
+		"RetentionInDays": assertions.Match_AnyValue(),
+	})
+}
@@ -2,9 +2,11 @@ package vaultwarden
 
 import (
 	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
@@ -12,6 +14,9 @@ import (
 type ImageRepositoryProps struct {
 	ImageName string
 	Version   string
+
+	// EncryptionKey encrypts the images, the AES-256 default encryption of ECR is used when not set
+	EncryptionKey awskms.IKey
 }
 
 // ImageRepository copies the official Vaultwarden container images from Docker Hub
@@ -20,14 +25,20 @@ type ImageRepository struct {
 	Repository awsecr.Repository
 }
 
-func NewImageRepository(scope constructs.Construct, id string, props *ImageRepositoryProps, Environment lib.Environment) *ImageRepository {
+func NewImageRepository(scope constructs.Construct, id string, props *ImageRepositoryProps, environment lib.Environment) *ImageRepository {
 	construct := constructs.NewConstruct(scope, &id)
 
-	// Create an ECR repository to store the Vaultwarden image
-	repoName := Environment.GetStackName("VaultwardenImageRepository")
-	repository := awsecr.NewRepository(construct, jsii.String(repoName), &awsecr.RepositoryProps{
-		RepositoryName: jsii.String(props.ImageName),
-	})
+	// Create an ECR repository to store the Vaultwarden image, scoped to the environment
+	repoName := environment.GetStackName("VaultwardenImageRepository")
+	repositoryName := environment.ResourceName(naming.ECRRepository, props.ImageName)
+	repositoryProps := &awsecr.RepositoryProps{
+		RepositoryName: jsii.String(repositoryName),
+	}
+	if props.EncryptionKey != nil {
+		repositoryProps.Encryption = awsecr.RepositoryEncryption_KMS()
+		repositoryProps.EncryptionKey = props.EncryptionKey
+	}
+	repository := awsecr.NewRepository(construct, jsii.String(repoName), repositoryProps)
 
 	// Note: In Go CDK, we don't have a direct equivalent to cdk-ecr-deployment
 	// We would need to use a custom resource or Lambda to pull and push the image
@@ -42,9 +53,9 @@ func NewImageRepository(scope constructs.Construct, id string, props *ImageRepos
 		Value: jsii.String("To copy the Vaultwarden image to this repository, run: " +
 			"docker pull vaultwarden/server:" + props.Version + " && " +
 			"docker tag vaultwarden/server:" + props.Version + " " +
-			"${AWS::AccountId}.dkr.ecr.${AWS::Region}.amazonaws.com/" + props.ImageName + ":" + props.Version + " && " +
+			"${AWS::AccountId}.dkr.ecr.${AWS::Region}.amazonaws.com/" + repositoryName + ":" + props.Version + " && " +
 			"aws ecr get-login-password | docker login --username AWS --password-stdin ${AWS::AccountId}.dkr.ecr.${AWS::Region}.amazonaws.com && " +
-			"docker push ${AWS::AccountId}.dkr.ecr.${AWS::Region}.amazonaws.com/" + props.ImageName + ":" + props.Version),
+			"docker push ${AWS::AccountId}.dkr.ecr.${AWS::Region}.amazonaws.com/" + repositoryName + ":" + props.Version),
 	})
 	// Can we wait here and check if the image is copied?
 	// Before we can use the image, we need to ensure it's copied
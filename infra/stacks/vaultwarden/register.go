@@ -0,0 +1,52 @@
+package vaultwarden
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/stacks/core"
+)
+
+func init() {
+	lib.RegisterStack(lib.StackRegistration{
+		Name:      "vaultwarden",
+		Suffix:    "VaultwardenStack",
+		DependsOn: []string{"core"},
+		Validate: func(props *lib.StackFactoryProps) error {
+			// The core stack creates the network of environments whose profile declares one
+			return vaultwardenStackProps(props, nil).validate(props.Environment.Profile.Network != nil)
+		},
+		New: func(scope constructs.Construct, id string, props *lib.StackFactoryProps) (lib.BuiltStack, error) {
+			coreStack, err := core.FromDependencies(props)
+			if err != nil {
+				return nil, err
+			}
+			stack, err := NewVaultwardenStack(scope, id, vaultwardenStackProps(props, coreStack))
+			if err != nil {
+				return nil, err
+			}
+			return lib.PlainStack(stack), nil
+		},
+	})
+}
+
+// vaultwardenStackProps returns the props of the vaultwarden stack of the environment with the
+// resources shared by its core stack, which is nil while the props are validated
+func vaultwardenStackProps(props *lib.StackFactoryProps, coreStack *core.CoreStack) *VaultwardenStackProps {
+	stackProps := &VaultwardenStackProps{
+		StackProps:   props.StackProps,
+		Environment:  props.Environment,
+		Config:       ConfigForEnvironment(props.Environment),
+		DomainConfig: props.DomainConfig,
+	}
+	if coreStack != nil {
+		stackProps.HostedZone = coreStack.HostedZone
+		stackProps.Certificate = coreStack.Certificate
+		stackProps.Email = coreStack.Email
+		stackProps.Maintenance = coreStack.Maintenance
+		stackProps.Network = coreStack.Network
+		stackProps.Keys = coreStack.Keys
+		stackProps.Observability = coreStack.Observability
+	}
+	return stackProps
+}
@@ -1,29 +1,69 @@
 package vaultwarden
 
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsefs"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+)
+
+// lifecyclePolicies are the days after which EFS moves files to infrequent access
+var lifecyclePolicies = map[int]awsefs.LifecyclePolicy{
+	1:   awsefs.LifecyclePolicy_AFTER_1_DAY,
+	7:   awsefs.LifecyclePolicy_AFTER_7_DAYS,
+	14:  awsefs.LifecyclePolicy_AFTER_14_DAYS,
+	30:  awsefs.LifecyclePolicy_AFTER_30_DAYS,
+	60:  awsefs.LifecyclePolicy_AFTER_60_DAYS,
+	90:  awsefs.LifecyclePolicy_AFTER_90_DAYS,
+	180: awsefs.LifecyclePolicy_AFTER_180_DAYS,
+	270: awsefs.LifecyclePolicy_AFTER_270_DAYS,
+	365: awsefs.LifecyclePolicy_AFTER_365_DAYS,
+}
+
 // VaultwardenConfig contains all configurable parameters for the Vaultwarden stack
 type VaultwardenConfig struct {
 	// Base configuration
 	BaseImageName string
 	BaseVersion   string
 	DomainName    string
-	
-	// VPC configuration
-	VpcCidr  string
-	MaxAzs   int
-	
+
 	// ECS configuration
 	ClusterName  string
 	DesiredCount int
 	Cpu          int
 	MemoryMiB    int
-	
+
 	// EFS configuration
-	FileSystemName           string
-	EnableAutomaticBackups   bool
-	LifecyclePolicyDays      int
+	FileSystemName            string
+	EnableAutomaticBackups    bool
+	LifecyclePolicyDays       int
 	OutOfInfrequentAccessHits int
 }
 
+// Validate reports every problem of the configuration
+func (c *VaultwardenConfig) Validate() error {
+	var errs []error
+	if c.DesiredCount < 0 {
+		errs = append(errs, fmt.Errorf("vaultwarden desired count %d must not be negative", c.DesiredCount))
+	}
+	if c.Cpu <= 0 || c.MemoryMiB <= 0 {
+		errs = append(errs, fmt.Errorf("vaultwarden cpu %d and memory %d MiB must be set", c.Cpu, c.MemoryMiB))
+	}
+	if _, ok := lifecyclePolicies[c.LifecyclePolicyDays]; !ok {
+		errs = append(errs, fmt.Errorf("vaultwarden lifecycle policy of %d days is not supported by EFS, use one of %v",
+			c.LifecyclePolicyDays, slices.Sorted(maps.Keys(lifecyclePolicies))))
+	}
+	if c.OutOfInfrequentAccessHits != 1 {
+		errs = append(errs, fmt.Errorf("vaultwarden out of infrequent access after %d hits is not supported by EFS, use 1", c.OutOfInfrequentAccessHits))
+	}
+	return errors.Join(errs...)
+}
+
 // DefaultVaultwardenConfig returns a configuration with sensible defaults
 func DefaultVaultwardenConfig() *VaultwardenConfig {
 	return &VaultwardenConfig{
@@ -31,21 +71,32 @@ func DefaultVaultwardenConfig() *VaultwardenConfig {
 		BaseImageName: "vaultwarden/server",
 		BaseVersion:   "latest",
 		DomainName:    "",
-		
-		// VPC configuration
-		VpcCidr:  "20.0.0.0/24",
-		MaxAzs:   2,
-		
+
 		// ECS configuration
 		ClusterName:  "vaultwarden-cluster",
 		DesiredCount: 1,
 		Cpu:          256, // 0.25 vCPU
 		MemoryMiB:    512, // 512 MB RAM
-		
+
 		// EFS configuration
-		FileSystemName:           "vaultwarden-fs",
-		EnableAutomaticBackups:   true,
-		LifecyclePolicyDays:      14,
+		FileSystemName:            "vaultwarden-fs",
+		EnableAutomaticBackups:    true,
+		LifecyclePolicyDays:       14,
 		OutOfInfrequentAccessHits: 1,
 	}
 }
+
+// ConfigForEnvironment returns the default configuration named and sized for the environment
+func ConfigForEnvironment(env lib.Environment) *VaultwardenConfig {
+	config := DefaultVaultwardenConfig()
+	config.ClusterName = env.ResourceName(naming.ECSCluster, "vaultwarden-cluster")
+	config.FileSystemName = env.ResourceName(naming.FileSystem, "vaultwarden-fs")
+
+	// Use the sizing of the environment profile, if it has one
+	if sizing := env.Profile.Sizing; sizing.Cpu > 0 {
+		config.DesiredCount = sizing.DesiredCount
+		config.Cpu = sizing.Cpu
+		config.MemoryMiB = sizing.MemoryMiB
+	}
+	return config
+}
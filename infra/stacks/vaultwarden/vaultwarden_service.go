@@ -1,30 +1,60 @@
 package vaultwarden
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsecs"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsecspatterns"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsefs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
+
+	"aws-infra-sandbox/stacks/core"
 )
 
 type VaultwardenServiceProps struct {
-	Cluster        awsecs.Cluster
-	ImageRepository awsecr.Repository
-	Version        string
-	Filesystem     awsefs.FileSystem
-	DomainName     *string
-	DesiredCount   int
-	Cpu            int
-	MemoryMiB      int
-	HostedZone     awsroute53.IHostedZone
+	Cluster          awsecs.Cluster
+	ImageRepository  awsecr.Repository
+	Version          string
+	Filesystem       awsefs.FileSystem
+	DomainName       *string
+	DesiredCount     int
+	Cpu              int
+	MemoryMiB        int
+	HostedZone       awsroute53.IHostedZone
+	LoadBalancerName string
+
+	// Subnets the tasks run in, defaults to the isolated subnets of the cluster's VPC
+	Subnets *awsec2.SubnetSelection
+
+	// Certificate for the domain name and its aliases, a certificate is created when it is not set
+	Certificate awscertificatemanager.ICertificate
+
+	// AliasDomainNames redirect permanently to the domain name
+	AliasDomainNames []string
+
+	// Email configures the SES SMTP endpoint as the mail server with SMTP credentials of the
+	// service when set
+	Email *core.EmailIdentity
+
+	// SmtpCredentials configure the credentials the service sends mail with, they must be set
+	// with Email
+	SmtpCredentials *core.SmtpCredentialsProps
+
+	// LogGroup receives the container logs, the service creates one when it is not set
+	LogGroup awslogs.ILogGroup
+
+	// Maintenance fails the domain name over to the maintenance page while /alive fails when set
+	Maintenance *core.MaintenanceSite
 }
 
 // VaultwardenService creates an ECS service to run Vaultwarden containers
@@ -34,37 +64,44 @@ type VaultwardenService struct {
 
 func NewVaultwardenService(scope constructs.Construct, id string, props *VaultwardenServiceProps) *VaultwardenService {
 	construct := constructs.NewConstruct(scope, &id)
-	
+
 	// Create an execution role for the ECS task
 	executionRole := awsiam.NewRole(construct, jsii.String("TaskExecRole"), &awsiam.RoleProps{
 		AssumedBy: awsiam.NewServicePrincipal(jsii.String("ecs-tasks.amazonaws.com"), nil),
 	})
-	
+
 	// Grant the execution role permission to pull from the ECR repository
 	props.ImageRepository.GrantPull(executionRole)
-	
-	// Create a certificate if a domain name is provided
-	var certificate awscertificatemanager.Certificate
-	if props.DomainName != nil && props.HostedZone != nil {
+
+	// Create a certificate if a domain name is provided and none is shared
+	certificate := props.Certificate
+	if certificate == nil && props.DomainName != nil && props.HostedZone != nil {
 		certificate = awscertificatemanager.NewCertificate(construct, jsii.String("VaultwardenCertificate"), &awscertificatemanager.CertificateProps{
-			DomainName: props.DomainName,
-			Validation: awscertificatemanager.CertificateValidation_FromDns(props.HostedZone),
+			DomainName:              props.DomainName,
+			SubjectAlternativeNames: jsii.Strings(props.AliasDomainNames...),
+			Validation:              awscertificatemanager.CertificateValidation_FromDns(props.HostedZone),
 		})
-		
+
 		// Output a warning about certificate validation
 		awscdk.NewCfnOutput(construct, jsii.String("CertificateValidationWarning"), &awscdk.CfnOutputProps{
 			Value: jsii.String("IMPORTANT: You need to validate the SSL certificate by adding DNS records. " +
 				"The deployment will wait until validation is complete. Check the ACM console for details."),
 		})
 	}
-	
+
+	// Use default values if not provided
+	subnets := props.Subnets
+	if subnets == nil {
+		subnets = &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED}
+	}
+
 	// Create a security group for specific egress rules
 	securityGroup := awsec2.NewSecurityGroup(construct, jsii.String("VaultwardenSecurityGroup"), &awsec2.SecurityGroupProps{
-		Vpc: props.Cluster.Vpc(),
+		Vpc:              props.Cluster.Vpc(),
 		AllowAllOutbound: jsii.Bool(false),
-		Description: jsii.String("Security group for Vaultwarden service"),
+		Description:      jsii.String("Security group for Vaultwarden service"),
 	})
-	
+
 	// Add specific egress rules
 	securityGroup.AddEgressRule(
 		awsec2.Peer_AnyIpv4(),
@@ -72,53 +109,96 @@ func NewVaultwardenService(scope constructs.Construct, id string, props *Vaultwa
 		jsii.String("Allow HTTPS outbound traffic"),
 		nil,
 	)
-	
+
+	// Send mail through SES with SMTP credentials of the service, tasks in isolated subnets
+	// reach SMTP through an endpoint in their subnets
+	var smtpCredentials *core.SmtpCredentials
+	var secrets *map[string]awsecs.Secret
+	if props.Email != nil {
+		smtpCredentials = props.Email.NewSmtpCredentials(construct, "SmtpCredentials", props.SmtpCredentials)
+		secrets = &map[string]awsecs.Secret{
+			"SMTP_USERNAME": awsecs.Secret_FromSecretsManager(smtpCredentials.Secret, jsii.String(core.SmtpUsernameKey)),
+			"SMTP_PASSWORD": awsecs.Secret_FromSecretsManager(smtpCredentials.Secret, jsii.String(core.SmtpPasswordKey)),
+		}
+		securityGroup.AddEgressRule(
+			awsec2.Peer_Ipv4(props.Cluster.Vpc().VpcCidrBlock()),
+			awsec2.Port_Tcp(jsii.Number(587)),
+			jsii.String("Allow SMTP to the SES endpoint"),
+			nil,
+		)
+		awsec2.NewInterfaceVpcEndpoint(construct, jsii.String("SmtpEndpoint"), &awsec2.InterfaceVpcEndpointProps{
+			Vpc:               props.Cluster.Vpc(),
+			Service:           awsec2.InterfaceVpcEndpointAwsService_EMAIL_SMTP(),
+			PrivateDnsEnabled: jsii.Bool(true),
+			Subnets:           subnets,
+		})
+	}
+
 	// Use default values if not provided
 	desiredCount := 1
 	if props.DesiredCount > 0 {
 		desiredCount = props.DesiredCount
 	}
-	
+
 	cpu := 256
 	if props.Cpu > 0 {
 		cpu = props.Cpu
 	}
-	
+
 	memoryMiB := 512
 	if props.MemoryMiB > 0 {
 		memoryMiB = props.MemoryMiB
 	}
-	
+
 	// Create the Fargate service with an Application Load Balancer
+	// Send the container logs to the given log group, the pattern creates one otherwise. The
+	// stream prefix is the one the pattern uses.
+	var logDriver awsecs.LogDriver
+	if props.LogGroup != nil {
+		logDriver = awsecs.LogDriver_AwsLogs(&awsecs.AwsLogDriverProps{
+			StreamPrefix: jsii.String("VaultwardenService"),
+			LogGroup:     props.LogGroup,
+		})
+	}
+
 	serviceProps := &awsecspatterns.ApplicationLoadBalancedFargateServiceProps{
 		Cluster:        props.Cluster,
 		DesiredCount:   jsii.Number(float64(desiredCount)),
 		Cpu:            jsii.Number(float64(cpu)),
 		MemoryLimitMiB: jsii.Number(float64(memoryMiB)),
-		
+
 		TaskImageOptions: &awsecspatterns.ApplicationLoadBalancedTaskImageOptions{
 			Image:         awsecs.ContainerImage_FromEcrRepository(props.ImageRepository, jsii.String(props.Version)),
 			ExecutionRole: executionRole,
-			Environment:   generateVaultwardenEnvironmentVariables(),
-		},
-		
-		TaskSubnets: &awsec2.SubnetSelection{
-			SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
+			Environment:   generateVaultwardenEnvironmentVariables(props.Email),
+			Secrets:       secrets,
+			LogDriver:     logDriver,
 		},
-		
+
+		TaskSubnets: subnets,
+
 		PublicLoadBalancer: jsii.Bool(true),
 		Certificate:        certificate,
-		
+
 		// Fix the minHealthyPercent warning
 		MinHealthyPercent: jsii.Number(100),
-		
+
 		// Add the security group
 		SecurityGroups: &[]awsec2.ISecurityGroup{securityGroup},
 	}
-	
+
+	// Give the load balancer a predictable name when one is provided
+	if props.LoadBalancerName != "" {
+		serviceProps.LoadBalancerName = jsii.String(props.LoadBalancerName)
+	}
+
 	// Create the Fargate service
 	service := awsecspatterns.NewApplicationLoadBalancedFargateService(construct, jsii.String("VaultwardenService"), serviceProps)
-	
+	if smtpCredentials != nil {
+		// Tasks read the password when they start
+		service.Service().Node().AddDependency(smtpCredentials.Password)
+	}
+
 	// Add EFS volume to the task definition
 	service.TaskDefinition().AddVolume(&awsecs.Volume{
 		Name: jsii.String("efs"),
@@ -127,44 +207,96 @@ func NewVaultwardenService(scope constructs.Construct, id string, props *Vaultwa
 			TransitEncryption: jsii.String("ENABLED"),
 		},
 	})
-	
+
 	// Mount the EFS volume to the container
 	service.TaskDefinition().DefaultContainer().AddMountPoints(&awsecs.MountPoint{
 		SourceVolume:  jsii.String("efs"),
 		ContainerPath: jsii.String("/data"),
 		ReadOnly:      jsii.Bool(false),
 	})
-	
+
 	// Allow network connectivity between the service and the EFS filesystem
 	service.Service().Connections().AllowFrom(props.Filesystem, awsec2.Port_Tcp(jsii.Number(2049)), jsii.String("Allow EFS access from Vaultwarden"))
 	service.Service().Connections().AllowTo(props.Filesystem, awsec2.Port_Tcp(jsii.Number(2049)), jsii.String("Allow Vaultwarden to access EFS"))
-	
+
 	// Create Route53 A record for the custom domain if domain name is provided
 	if props.DomainName != nil && props.HostedZone != nil {
-		awsroute53.NewARecord(construct, jsii.String("VaultwardenDnsRecord"), &awsroute53.ARecordProps{
-			Zone:       props.HostedZone,
-			RecordName: props.DomainName,
-			Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewLoadBalancerTarget(service.LoadBalancer(), nil)),
+		target := awsroute53.RecordTarget_FromAlias(awsroute53targets.NewLoadBalancerTarget(service.LoadBalancer(), nil))
+		if props.Maintenance != nil && props.Maintenance.Covers(*props.DomainName) {
+			// The load balancer answers every hostname, so the health check reaches it on an
+			// origin hostname that is never failed over
+			awsroute53.NewARecord(construct, jsii.String("VaultwardenOriginRecord"), &awsroute53.ARecordProps{
+				Zone:       props.HostedZone,
+				RecordName: jsii.String(props.Maintenance.OriginHostname("vault")),
+				Target:     target,
+			})
+			healthCheck := props.Maintenance.AddFailover("vault", *props.DomainName, "/alive")
+			core.NewPrimaryRecord(construct, "VaultwardenPrimaryRecord", props.HostedZone, *props.DomainName, target, healthCheck)
+		} else {
+			if props.Maintenance != nil {
+				awscdk.Annotations_Of(construct).AddWarningV2(jsii.String("aws-infra-sandbox:failoverHostname"),
+					jsii.String(fmt.Sprintf("%s is not covered by the maintenance page and does not fail over", *props.DomainName)))
+			}
+			awsroute53.NewARecord(construct, jsii.String("VaultwardenDnsRecord"), &awsroute53.ARecordProps{
+				Zone:       props.HostedZone,
+				RecordName: props.DomainName,
+				Target:     target,
+			})
+		}
+	}
+
+	// Redirect the aliases to the domain name
+	if props.DomainName != nil && props.HostedZone != nil && len(props.AliasDomainNames) > 0 {
+		service.Listener().AddAction(jsii.String("AliasRedirect"), &awselasticloadbalancingv2.AddApplicationActionProps{
+			Priority:   jsii.Number(10),
+			Conditions: &[]awselasticloadbalancingv2.ListenerCondition{awselasticloadbalancingv2.ListenerCondition_HostHeaders(jsii.Strings(props.AliasDomainNames...))},
+			Action: awselasticloadbalancingv2.ListenerAction_Redirect(&awselasticloadbalancingv2.RedirectOptions{
+				Host:      props.DomainName,
+				Permanent: jsii.Bool(true),
+			}),
 		})
+
+		for _, alias := range props.AliasDomainNames {
+			// Aliases only redirect, they keep a simple record even when the domain name fails over
+			if props.Maintenance != nil {
+				awscdk.Annotations_Of(construct).AddWarningV2(jsii.String("aws-infra-sandbox:failoverHostname"),
+					jsii.String(fmt.Sprintf("%s redirects to %s and does not fail over", alias, *props.DomainName)))
+			}
+			awsroute53.NewARecord(construct, jsii.String("VaultwardenAliasRecord-"+alias), &awsroute53.ARecordProps{
+				Zone:       props.HostedZone,
+				RecordName: jsii.String(alias),
+				Target:     awsroute53.RecordTarget_FromAlias(awsroute53targets.NewLoadBalancerTarget(service.LoadBalancer(), nil)),
+			})
+		}
 	}
-	
+
 	// Output the load balancer DNS name
 	awscdk.NewCfnOutput(construct, jsii.String("LoadBalancerDnsName"), &awscdk.CfnOutputProps{
 		Description: jsii.String("The DNS name of the load balancer for the Vaultwarden service"),
 		Value:       service.LoadBalancer().LoadBalancerDnsName(),
 	})
-	
+
 	return &VaultwardenService{
 		Service: service,
 	}
 }
 
 // generateVaultwardenEnvironmentVariables creates environment variables for Vaultwarden configuration
-func generateVaultwardenEnvironmentVariables() *map[string]*string {
+func generateVaultwardenEnvironmentVariables(email *core.EmailIdentity) *map[string]*string {
 	// In a real implementation, you would read these from environment variables or parameters
 	// For now, we'll just return some basic configuration
-	return &map[string]*string{
+	variables := map[string]*string{
 		"WEBSOCKET_ENABLED": jsii.String("true"),
 		"LOG_LEVEL":         jsii.String("info"),
 	}
+
+	// Send mail through the SES SMTP endpoint, the credentials are passed as secrets
+	if email != nil {
+		variables["SMTP_HOST"] = email.SmtpEndpoint()
+		variables["SMTP_PORT"] = jsii.String("587")
+		variables["SMTP_SECURITY"] = jsii.String("starttls")
+		variables["SMTP_FROM"] = jsii.String("vaultwarden@" + email.Domain)
+		variables["SMTP_FROM_NAME"] = jsii.String("Vaultwarden")
+	}
+	return &variables
 }
@@ -1,17 +1,24 @@
 package vaultwarden
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsecs"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsefs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 
 	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+	"aws-infra-sandbox/stacks/core"
 )
 
 type VaultwardenStackProps struct {
@@ -19,31 +26,97 @@ type VaultwardenStackProps struct {
 	Environment  lib.Environment
 	Config       *VaultwardenConfig
 	DomainConfig *lib.DomainConfig
+
+	// HostedZone and Certificate are shared by CoreStack, the stack imports the zone and
+	// creates its own certificate when they are not set
+	HostedZone  awsroute53.IHostedZone
+	Certificate awscertificatemanager.ICertificate
+
+	// Email is the SES identity of the environment, Vaultwarden sends invites and 2FA mail through it
+	Email *core.EmailIdentity
+
+	// SmtpPasswordCodePath is the prebuilt SMTP password function, core.SmtpPasswordFunctionDir
+	// is built during synthesis when it is not set and Email is
+	SmtpPasswordCodePath string
+
+	// Maintenance is shared by CoreStack when failover is enabled
+	Maintenance *core.MaintenanceSite
+
+	// Network is the VPC of the environment shared by CoreStack, the stack cannot be built without it
+	Network *core.EnvironmentNetwork
+
+	// Observability is shared by CoreStack, the stack alarms on the CPU and memory utilization of
+	// the service and adds them to the environment dashboard when set
+	Observability *core.Observability
+
+	// Keys are shared by CoreStack, the image repository, file system, logs and SMTP credentials
+	// are encrypted with them when set
+	Keys *core.EnvironmentKeys
 }
 
-// VaultwardenStack encapsulates all resources needed to run Vaultwarden on AWS
-func NewVaultwardenStack(scope constructs.Construct, id string, props *VaultwardenStackProps) awscdk.Stack {
-	var sprops awscdk.StackProps
-	if props != nil {
-		sprops = props.StackProps
+// config returns the configuration from the props or the default
+func (p *VaultwardenStackProps) config() *VaultwardenConfig {
+	if p.Config == nil {
+		return DefaultVaultwardenConfig()
 	}
-	stack := awscdk.NewStack(scope, &id, &sprops)
+	return p.Config
+}
 
-	// Add environment tags
-	awscdk.Tags_Of(stack).Add(jsii.String("x:stack"), jsii.String("vaultwarden"), nil)
+// domainConfig returns the domain config from the props or the default
+func (p *VaultwardenStackProps) domainConfig() *lib.DomainConfig {
+	if p.DomainConfig == nil {
+		return lib.DefaultDomainConfig()
+	}
+	return p.DomainConfig
+}
+
+// Validate reports every problem of the props, such as a missing network or an EFS lifecycle
+// policy that does not exist
+func (p *VaultwardenStackProps) Validate() error {
+	if p == nil {
+		return errors.New("vaultwarden stack props must be set")
+	}
+	return p.validate(p.Network != nil)
+}
 
-	// Use configuration from props or environment variables
-	config := props.Config
-	if config == nil {
-		config = DefaultVaultwardenConfig()
+// validate reports every problem of the props, given whether the environment has a network
+func (p *VaultwardenStackProps) validate(hasNetwork bool) error {
+	errs := []error{p.domainConfig().Validate(p.Environment), p.config().Validate()}
+	// Vaultwarden runs in the VPC of the environment
+	if !hasNetwork {
+		errs = append(errs, errors.New("vaultwarden needs the environment network, declare network in the environment profile"))
 	}
+	return errors.Join(errs...)
+}
 
-	// Use domain config from props or default
-	domainConfig := props.DomainConfig
-	if domainConfig == nil {
-		domainConfig = lib.DefaultDomainConfig()
+// VaultwardenStack encapsulates all resources needed to run Vaultwarden on AWS, it returns the
+// problems of the props instead when they are invalid
+func NewVaultwardenStack(scope constructs.Construct, id string, props *VaultwardenStackProps) (awscdk.Stack, error) {
+	if err := props.Validate(); err != nil {
+		return nil, err
 	}
 
+	smtpPasswordCodePath := props.SmtpPasswordCodePath
+	if props.Email != nil && smtpPasswordCodePath == "" {
+		var err error
+		smtpPasswordCodePath, err = core.BuildSmtpPasswordFunction()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sprops := props.StackProps
+	if props.Maintenance != nil {
+		sprops = props.Maintenance.WithCrossRegionReferences(sprops)
+	}
+	stack := awscdk.NewStack(scope, &id, &sprops)
+
+	// Add environment tags
+	awscdk.Tags_Of(stack).Add(jsii.String("x:stack"), jsii.String("vaultwarden"), nil)
+
+	network := props.Network
+	config := props.config()
+	domainConfig := props.domainConfig()
+
 	// Override with environment variables if set
 	baseVersion := os.Getenv("VAULTWARDEN_BASE_VERSION")
 	if baseVersion != "" {
@@ -61,92 +134,135 @@ func NewVaultwardenStack(scope constructs.Construct, id string, props *Vaultward
 		config.DomainName = domainConfig.GetAppDomain("vault", props.Environment)
 	}
 
-	// Reference the hosted zone from CoreStack
-	hostedZone := awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.HostedZoneAttributes{
-		HostedZoneId: jsii.String(domainConfig.HostedZoneId),
-		ZoneName:     jsii.String(domainConfig.RootDomain),
+	// Aliases redirect to the domain name, the wildcard certificate from CoreStack is only
+	// used if it covers all of them
+	aliases := domainConfig.GetAliasDomains("vault", props.Environment)
+	certificate := props.Certificate
+	if !lib.CoveredByWildcard(domainConfig.GetEnvironmentDomain(props.Environment), append([]string{config.DomainName}, aliases...)...) {
+		certificate = nil
+	}
+
+	// Use the hosted zone from CoreStack
+	hostedZone := props.HostedZone
+	if hostedZone == nil {
+		hostedZone = awsroute53.HostedZone_FromHostedZoneAttributes(stack, jsii.String("EbboDevZone"), &awsroute53.HostedZoneAttributes{
+			HostedZoneId: jsii.String(domainConfig.HostedZoneId),
+			ZoneName:     jsii.String(domainConfig.RootDomain),
+		})
+	}
+
+	// Create the image repository and the log group of the containers, encrypted with the keys
+	// of the environment if it has them
+	var dataKey, logsKey, secretsKey awskms.IKey
+	if props.Keys != nil {
+		dataKey = props.Keys.Data
+		logsKey = props.Keys.Logs
+		secretsKey = props.Keys.Secrets
+	}
+	logGroup := awslogs.NewLogGroup(stack, jsii.String("VaultwardenLogGroup"), &awslogs.LogGroupProps{
+		LogGroupName:  jsii.String(props.Environment.ResourceName(naming.LogGroup, "vaultwarden")),
+		EncryptionKey: logsKey,
+		Retention:     props.Environment.Profile.CdkLogRetention(),
+		RemovalPolicy: props.Environment.Profile.Retention.CdkLogRemovalPolicy(),
 	})
 
-	// Create the image repository
 	imageRepository := NewImageRepository(stack, "ImageRepository", &ImageRepositoryProps{
-		ImageName: config.BaseImageName,
-		Version:   config.BaseVersion,
+		ImageName:     config.BaseImageName,
+		Version:       config.BaseVersion,
+		EncryptionKey: dataKey,
 	}, props.Environment)
 
-	// Create the network infrastructure
-	network := NewNetwork(stack, "Network", &NetworkProps{
-		VpcCidr: config.VpcCidr,
-		MaxAzs:  config.MaxAzs,
-	})
-
 	// Create the ECS cluster
 	cluster := awsecs.NewCluster(stack, jsii.String("VaultwardenCluster"), &awsecs.ClusterProps{
 		ClusterName: jsii.String(config.ClusterName),
 		Vpc:         network.Vpc,
 	})
 
-	// Allow the cluster to access AWS services via VPC endpoints
-	network.EcrEndpoint.Connections().AllowDefaultPortFrom(cluster.Connections(), jsii.String("Allow ECR API access"))
-	network.EcrRepositoryEndpoint.Connections().AllowDefaultPortFrom(cluster.Connections(), jsii.String("Allow ECR Repository access"))
-	network.CloudwatchEndpoint.Connections().AllowDefaultPortFrom(cluster.Connections(), jsii.String("Allow CloudWatch access"))
-
 	// Create an EFS filesystem for persistent storage
-	var lifecyclePolicy awsefs.LifecyclePolicy
-	if config.LifecyclePolicyDays == 7 {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_7_DAYS
-	} else if config.LifecyclePolicyDays == 14 {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_14_DAYS
-	} else if config.LifecyclePolicyDays == 30 {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_30_DAYS
-	} else if config.LifecyclePolicyDays == 60 {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_60_DAYS
-	} else if config.LifecyclePolicyDays == 90 {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_90_DAYS
-	} else {
-		lifecyclePolicy = awsefs.LifecyclePolicy_AFTER_14_DAYS // Default
-	}
-
-	var outOfInfrequentAccessPolicy awsefs.OutOfInfrequentAccessPolicy
-	if config.OutOfInfrequentAccessHits == 1 {
-		outOfInfrequentAccessPolicy = awsefs.OutOfInfrequentAccessPolicy_AFTER_1_ACCESS
-	} else {
-		outOfInfrequentAccessPolicy = awsefs.OutOfInfrequentAccessPolicy_AFTER_1_ACCESS // Default
-	}
-
 	filesystem := awsefs.NewFileSystem(stack, jsii.String("VaultwardenFS"), &awsefs.FileSystemProps{
 		FileSystemName: jsii.String(config.FileSystemName),
 
-		Vpc: network.Vpc,
-		VpcSubnets: &awsec2.SubnetSelection{
-			SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
-		},
+		Vpc:        network.Vpc,
+		VpcSubnets: network.WorkloadSubnets,
 
 		Encrypted:              jsii.Bool(true),
+		KmsKey:                 dataKey,
 		PerformanceMode:        awsefs.PerformanceMode_GENERAL_PURPOSE,
 		EnableAutomaticBackups: jsii.Bool(config.EnableAutomaticBackups),
 
-		LifecyclePolicy:             lifecyclePolicy,
-		OutOfInfrequentAccessPolicy: outOfInfrequentAccessPolicy,
+		LifecyclePolicy:             lifecyclePolicies[config.LifecyclePolicyDays],
+		OutOfInfrequentAccessPolicy: awsefs.OutOfInfrequentAccessPolicy_AFTER_1_ACCESS,
 	})
 
+	var smtpCredentials *core.SmtpCredentialsProps
+	if props.Email != nil {
+		smtpCredentials = &core.SmtpCredentialsProps{
+			Environment: props.Environment,
+			SecretsKey:  secretsKey,
+			LogsKey:     logsKey,
+			CodePath:    smtpPasswordCodePath,
+		}
+	}
+
 	// Create the Vaultwarden service with domain name
-	NewVaultwardenService(stack, "VaultwardenService", &VaultwardenServiceProps{
-		Cluster:         cluster,
-		ImageRepository: imageRepository.Repository,
-		Version:         config.BaseVersion,
-		Filesystem:      filesystem,
-		DomainName:      jsii.String(config.DomainName),
-		DesiredCount:    config.DesiredCount,
-		Cpu:             config.Cpu,
-		MemoryMiB:       config.MemoryMiB,
-		HostedZone:      hostedZone,
+	service := NewVaultwardenService(stack, "VaultwardenService", &VaultwardenServiceProps{
+		Cluster:          cluster,
+		ImageRepository:  imageRepository.Repository,
+		Version:          config.BaseVersion,
+		Filesystem:       filesystem,
+		Subnets:          network.WorkloadSubnets,
+		DomainName:       jsii.String(config.DomainName),
+		DesiredCount:     config.DesiredCount,
+		Cpu:              config.Cpu,
+		MemoryMiB:        config.MemoryMiB,
+		HostedZone:       hostedZone,
+		Certificate:      certificate,
+		AliasDomainNames: aliases,
+		Email:            props.Email,
+		SmtpCredentials:  smtpCredentials,
+		Maintenance:      props.Maintenance,
+		LogGroup:         logGroup,
+		LoadBalancerName: props.Environment.ResourceName(naming.LoadBalancer, "vault"),
 	})
 
+	if props.Observability != nil {
+		observeService(stack, props.Observability, service.Service.Service(), props.Environment)
+	}
+
 	// Output the domain name
 	awscdk.NewCfnOutput(stack, jsii.String("VaultwardenDomainName"), &awscdk.CfnOutputProps{
 		Description: jsii.String("The domain name for the Vaultwarden service"),
 		Value:       jsii.String(config.DomainName),
 	})
 
-	return stack
+	return stack, nil
+}
+
+// observeService alarms when the service runs out of CPU or memory and adds both to the
+// environment dashboard
+func observeService(stack awscdk.Stack, observability *core.Observability, service awsecs.FargateService, env lib.Environment) {
+	period := awscdk.Duration_Minutes(jsii.Number(5))
+	cpu := service.MetricCpuUtilization(&awscloudwatch.MetricOptions{Period: period})
+	memory := service.MetricMemoryUtilization(&awscloudwatch.MetricOptions{Period: period})
+
+	for _, utilization := range []struct {
+		name   string
+		metric awscloudwatch.Metric
+	}{{"cpu", cpu}, {"memory", memory}} {
+		observability.AddAlarms(utilization.metric.CreateAlarm(stack, jsii.String("Vaultwarden-"+utilization.name+"-Alarm"), &awscloudwatch.CreateAlarmOptions{
+			AlarmName:          jsii.String(env.ResourceName(naming.Alarm, "vault", utilization.name)),
+			AlarmDescription:   jsii.String(fmt.Sprintf("Vaultwarden uses more than 80%% of its %s", utilization.name)),
+			Threshold:          jsii.Number(80),
+			EvaluationPeriods:  jsii.Number(3),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_THRESHOLD,
+			TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+		}))
+	}
+
+	observability.AddWidgets(awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+		Title:     jsii.String("Vaultwarden CPU and memory utilization"),
+		Left:      &[]awscloudwatch.IMetric{cpu, memory},
+		LeftYAxis: &awscloudwatch.YAxisProps{Min: jsii.Number(0), Max: jsii.Number(100), Label: jsii.String("%")},
+		Width:     jsii.Number(12),
+	}))
 }
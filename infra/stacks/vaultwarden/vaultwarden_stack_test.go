@@ -1,12 +1,17 @@
 package vaultwarden_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
 	"github.com/aws/jsii-runtime-go"
 	
 	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/stacks/core"
 	"aws-infra-sandbox/stacks/vaultwarden"
 )
 
@@ -20,16 +25,25 @@ func TestVaultwardenStackSynthesizes(t *testing.T) {
 		Username: "tester",
 	}
 	
-	// WHEN
-	stack := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
-		StackProps: awscdk.StackProps{
-			Env: &awscdk.Environment{
-				Account: jsii.String("123456789012"),
-				Region:  jsii.String("us-east-1"),
-			},
+	// The network is shared by CoreStack
+	stackProps := awscdk.StackProps{
+		Env: &awscdk.Environment{
+			Account: jsii.String("123456789012"),
+			Region:  jsii.String("us-east-1"),
 		},
+	}
+	networkStack := awscdk.NewStack(app, jsii.String("TestNetworkStack"), &stackProps)
+	network := core.NewEnvironmentNetwork(networkStack, "Network", &lib.NetworkConfig{})
+
+	// WHEN
+	stack, err := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
+		StackProps:  stackProps,
 		Environment: env,
+		Network:     network,
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	
 	// THEN - the stack should synthesize without errors
 	// In Go CDK, we don't have a direct equivalent to Template_FromStack
@@ -37,4 +51,202 @@ func TestVaultwardenStackSynthesizes(t *testing.T) {
 	if stack == nil {
 		t.Fatal("Stack should not be nil")
 	}
+
+	// The stack runs in the shared VPC instead of creating its own
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::EC2::VPC"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::EC2::VPCEndpoint"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::ECS::Service"), jsii.Number(1))
+}
+
+func TestVaultwardenStackRequiresTheEnvironmentNetwork(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+
+	// WHEN
+	_, err := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
+		Environment: lib.Environment{Name: "test", Username: "tester"},
+	})
+
+	// THEN
+	if err == nil || !strings.Contains(err.Error(), "needs the environment network") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVaultwardenStackReportsEveryConfigProblem(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	config := vaultwarden.DefaultVaultwardenConfig()
+	config.LifecyclePolicyDays = 45
+
+	// WHEN
+	_, err := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
+		Environment:  lib.Environment{Name: "test", Username: "tester"},
+		Config:       config,
+		DomainConfig: &lib.DomainConfig{RootDomain: "example.com"},
+	})
+
+	// THEN
+	if err == nil {
+		t.Fatal("expected the config to be rejected")
+	}
+	for _, problem := range []string{
+		"hosted zone ID of example.com must be set",
+		"lifecycle policy of 45 days is not supported",
+		"needs the environment network",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+}
+
+func TestVaultwardenStackRequiresProps(t *testing.T) {
+	if _, err := vaultwarden.NewVaultwardenStack(awscdk.NewApp(nil), "TestVaultwardenStack", nil); err == nil {
+		t.Fatal("expected nil props to be rejected")
+	}
+}
+
+func TestVaultwardenStackSendsMailWithSmtpCredentials(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+		Profile: lib.Profile{
+			Network: &lib.NetworkConfig{},
+		},
+	}
+	stackProps := awscdk.StackProps{
+		Env: &awscdk.Environment{
+			Account: jsii.String("123456789012"),
+			Region:  jsii.String("eu-central-1"),
+		},
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Email = &lib.EmailConfig{}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:   stackProps,
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codePath := filepath.Join(t.TempDir(), "smtp-password.zip")
+	if err := os.WriteFile(codePath, []byte("placeholder"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN
+	stack, err := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
+		StackProps:           stackProps,
+		Environment:          env,
+		DomainConfig:         domainConfig,
+		HostedZone:           coreStack.HostedZone,
+		Certificate:          coreStack.Certificate,
+		Email:                coreStack.Email,
+		SmtpPasswordCodePath: codePath,
+		Network:              coreStack.Network,
+		Keys:                 coreStack.Keys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the SMTP credentials of an IAM user are encrypted and passed to the container as secrets
+	template := assertions.Template_FromStack(stack, nil)
+	template.ResourceCountIs(jsii.String("AWS::IAM::AccessKey"), jsii.Number(1))
+	template.ResourceCountIs(jsii.String("AWS::SecretsManager::Secret"), jsii.Number(2))
+	template.AllResourcesProperties(jsii.String("AWS::SecretsManager::Secret"), map[string]interface{}{
+		"KmsKeyId": assertions.Match_AnyValue(),
+	})
+
+	// THEN - the password is derived by a Go function from the access key, which keeps its own secret
+	template.HasResourceProperties(jsii.String("Custom::SmtpPassword"), map[string]interface{}{
+		"SourceSecretId": assertions.Match_AnyValue(),
+		"SecretId":       assertions.Match_AnyValue(),
+		"Region":         "eu-central-1",
+	})
+	template.AllResourcesProperties(jsii.String("AWS::Lambda::Function"), map[string]interface{}{
+		"Runtime": "provided.al2023",
+	})
+	template.HasResourceProperties(jsii.String("AWS::ECS::TaskDefinition"), map[string]interface{}{
+		"ContainerDefinitions": assertions.Match_ArrayWith(&[]interface{}{
+			assertions.Match_ObjectLike(&map[string]interface{}{
+				"Environment": assertions.Match_ArrayWith(&[]interface{}{
+					map[string]interface{}{"Name": "SMTP_FROM", "Value": "vaultwarden@staging.ebbo.dev"},
+				}),
+				"Secrets": assertions.Match_ArrayWith(&[]interface{}{
+					assertions.Match_ObjectLike(&map[string]interface{}{"Name": "SMTP_PASSWORD"}),
+					assertions.Match_ObjectLike(&map[string]interface{}{"Name": "SMTP_USERNAME"}),
+				}),
+			}),
+		}),
+	})
+	template.HasResourceProperties(jsii.String("AWS::EC2::VPCEndpoint"), map[string]interface{}{
+		"ServiceName": "com.amazonaws.eu-central-1.email-smtp",
+	})
+}
+
+func TestVaultwardenStackWarnsThatAliasesDoNotFailOver(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	env := lib.Environment{
+		Name: "staging",
+		Kind: lib.KindStaging,
+		Profile: lib.Profile{
+			Network: &lib.NetworkConfig{},
+		},
+	}
+	stackProps := awscdk.StackProps{
+		Env: &awscdk.Environment{
+			Account: jsii.String("123456789012"),
+			Region:  jsii.String("eu-central-1"),
+		},
+	}
+	domainConfig := lib.DefaultDomainConfig()
+	domainConfig.Failover = &lib.FailoverConfig{}
+	domainConfig.Strategy = lib.CustomHostnames{
+		Overrides: map[string]lib.HostnameOverride{
+			"vault": {Aliases: []string{"passwords.staging.ebbo.dev"}},
+		},
+	}
+	coreStack, err := core.NewCoreStack(app, "TestCoreStack", &core.CoreStackProps{
+		StackProps:   stackProps,
+		Environment:  env,
+		DomainConfig: domainConfig,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WHEN
+	stack, err := vaultwarden.NewVaultwardenStack(app, "TestVaultwardenStack", &vaultwarden.VaultwardenStackProps{
+		StackProps:   stackProps,
+		Environment:  env,
+		DomainConfig: domainConfig,
+		HostedZone:   coreStack.HostedZone,
+		Certificate:  coreStack.Certificate,
+		Maintenance:  coreStack.Maintenance,
+		Network:      coreStack.Network,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// THEN - the domain name fails over, its alias keeps a simple record and says so
+	template := assertions.Template_FromStack(stack, nil)
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":     "vault.staging.ebbo.dev.",
+		"Failover": "PRIMARY",
+	})
+	template.HasResourceProperties(jsii.String("AWS::Route53::RecordSet"), map[string]interface{}{
+		"Name":     "passwords.staging.ebbo.dev.",
+		"Failover": assertions.Match_Absent(),
+	})
+	annotations := assertions.Annotations_FromStack(stack)
+	annotations.HasWarning(jsii.String("*"), assertions.Match_StringLikeRegexp(jsii.String("passwords.staging.ebbo.dev redirects to vault.staging.ebbo.dev and does not fail over")))
 }
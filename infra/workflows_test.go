@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/jsii-runtime-go"
+	"gopkg.in/yaml.v3"
+
+	"aws-infra-sandbox/lib"
+	"aws-infra-sandbox/lib/naming"
+	"aws-infra-sandbox/stacks/bootstrap"
+)
+
+// workflowsDir holds the GitHub Actions workflows that deploy the app
+const workflowsDir = "../.github/workflows"
+
+// exampleSHA is the commit of the example workflow runs
+const exampleSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// workflowExpressions are example values of the expressions workflows pass to the deploy workflow
+var workflowExpressions = map[string]string{
+	"${{ github.sha }}":                            exampleSHA,
+	"${{ needs.release-please.outputs.tag_name }}": "v1.5.0",
+	"${{ github.event.pull_request.number }}":      "42",
+}
+
+// exampleRepository is the repository the example workflow runs belong to
+const exampleRepository = "ebbo/aws-infra-sandbox"
+
+// roleSecretPattern matches the secrets holding the deploy role ARN of an environment
+var roleSecretPattern = regexp.MustCompile(`^\$\{\{ secrets\.AWS_ROLE_TO_ASSUME_([A-Z]+) \}\}$`)
+
+// workflow is the part of a workflow file that decides how the app is deployed
+type workflow struct {
+	On map[string]struct {
+		Branches []string `yaml:"branches"`
+	} `yaml:"on"`
+	Jobs map[string]struct {
+		Uses        string            `yaml:"uses"`
+		Environment string            `yaml:"environment"`
+		With        map[string]string `yaml:"with"`
+		Secrets     map[string]string `yaml:"secrets"`
+		Steps       []struct {
+			With map[string]string `yaml:"with"`
+		} `yaml:"steps"`
+	} `yaml:"jobs"`
+}
+
+// loadWorkflows returns the workflow files by name
+func loadWorkflows(t *testing.T) map[string]workflow {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(workflowsDir, "*.yml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no workflows in %s: %v", workflowsDir, err)
+	}
+
+	workflows := map[string]workflow{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var file workflow
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		workflows[filepath.Base(path)] = file
+	}
+	return workflows
+}
+
+// subjects returns the sub claims of the OIDC tokens of the workflow's runs, jobs in a GitHub
+// deployment environment are identified by it instead
+func (w workflow) subjects() []string {
+	var subjects []string
+	for event, filter := range w.On {
+		switch event {
+		case "push":
+			for _, branch := range filter.Branches {
+				subjects = append(subjects, "ref:refs/heads/"+branch)
+			}
+		case "pull_request":
+			subjects = append(subjects, "pull_request")
+		default:
+			// Comments, schedules and manual runs use the default branch
+			subjects = append(subjects, "ref:refs/heads/main")
+		}
+	}
+	return subjects
+}
+
+// deployJob is a job calling the reusable deploy workflow, with its expressions expanded
+type deployJob struct {
+	Workflow    string
+	Name        string
+	Environment string
+	Version     string
+}
+
+// loadDeployJobs returns the jobs of every workflow that call the reusable deploy workflow
+func loadDeployJobs(t *testing.T) []deployJob {
+	t.Helper()
+	var jobs []deployJob
+	for name, file := range loadWorkflows(t) {
+		for jobName, job := range file.Jobs {
+			if !strings.HasSuffix(job.Uses, "reusable-aws-deploy.yml") {
+				continue
+			}
+			jobs = append(jobs, deployJob{
+				Workflow:    name,
+				Name:        jobName,
+				Environment: expandExpression(t, job.With["environment"]),
+				Version:     expandExpression(t, job.With["version"]),
+			})
+		}
+	}
+	if len(jobs) == 0 {
+		t.Fatal("no workflow calls the reusable deploy workflow")
+	}
+	return jobs
+}
+
+// expandExpression replaces a workflow expression by its example value
+func expandExpression(t *testing.T, value string) string {
+	t.Helper()
+	if !strings.Contains(value, "${{") {
+		return value
+	}
+	example, ok := workflowExpressions[value]
+	if !ok {
+		t.Fatalf("add an example value of %s to workflowExpressions", value)
+	}
+	return example
+}
+
+func TestWorkflowsPassValidBuildMetadata(t *testing.T) {
+	for _, job := range loadDeployJobs(t) {
+		// The deploy workflow passes the commit of the run as sha next to the version input
+		build := lib.BuildInfo{Version: job.Version, CommitSHA: exampleSHA}
+		if err := build.Validate(); err != nil {
+			t.Errorf("%s job %s: %v", job.Workflow, job.Name, err)
+		}
+	}
+}
+
+// deployRoleSubjects synthesizes the bootstrap stack of the shipped profiles and returns the
+// sub claims each deploy role trusts by environment
+func deployRoleSubjects(t *testing.T) map[string][]string {
+	t.Helper()
+	profiles, err := lib.ParseProfiles(defaultProfiles, ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack, err := bootstrap.NewBootstrapStack(awscdk.NewApp(nil), bootstrap.StackName, &bootstrap.BootstrapStackProps{
+		StackProps: awscdk.StackProps{Env: &awscdk.Environment{
+			Account: jsii.String("495599733505"),
+			Region:  jsii.String("eu-central-1"),
+		}},
+		Profiles:   profiles,
+		Repository: exampleRepository,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := assertions.Template_FromStack(stack.Stack, nil).FindResources(jsii.String("AWS::IAM::Role"), nil)
+	subjects := map[string][]string{}
+	for _, name := range profiles.Names() {
+		for _, resource := range *roles {
+			properties := (*resource)["Properties"].(map[string]interface{})
+			if properties["RoleName"] != naming.Role.Format("github-deploy", name) {
+				continue
+			}
+			statement := properties["AssumeRolePolicyDocument"].(map[string]interface{})["Statement"].([]interface{})[0]
+			condition := statement.(map[string]interface{})["Condition"].(map[string]interface{})["StringLike"]
+			switch sub := condition.(map[string]interface{})["token.actions.githubusercontent.com:sub"].(type) {
+			case string:
+				subjects[name] = []string{sub}
+			case []interface{}:
+				for _, value := range sub {
+					subjects[name] = append(subjects[name], value.(string))
+				}
+			}
+		}
+	}
+	return subjects
+}
+
+// matchesStringLike reports whether value matches an IAM StringLike pattern
+func matchesStringLike(pattern, value string) bool {
+	expression := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	return regexp.MustCompile("^" + expression + "$").MatchString(value)
+}
+
+func TestWorkflowsCanAssumeTheirDeployRoles(t *testing.T) {
+	trusted := deployRoleSubjects(t)
+	for name, file := range loadWorkflows(t) {
+		// The reusable workflow runs with the subject of its caller
+		if _, ok := file.On["workflow_call"]; ok {
+			continue
+		}
+		for jobName, job := range file.Jobs {
+			roles := []string{job.Secrets["AWS_ROLE_TO_ASSUME"]}
+			for _, step := range job.Steps {
+				roles = append(roles, step.With["role-to-assume"])
+			}
+			subjects := file.subjects()
+			if job.Environment != "" {
+				subjects = []string{"environment:" + job.Environment}
+			}
+
+			for _, role := range roles {
+				if role == "" {
+					continue
+				}
+				match := roleSecretPattern.FindStringSubmatch(role)
+				if match == nil {
+					t.Errorf("%s job %s assumes %s, use an AWS_ROLE_TO_ASSUME_<ENVIRONMENT> secret", name, jobName, role)
+					continue
+				}
+				environment := strings.ToLower(match[1])
+				if deployed := job.With["environment"]; deployed != "" && deployed != environment {
+					t.Errorf("%s job %s deploys %s with the role of %s", name, jobName, deployed, environment)
+				}
+				for _, subject := range subjects {
+					sub := "repo:" + exampleRepository + ":" + subject
+					if !slices.ContainsFunc(trusted[environment], func(pattern string) bool { return matchesStringLike(pattern, sub) }) {
+						t.Errorf("%s job %s runs as %s, which the %s deploy role does not trust", name, jobName, sub, environment)
+					}
+				}
+			}
+		}
+	}
+}
@@ -35,7 +35,7 @@ fi
 
 echo -e "${BLUE}Bootstrapping CDK in account ${AWS_ACCOUNT_ID} and region ${AWS_REGION}...${NC}"
 
-# Bootstrap CDK with permissions for GitHub Actions roles
+# Bootstrap CDK, the GitHub Actions deploy roles of `make setup-github` assume its roles
 cdk bootstrap aws://${AWS_ACCOUNT_ID}/${AWS_REGION} \
   --cloudformation-execution-policies arn:aws:iam::aws:policy/AdministratorAccess \
   --trust ${AWS_ACCOUNT_ID} \
@@ -43,48 +43,4 @@ cdk bootstrap aws://${AWS_ACCOUNT_ID}/${AWS_REGION} \
 
 echo -e "${GREEN}CDK bootstrap completed successfully.${NC}"
 
-# Update IAM roles with CDK bootstrap permissions if they exist
-if aws iam get-role --role-name GitHubActionsStaging &> /dev/null; then
-  echo -e "${BLUE}Adding CDK bootstrap permissions to GitHubActionsStaging role...${NC}"
-  
-  # Create inline policy for CDK bootstrap permissions
-  cat > .aws-github-oidc/cdk-bootstrap-policy.json << EOF
-{
-  "Version": "2012-10-17",
-  "Statement": [
-    {
-      "Effect": "Allow",
-      "Action": [
-        "cloudformation:DescribeStacks",
-        "ssm:GetParameter"
-      ],
-      "Resource": "*"
-    },
-    {
-      "Effect": "Allow",
-      "Action": "sts:AssumeRole",
-      "Resource": [
-        "arn:aws:iam::${AWS_ACCOUNT_ID}:role/cdk-*"
-      ]
-    }
-  ]
-}
-EOF
-  
-  # Attach the policy to both roles
-  aws iam put-role-policy \
-    --role-name GitHubActionsStaging \
-    --policy-name CDKBootstrapAccess \
-    --policy-document file://.aws-github-oidc/cdk-bootstrap-policy.json
-  
-  if aws iam get-role --role-name GitHubActionsProduction &> /dev/null; then
-    aws iam put-role-policy \
-      --role-name GitHubActionsProduction \
-      --policy-name CDKBootstrapAccess \
-      --policy-document file://.aws-github-oidc/cdk-bootstrap-policy.json
-  fi
-  
-  echo -e "${GREEN}CDK bootstrap permissions added to GitHub Actions roles.${NC}"
-fi
-
 echo -e "${GREEN}Setup complete! Your environment is now ready for CDK deployments.${NC}"